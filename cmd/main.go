@@ -3,8 +3,16 @@ package main
 import (
     "github.com/gin-gonic/gin"
     "github.com/spacelord16/Videoparty/internal/api"
+    "github.com/spacelord16/Videoparty/internal/config"
     "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/janitor"
+    "github.com/spacelord16/Videoparty/internal/mail"
     "github.com/spacelord16/Videoparty/internal/middleware"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/oidc"
+    "github.com/spacelord16/Videoparty/internal/password"
+    "github.com/spacelord16/Videoparty/internal/realtime"
+    "github.com/spacelord16/Videoparty/internal/scheduler"
     "github.com/joho/godotenv"
     "log"
 )
@@ -14,16 +22,39 @@ func main() {
         log.Println("No .env file found")
     }
 
+    password.InitHasher()
+
     if err := db.InitDB(); err != nil {
         log.Fatal("Failed to connect to database:", err)
     }
 
+    if err := mail.InitMailer(); err != nil {
+        log.Fatal("Failed to configure mailer:", err)
+    }
+
+    if err := oidc.InitProvider(); err != nil {
+        log.Fatal("Failed to configure OIDC:", err)
+    }
+
+    if err := realtime.Init(db.DB); err != nil {
+        log.Fatal("Failed to start realtime events:", err)
+    }
+
+    janitor.Start(db.DB, janitor.PolicyFromEnv())
+    scheduler.Start(db.DB, api.RoomStarted)
+
     r := gin.Default()
 
+    // Only believe X-Forwarded-For from the proxies in TRUSTED_PROXIES, so
+    // clients can't choose the address seen by login throttling and sessions.
+    if err := r.SetTrustedProxies(config.List("TRUSTED_PROXIES")); err != nil {
+        log.Fatal("Invalid TRUSTED_PROXIES:", err)
+    }
+
     // CORS middleware
     r.Use(func(c *gin.Context) {
         c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-        c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+        c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
         c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
         if c.Request.Method == "OPTIONS" {
             c.AbortWithStatus(204)
@@ -35,20 +66,109 @@ func main() {
     // Public routes
     r.POST("/api/register", api.Register)
     r.POST("/api/login", api.Login)
+    r.POST("/api/login/2fa", api.LoginTwoFactor)
+    r.POST("/api/login/magic", api.RequestMagicLink)
+    r.POST("/api/login/magic/verify", api.LoginMagicLink)
+    r.POST("/api/email/verify", api.VerifyEmail)
+    r.POST("/api/password/forgot", api.ForgotPassword)
+    r.POST("/api/password/reset", api.ResetPassword)
+    r.GET("/api/oidc/login", api.OIDCLogin)
+    r.GET("/api/oidc/callback", api.OIDCCallback)
+    r.GET("/api/avatars/:key/:size", api.GetAvatar)
 
-    // Protected routes
+    // Protected routes. API tokens need the scope a route declares, and
+    // account management requires an interactive session.
     protected := r.Group("/api")
     protected.Use(middleware.AuthMiddleware())
     {
+        session := middleware.RequireSession()
+        roomsRead := middleware.RequireScope(model.ScopeRoomsRead)
+        roomsWrite := middleware.RequireScope(model.ScopeRoomsWrite)
+        roomsControl := middleware.RequireScope(model.ScopeRoomsControl)
+        playlistWrite := middleware.RequireScope(model.ScopePlaylistWrite)
+
         // User routes
         protected.GET("/user", api.GetUser)
-        protected.PUT("/user", api.UpdateUser)
+        protected.PUT("/user", session, api.UpdateUser)
+        protected.DELETE("/user", session, api.DeleteAccount)
+        protected.GET("/user/export", session, api.ExportAccount)
+        protected.POST("/user/avatar", session, api.UploadAvatar)
+        protected.DELETE("/user/avatar", session, api.DeleteAvatar)
+        protected.POST("/email/verify/resend", session, api.ResendVerification)
+
+        // Two-factor routes
+        protected.POST("/user/2fa/setup", session, api.SetupTOTP)
+        protected.POST("/user/2fa/enable", session, api.EnableTOTP)
+        protected.POST("/user/2fa/disable", session, api.DisableTOTP)
+        protected.POST("/user/2fa/recovery-codes", session, api.RegenerateRecoveryCodes)
+
+        // Session routes
+        protected.GET("/user/sessions", session, api.ListSessions)
+        protected.DELETE("/user/sessions", session, api.RevokeOtherSessions)
+        protected.DELETE("/user/sessions/:id", session, api.RevokeSession)
+
+        // API token routes
+        protected.POST("/user/tokens", session, api.CreateAPIToken)
+        protected.GET("/user/tokens", session, api.ListAPITokens)
+        protected.DELETE("/user/tokens/:id", session, api.DeleteAPIToken)
+
+        // Vanity room code routes
+        protected.GET("/user/room-codes", session, api.ListRoomCodes)
+        protected.POST("/user/room-codes", session, api.ReserveRoomCode)
+        protected.DELETE("/user/room-codes/:code", session, api.ReleaseRoomCode)
+
+        // Room template routes
+        protected.GET("/user/room-templates", roomsRead, api.ListRoomTemplates)
+        protected.DELETE("/user/room-templates/:id", roomsWrite, api.DeleteRoomTemplate)
+        protected.POST("/user/room-templates/:id/rooms", roomsWrite, api.CreateRoomFromTemplate)
 
         // Room routes
-        protected.POST("/rooms", api.CreateRoom)
-        protected.GET("/rooms/:code", api.GetRoom)
-        protected.POST("/rooms/:code/join", api.JoinRoom)
-        protected.PUT("/rooms/:code/state", api.UpdateRoomState)
+        protected.GET("/rooms", roomsRead, api.ListRooms)
+        protected.POST("/rooms", roomsWrite, api.CreateRoom)
+        protected.GET("/rooms/:code", roomsRead, api.GetRoom)
+        protected.PATCH("/rooms/:code", roomsWrite, api.UpdateRoom)
+        protected.DELETE("/rooms/:code", roomsWrite, api.DeleteRoom)
+        protected.POST("/rooms/:code/close", roomsWrite, api.CloseRoom)
+        protected.POST("/rooms/:code/clone", roomsWrite, api.CloneRoom)
+        protected.POST("/rooms/:code/template", roomsWrite, api.SaveRoomTemplate)
+        protected.PUT("/rooms/:code/schedule", roomsWrite, api.SetSchedule)
+        protected.DELETE("/rooms/:code/schedule", roomsWrite, api.ClearSchedule)
+        protected.GET("/rooms/:code/calendar.ics", roomsRead, api.RoomCalendar)
+        protected.GET("/rooms/:code/stats", roomsRead, api.RoomStats)
+        protected.GET("/rooms/:code/stats.csv", roomsRead, api.RoomStatsCSV)
+        protected.GET("/rooms/:code/events", roomsRead, api.RoomEvents)
+        protected.GET("/rooms/:code/participants", roomsRead, api.ListParticipants)
+        protected.GET("/rooms/:code/queue", roomsRead, api.ListQueue)
+        protected.POST("/rooms/:code/queue", playlistWrite, api.AddToQueue)
+        protected.DELETE("/rooms/:code/queue/:id", playlistWrite, api.RemoveFromQueue)
+        protected.POST("/rooms/:code/participants/:user_id/kick", roomsWrite, api.KickParticipant)
+        protected.GET("/rooms/:code/bans", roomsWrite, api.ListBans)
+        protected.POST("/rooms/:code/bans", roomsWrite, api.BanUser)
+        protected.DELETE("/rooms/:code/bans/:user_id", roomsWrite, api.UnbanUser)
+        protected.GET("/rooms/:code/join-request", roomsRead, api.GetJoinRequest)
+        protected.GET("/rooms/:code/lobby", roomsWrite, api.ListLobby)
+        protected.POST("/rooms/:code/lobby/admit", roomsWrite, api.AdmitJoinRequests)
+        protected.POST("/rooms/:code/lobby/deny", roomsWrite, api.DenyJoinRequests)
+        protected.POST("/rooms/:code/lobby/:id/admit", roomsWrite, api.AdmitJoinRequests)
+        protected.POST("/rooms/:code/lobby/:id/deny", roomsWrite, api.DenyJoinRequests)
+        protected.POST("/rooms/:code/join", roomsRead, api.JoinRoom)
+        protected.PUT("/rooms/:code/state", roomsControl, api.UpdateRoomState)
+        protected.POST("/rooms/:code/host", roomsControl, api.TransferHost)
+
+        // Invite routes
+        protected.POST("/rooms/:code/invites", roomsWrite, api.CreateInvite)
+        protected.GET("/rooms/:code/invites", roomsWrite, api.ListInvites)
+        protected.DELETE("/rooms/:code/invites/:id", roomsWrite, api.RevokeInvite)
+        protected.POST("/invites/:token/join", roomsRead, api.RedeemInvite)
+    }
+
+    // Admin routes
+    admin := r.Group("/api/admin")
+    admin.Use(middleware.AuthMiddleware(), middleware.RequireSession(), middleware.AdminOnly())
+    {
+        admin.POST("/unlock", api.UnlockLogin)
+        admin.POST("/users/:id/2fa/reset", api.AdminResetTOTP)
+        admin.PUT("/users/:id/premium", api.SetPremium)
     }
 
     r.Run(":8080")
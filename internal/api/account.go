@@ -0,0 +1,172 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/mail"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/password"
+    "gorm.io/gorm"
+    "log"
+    "net/http"
+    "net/url"
+    "time"
+)
+
+// appLink builds a link into the frontend carrying token.
+func appLink(path, token string) string {
+    return config.String("APP_URL", "http://localhost:3000") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendTokenEmail issues a token for purpose and mails it to user using the
+// template of the same name. Failures are logged, not returned, so callers
+// never reveal whether delivery worked.
+func sendTokenEmail(user model.User, purpose, path string, ttl time.Duration) {
+    token, err := db.CreateUserToken(db.DB, user.ID, purpose, ttl)
+    if err != nil {
+        log.Printf("Error creating %s token for user %d: %v", purpose, user.ID, err)
+        return
+    }
+
+    err = mail.SendTemplate(purpose, user.Email, map[string]interface{}{
+        "Username":  user.Username,
+        "Link":      appLink(path, token),
+        "ExpiresIn": ttl.String(),
+    })
+    if err != nil {
+        log.Printf("Error sending %s email to user %d: %v", purpose, user.ID, err)
+    }
+}
+
+func sendVerificationEmail(user model.User) {
+    if user.Email == "" || user.EmailVerified {
+        return
+    }
+    ttl := config.Duration("EMAIL_VERIFICATION_TTL", 48*time.Hour)
+    sendTokenEmail(user, model.TokenVerifyEmail, "/verify-email", ttl)
+}
+
+func VerifyEmail(c *gin.Context) {
+    var verifyData VerifyEmailRequest
+    if !bindJSON(c, &verifyData) {
+        return
+    }
+
+    token, err := db.ConsumeUserToken(db.DB, verifyData.Token, model.TokenVerifyEmail)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Verification link is invalid or expired"})
+        return
+    }
+
+    if err := db.DB.Model(&model.User{}).Where("id = ?", token.UserID).Update("email_verified", true).Error; err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+func ResendVerification(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    var user model.User
+    if err := db.DB.First(&user, userID).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+
+    if user.EmailVerified {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+        return
+    }
+
+    if err := db.InvalidateUserTokens(db.DB, user.ID, model.TokenVerifyEmail); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+        return
+    }
+    sendVerificationEmail(user)
+
+    c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// passwordResetCooldown stops the endpoint being used to flood an inbox.
+const passwordResetCooldown = time.Minute
+
+func ForgotPassword(c *gin.Context) {
+    var forgotData ForgotPasswordRequest
+    if !bindJSON(c, &forgotData) || mailThrottled(c) {
+        return
+    }
+
+    // Always answer the same way, and look the address up off the request
+    // path, so neither the response nor its timing reveals which addresses
+    // have accounts.
+    queueMail(func() { sendPasswordReset(forgotData.Email) })
+
+    c.JSON(http.StatusOK, gin.H{"message": "If that address has an account, a reset link has been sent"})
+}
+
+// sendPasswordReset mails a reset link to the account with email, unless
+// there is none or it was sent one within passwordResetCooldown.
+func sendPasswordReset(email string) {
+    var user model.User
+    err := db.DB.Where("LOWER(email) = LOWER(?)", email).First(&user).Error
+    if err != nil {
+        if err != gorm.ErrRecordNotFound {
+            log.Printf("Error looking up password reset address: %v", err)
+        }
+        return
+    }
+
+    recent, err := db.RecentUserToken(db.DB, user.ID, model.TokenPasswordReset, passwordResetCooldown)
+    if err != nil {
+        log.Printf("Error checking recent reset links for user %d: %v", user.ID, err)
+        return
+    }
+    if recent {
+        return
+    }
+
+    ttl := config.Duration("PASSWORD_RESET_TTL", time.Hour)
+    sendTokenEmail(user, model.TokenPasswordReset, "/reset-password", ttl)
+}
+
+func ResetPassword(c *gin.Context) {
+    var resetData ResetPasswordRequest
+    if !bindJSON(c, &resetData) {
+        return
+    }
+
+    // Check the policy first so a rejected password doesn't burn the token.
+    if err := password.Validate(resetData.Password); err != nil {
+        writeFieldError(c, "password", "password_policy", err.Error())
+        return
+    }
+
+    token, err := db.ConsumeUserToken(db.DB, resetData.Token, model.TokenPasswordReset)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Reset link is invalid or expired"})
+        return
+    }
+
+    if err := db.UpdatePassword(db.DB, token.UserID, resetData.Password); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+        return
+    }
+
+    // Any other reset links that were sent are no longer needed, and
+    // whoever knew the old password is signed out.
+    if err := db.InvalidateUserTokens(db.DB, token.UserID, model.TokenPasswordReset); err != nil {
+        log.Printf("Error invalidating reset tokens for user %d: %v", token.UserID, err)
+    }
+    if err := db.RevokeOtherSessions(db.DB, token.UserID, 0); err != nil {
+        log.Printf("Error revoking sessions of user %d: %v", token.UserID, err)
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/mail"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+)
+
+// withMemoryMailer captures the mail sent during the test.
+func withMemoryMailer(t *testing.T) *mail.MemoryMailer {
+    t.Helper()
+    m := &mail.MemoryMailer{}
+    old := mail.Sender
+    mail.Sender = m
+    t.Cleanup(func() { mail.Sender = old })
+    return m
+}
+
+func TestSendPasswordReset(t *testing.T) {
+    conn := testDB(t)
+    outbox := withMemoryMailer(t)
+    newTestUser(t, conn, "alice")
+
+    sendPasswordReset("ALICE@example.com")
+    sent := outbox.Messages()
+    if len(sent) != 1 || sent[0].To != "alice@example.com" || !strings.Contains(sent[0].Body, "/reset-password?token=") {
+        t.Fatalf("sent = %+v, want one reset link to alice", sent)
+    }
+
+    sendPasswordReset("alice@example.com")
+    if n := len(outbox.Messages()); n != 1 {
+        t.Errorf("sent %d messages within the cooldown, want 1", n)
+    }
+
+    sendPasswordReset("nobody@example.com")
+    if n := len(outbox.Messages()); n != 1 {
+        t.Errorf("mail was sent for an unknown address")
+    }
+}
+
+func TestQueueMail(t *testing.T) {
+    done := make(chan bool)
+    if !queueMail(func() { done <- true }) {
+        t.Fatal("an empty queue refused a job")
+    }
+    select {
+    case <-done:
+    case <-time.After(time.Second):
+        t.Fatal("the queued job did not run")
+    }
+}
+
+func TestForgotPasswordIsThrottledPerAddress(t *testing.T) {
+    conn := testDB(t)
+    withMemoryMailer(t)
+    t.Setenv("MAIL_IP_THRESHOLD", "2")
+    newTestUser(t, conn, "alice")
+
+    r := testRouter()
+    r.POST("/forgot-password", ForgotPassword)
+    for i := 1; i <= 2; i++ {
+        if w := serve(r, http.MethodPost, "/forgot-password", 0, gin.H{"email": "nobody@example.com"}); w.Code != http.StatusOK {
+            t.Fatalf("request %d: status = %d", i, w.Code)
+        }
+    }
+    w := serve(r, http.MethodPost, "/forgot-password", 0, gin.H{"email": "alice@example.com"})
+    if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+        t.Errorf("request past the limit: status = %d, headers %v", w.Code, w.Header())
+    }
+}
+
+func TestResetPassword(t *testing.T) {
+    conn := testDB(t)
+    user := newTestUser(t, conn, "alice")
+    session := model.Session{UserID: user.ID, JTI: "j1", ExpiresAt: time.Now().Add(time.Hour)}
+    if err := conn.Create(&session).Error; err != nil {
+        t.Fatal(err)
+    }
+    token, err := db.CreateUserToken(conn, user.ID, model.TokenPasswordReset, time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    other, _ := db.CreateUserToken(conn, user.ID, model.TokenPasswordReset, time.Hour)
+
+    r := testRouter()
+    r.POST("/reset-password", ResetPassword)
+    const newPassword = "a long and unusual passphrase 42"
+
+    w := serve(r, http.MethodPost, "/reset-password", 0, map[string]string{"token": token, "password": "short"})
+    if w.Code != http.StatusBadRequest {
+        t.Fatalf("weak password: status %d", w.Code)
+    }
+    w = serve(r, http.MethodPost, "/reset-password", 0, map[string]string{"token": token, "password": newPassword})
+    if w.Code != http.StatusOK {
+        t.Fatalf("reset: status %d, %s", w.Code, w.Body)
+    }
+    if _, err := db.AuthenticateUser(conn, "alice", newPassword); err != nil {
+        t.Errorf("new password rejected: %v", err)
+    }
+
+    w = serve(r, http.MethodPost, "/reset-password", 0, map[string]string{"token": other, "password": newPassword})
+    if w.Code != http.StatusBadRequest {
+        t.Errorf("second reset link still works: status %d", w.Code)
+    }
+    conn.First(&session, session.ID)
+    if session.RevokedAt == nil {
+        t.Error("sessions were not revoked")
+    }
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "log"
+    "net/http"
+    "strconv"
+)
+
+func UnlockLogin(c *gin.Context) {
+    var unlockData UnlockLoginRequest
+    if !bindJSON(c, &unlockData) {
+        return
+    }
+
+    if unlockData.Username != "" {
+        subject, err := db.AccountSubject(db.DB, unlockData.Username)
+        if err == nil {
+            err = db.ClearLoginThrottle(db.DB, subject)
+        }
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+            return
+        }
+    }
+    if unlockData.IP != "" {
+        if err := db.ClearLoginThrottle(db.DB, db.IPSubject(unlockData.IP)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock address"})
+            return
+        }
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Login lock cleared"})
+}
+
+// SetPremium grants or revokes a user's premium status.
+func SetPremium(c *gin.Context) {
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        return
+    }
+
+    var premiumData SetPremiumRequest
+    if !bindJSON(c, &premiumData) {
+        return
+    }
+
+    res := db.DB.Model(&model.User{}).Where("id = ?", id).Update("premium", premiumData.Premium)
+    if res.Error != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+        return
+    }
+    if res.RowsAffected == 0 {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+
+    adminID, _ := c.Get("userID")
+    log.Printf("Admin %v set premium=%t for user %d", adminID, premiumData.Premium, id)
+
+    c.Status(http.StatusNoContent)
+}
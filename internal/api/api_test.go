@@ -0,0 +1,86 @@
+package api
+
+import (
+    "bytes"
+    "encoding/json"
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/dbtest"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "gorm.io/gorm"
+    "net/http/httptest"
+    "strconv"
+    "testing"
+)
+
+// testDB points db.DB at a migrated database of the test's own, skipping the
+// test when no database is configured.
+func testDB(t *testing.T) *gorm.DB {
+    t.Helper()
+    conn := dbtest.Open(t, db.Migrate)
+    old := db.DB
+    db.DB = conn
+    t.Cleanup(func() { db.DB = old })
+    return conn
+}
+
+// testRouter returns a router whose requests are made by the user whose ID
+// is in the X-Test-User header, standing in for AuthMiddleware.
+func testRouter() *gin.Engine {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.Use(func(c *gin.Context) {
+        if id, err := strconv.ParseUint(c.GetHeader("X-Test-User"), 10, 64); err == nil {
+            c.Set("userID", uint(id))
+        }
+    })
+    return r
+}
+
+// serve runs a request as userID against r, encoding body as JSON if it is
+// not nil.
+func serve(r *gin.Engine, method, path string, userID uint, body interface{}) *httptest.ResponseRecorder {
+    var buf bytes.Buffer
+    if body != nil {
+        json.NewEncoder(&buf).Encode(body)
+    }
+    req := httptest.NewRequest(method, path, &buf)
+    req.Header.Set("Content-Type", "application/json")
+    if userID != 0 {
+        req.Header.Set("X-Test-User", strconv.FormatUint(uint64(userID), 10))
+    }
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    return w
+}
+
+func newTestUser(t *testing.T, conn *gorm.DB, name string) model.User {
+    t.Helper()
+    user := model.User{Username: name, Email: name + "@example.com", EmailVerified: true}
+    if err := conn.Create(&user).Error; err != nil {
+        t.Fatalf("create user %s: %v", name, err)
+    }
+    return user
+}
+
+// newTestRoom stores room, filling in an open public default, with its host
+// as a participant.
+func newTestRoom(t *testing.T, conn *gorm.DB, room model.Room) model.Room {
+    t.Helper()
+    if room.Name == "" {
+        room.Name = "Movie night"
+    }
+    if room.Visibility == "" {
+        room.Visibility = model.VisibilityPublic
+    }
+    if room.JoinPolicy == "" {
+        room.JoinPolicy = model.JoinOpen
+    }
+    if err := db.CreateRoom(conn, &room); err != nil {
+        t.Fatalf("create room: %v", err)
+    }
+    if err := db.TouchParticipant(conn, &room, room.HostID); err != nil {
+        t.Fatalf("add host: %v", err)
+    }
+    return room
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+func CreateAPIToken(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    var tokenData CreateAPITokenRequest
+    if !bindJSON(c, &tokenData) {
+        return
+    }
+
+    expiresAt := time.Now().Add(time.Duration(tokenData.ExpiresIn) * time.Second)
+    apiToken := model.APIToken{
+        UserID:    userID.(uint),
+        Name:      tokenData.Name,
+        Scopes:    strings.Join(tokenData.Scopes, " "),
+        ExpiresAt: &expiresAt,
+    }
+
+    token, err := db.CreateAPIToken(db.DB, &apiToken)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+        return
+    }
+
+    // The token is only ever returned here.
+    c.JSON(http.StatusCreated, gin.H{
+        "api_token": apiToken,
+        "token":     token,
+    })
+}
+
+func ListAPITokens(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    tokens, err := db.ListAPITokens(db.DB, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tokens"})
+        return
+    }
+
+    c.JSON(http.StatusOK, tokens)
+}
+
+func DeleteAPIToken(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+        return
+    }
+
+    if err := db.DeleteAPIToken(db.DB, userID.(uint), uint(id)); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
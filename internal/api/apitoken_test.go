@@ -0,0 +1,51 @@
+package api
+
+import (
+    "github.com/spacelord16/Videoparty/internal/model"
+    "net/http"
+    "testing"
+    "time"
+)
+
+func TestCreateAPITokenValidation(t *testing.T) {
+    r := testRouter()
+    r.POST("/tokens", CreateAPIToken)
+
+    tests := []struct {
+        name string
+        body map[string]interface{}
+    }{
+        {"no scopes", map[string]interface{}{"name": "ci", "scopes": []string{}}},
+        {"unknown scope", map[string]interface{}{"name": "ci", "scopes": []string{"admin"}}},
+        {"no lifetime", map[string]interface{}{"name": "ci", "scopes": []string{"rooms:read"}}},
+        {"zero lifetime", map[string]interface{}{"name": "ci", "scopes": []string{"rooms:read"}, "expires_in": 0}},
+        {"negative lifetime", map[string]interface{}{"name": "ci", "scopes": []string{"rooms:read"}, "expires_in": -1}},
+        {"lifetime over a year", map[string]interface{}{"name": "ci", "scopes": []string{"rooms:read"}, "expires_in": 31536001}},
+        {"lifetime overflowing a duration", map[string]interface{}{"name": "ci", "scopes": []string{"rooms:read"}, "expires_in": int64(1) << 40}},
+    }
+    for _, tt := range tests {
+        if w := serve(r, http.MethodPost, "/tokens", 1, tt.body); w.Code != http.StatusBadRequest {
+            t.Errorf("%s: status %d, want 400", tt.name, w.Code)
+        }
+    }
+}
+
+func TestCreateAPIToken(t *testing.T) {
+    conn := testDB(t)
+    user := newTestUser(t, conn, "alice")
+
+    r := testRouter()
+    r.POST("/tokens", CreateAPIToken)
+    w := serve(r, http.MethodPost, "/tokens", user.ID, map[string]interface{}{
+        "name": "ci", "scopes": []string{"rooms:read", "playlist:write"}, "expires_in": 3600,
+    })
+    if w.Code != http.StatusCreated {
+        t.Fatalf("status %d, %s", w.Code, w.Body)
+    }
+
+    var stored model.APIToken
+    conn.Where("user_id = ?", user.ID).First(&stored)
+    if stored.ExpiresAt == nil || time.Until(*stored.ExpiresAt) > time.Hour {
+        t.Errorf("token expires at %v, want within an hour", stored.ExpiresAt)
+    }
+}
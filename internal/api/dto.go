@@ -0,0 +1,154 @@
+package api
+
+import (
+    "github.com/spacelord16/Videoparty/internal/avatar"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "log"
+    "strconv"
+    "time"
+)
+
+// Responses are built from these DTOs rather than the gorm models, so
+// persistence-only fields such as password hashes can never be serialised.
+
+// UserSummary is how a user appears to other users.
+type UserSummary struct {
+    ID          uint    `json:"id"`
+    Username    string  `json:"username"`
+    DisplayName string  `json:"display_name"`
+    AvatarURL   *string `json:"avatar_url"`
+}
+
+// summaryAvatarSize is the avatar size linked from user summaries.
+const summaryAvatarSize = 128
+
+func newUserSummary(user model.User) UserSummary {
+    summary := UserSummary{
+        ID:          user.ID,
+        Username:    user.Username,
+        DisplayName: user.DisplayName,
+    }
+    if summary.DisplayName == "" {
+        summary.DisplayName = user.Username
+    }
+    if user.AvatarKey != "" {
+        u := avatar.URL(user.AvatarKey, summaryAvatarSize)
+        summary.AvatarURL = &u
+    }
+    return summary
+}
+
+// UserResponse is how a user appears to themselves.
+type UserResponse struct {
+    ID            uint              `json:"id"`
+    Username      string            `json:"username"`
+    Email         string            `json:"email"`
+    EmailVerified bool              `json:"email_verified"`
+    TOTPEnabled   bool              `json:"totp_enabled"`
+    Premium       bool              `json:"premium"`
+    DisplayName   string            `json:"display_name"`
+    Bio           string            `json:"bio"`
+    AvatarURLs    map[string]string `json:"avatar_urls"`
+}
+
+func newUserResponse(user model.User) UserResponse {
+    resp := UserResponse{
+        ID:            user.ID,
+        Username:      user.Username,
+        Email:         user.Email,
+        EmailVerified: user.EmailVerified,
+        TOTPEnabled:   user.TOTPEnabled,
+        Premium:       user.Premium,
+        DisplayName:   user.DisplayName,
+        Bio:           user.Bio,
+    }
+    if user.AvatarKey != "" {
+        resp.AvatarURLs = make(map[string]string, len(avatar.Sizes))
+        for _, size := range avatar.Sizes {
+            resp.AvatarURLs[strconv.Itoa(size)] = avatar.URL(user.AvatarKey, size)
+        }
+    }
+    return resp
+}
+
+// ParticipantResponse is one entry of a room's participant list.
+type ParticipantResponse struct {
+    User       UserSummary `json:"user"`
+    Role       string      `json:"role"`
+    IsHost     bool        `json:"is_host"`
+    JoinedAt   time.Time   `json:"joined_at"`
+    LastSeenAt time.Time   `json:"last_seen_at"`
+}
+
+func newParticipantResponse(room model.Room, p model.RoomParticipant) ParticipantResponse {
+    return ParticipantResponse{
+        User:       newUserSummary(p.User),
+        Role:       p.Role,
+        IsHost:     p.UserID == room.HostID,
+        JoinedAt:   p.JoinedAt,
+        LastSeenAt: p.LastSeenAt,
+    }
+}
+
+type RoomResponse struct {
+    ID          uint         `json:"id"`
+    Name        string       `json:"name"`
+    Code        string       `json:"code"`
+    HostID      uint         `json:"host_id"`
+    Host        *UserSummary `json:"host"`
+    VideoURL    string       `json:"video_url"`
+    Visibility  string       `json:"visibility"`
+    HasPassword bool         `json:"has_password"`
+    JoinPolicy  string       `json:"join_policy"`
+    MaxParticipants int      `json:"max_participants"` // effective limit, 0 if ROOM_MAX_PARTICIPANTS is 0
+    IsPlaying   bool         `json:"is_playing"`
+    CurrentTime float64      `json:"current_time"`
+    Platform    string       `json:"platform"`
+    LastActivityAt time.Time `json:"last_activity_at"`
+    Schedule    *ScheduleResponse `json:"schedule"`
+    ClosedAt    *time.Time   `json:"closed_at"`
+    CreatedAt   time.Time    `json:"created_at"`
+    UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// newRoomResponse builds the response for room, loading its host if the
+// association wasn't preloaded or is stale after a host change.
+func newRoomResponse(room model.Room) RoomResponse {
+    resp := RoomResponse{
+        ID:          room.ID,
+        Name:        room.Name,
+        Code:        room.Code,
+        HostID:      room.HostID,
+        VideoURL:    room.VideoURL,
+        Visibility:  room.Visibility,
+        HasPassword: room.HasPassword(),
+        JoinPolicy:  room.JoinPolicy,
+        MaxParticipants: db.RoomCapacity(&room),
+        IsPlaying:   room.IsPlaying,
+        CurrentTime: room.CurrentTime,
+        Platform:    room.Platform,
+        LastActivityAt: room.LastActivityAt,
+        Schedule:    newScheduleResponse(room),
+        ClosedAt:    room.ClosedAt,
+        CreatedAt:   room.CreatedAt,
+        UpdatedAt:   room.UpdatedAt,
+    }
+
+    host := room.Host
+    if host.ID != room.HostID {
+        if err := db.DB.First(&host, room.HostID).Error; err != nil {
+            log.Printf("Error loading host of room %s: %v", room.Code, err)
+            return resp
+        }
+    }
+    summary := newUserSummary(host)
+    resp.Host = &summary
+    return resp
+}
+
+// RoomListingResponse is one entry of the room directory.
+type RoomListingResponse struct {
+    RoomResponse
+    ParticipantCount int64 `json:"participant_count"`
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+    "encoding/json"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "strings"
+    "testing"
+)
+
+func TestUserResponsesHideSecrets(t *testing.T) {
+    user := model.User{
+        ID:           1,
+        Username:     "alice",
+        Email:        "alice@example.com",
+        Password:     "$argon2id$secret-hash",
+        TOTPSecret:   "TOTPSECRET",
+        TOTPLastStep: 99,
+    }
+    for name, v := range map[string]interface{}{
+        "UserResponse": newUserResponse(user),
+        "UserSummary":  newUserSummary(user),
+    } {
+        data, err := json.Marshal(v)
+        if err != nil {
+            t.Fatal(err)
+        }
+        for _, secret := range []string{"secret-hash", "TOTPSECRET", "99"} {
+            if strings.Contains(string(data), secret) {
+                t.Errorf("%s leaks %q: %s", name, secret, data)
+            }
+        }
+    }
+
+    data, _ := json.Marshal(newUserSummary(user))
+    if strings.Contains(string(data), "alice@example.com") {
+        t.Errorf("UserSummary shows the email address to other users: %s", data)
+    }
+}
+
+func TestUserSummaryDisplayName(t *testing.T) {
+    if got := newUserSummary(model.User{Username: "alice"}).DisplayName; got != "alice" {
+        t.Errorf("display name without one set = %q, want the username", got)
+    }
+    if got := newUserSummary(model.User{Username: "alice", DisplayName: "Alice A."}).DisplayName; got != "Alice A." {
+        t.Errorf("display name = %q", got)
+    }
+    if newUserSummary(model.User{Username: "alice"}).AvatarURL != nil {
+        t.Error("avatar URL without an avatar")
+    }
+}
+
+func TestRoomResponse(t *testing.T) {
+    t.Setenv("ROOM_MAX_PARTICIPANTS", "50")
+    host := model.User{ID: 7, Username: "host"}
+    room := model.Room{ID: 1, Code: "ABCDEFGH", HostID: 7, Host: host, PasswordHash: "$2a$hash"}
+
+    resp := newRoomResponse(room)
+    if !resp.HasPassword || resp.Host == nil || resp.Host.Username != "host" {
+        t.Errorf("resp = %+v", resp)
+    }
+    if resp.MaxParticipants != 50 {
+        t.Errorf("max_participants = %d, want the server default 50", resp.MaxParticipants)
+    }
+    room.MaxParticipants = 5
+    if got := newRoomResponse(room).MaxParticipants; got != 5 {
+        t.Errorf("max_participants = %d, want the room's own 5", got)
+    }
+
+    data, _ := json.Marshal(resp)
+    if strings.Contains(string(data), "$2a$hash") {
+        t.Errorf("room response leaks the password hash: %s", data)
+    }
+}
+
+func TestInviteAndRoomCodeResponsesHideSecrets(t *testing.T) {
+    for name, v := range map[string]interface{}{
+        "InviteResponse":   newInviteResponse(model.RoomInvite{ID: 1, RoomID: 2, CreatedBy: 3, TokenHash: "secret-token-hash", Role: model.RoleViewer}),
+        "RoomCodeResponse": newRoomCodeResponse(model.RoomCodeReservation{ID: 1, Code: "MOVIES", UserID: 424242}),
+    } {
+        data, err := json.Marshal(v)
+        if err != nil {
+            t.Fatal(err)
+        }
+        for _, secret := range []string{"secret-token-hash", "token_hash", "room_id", "424242", "user_id"} {
+            if strings.Contains(string(data), secret) {
+                t.Errorf("%s leaks %q: %s", name, secret, data)
+            }
+        }
+    }
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/realtime"
+    "io"
+    "net/http"
+    "time"
+)
+
+// eventKeepAlive is how often an idle event stream sends a comment, so
+// proxies don't time it out.
+const eventKeepAlive = 30 * time.Second
+
+// RoomEvents streams a room's events to the caller as server-sent events
+// until the client goes away or the server disconnects it.
+func RoomEvents(c *gin.Context) {
+    room, err := db.FindRoom(db.DB, c.Param("code"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+        return
+    }
+    if roomClosed(c, &room) {
+        return
+    }
+
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    ban, banned, err := db.ActiveBan(db.DB, room.ID, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load room"})
+        return
+    }
+    if banned {
+        writeBanned(c, ban)
+        return
+    }
+
+    allowed, err := canViewRoom(&room, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load room"})
+        return
+    }
+
+    // Users waiting in the lobby of a room they can't see yet only hear
+    // about their own request.
+    directOnly := false
+    if !allowed {
+        pending, err := db.HasPendingJoinRequest(db.DB, room.ID, userID.(uint))
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load room"})
+            return
+        }
+        if !pending {
+            c.JSON(http.StatusForbidden, gin.H{"error": "This room is private"})
+            return
+        }
+        directOnly = true
+    }
+
+    sub := realtime.Subscribe(room.ID, userID.(uint), directOnly)
+    defer realtime.Unsubscribe(sub)
+
+    c.Header("Cache-Control", "no-cache")
+    c.Header("X-Accel-Buffering", "no")
+
+    keepAlive := time.NewTicker(eventKeepAlive)
+    defer keepAlive.Stop()
+
+    c.Stream(func(w io.Writer) bool {
+        select {
+        case ev, ok := <-sub.Events:
+            if !ok {
+                return false
+            }
+            c.SSEvent(ev.Type, ev.Data)
+            return !ev.Close
+        case <-keepAlive.C:
+            _, err := io.WriteString(w, ": keep-alive\n\n")
+            return err == nil
+        case <-c.Request.Context().Done():
+            return false
+        }
+    })
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "gorm.io/gorm"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+// InviteResponse is one of a room's invites, without its token.
+type InviteResponse struct {
+    ID        uint       `json:"id"`
+    Role      string     `json:"role"`
+    MaxUses   int        `json:"max_uses"`
+    Uses      int        `json:"uses"`
+    ExpiresAt time.Time  `json:"expires_at"`
+    RevokedAt *time.Time `json:"revoked_at"`
+    CreatedBy uint       `json:"created_by"`
+    CreatedAt time.Time  `json:"created_at"`
+}
+
+func newInviteResponse(invite model.RoomInvite) InviteResponse {
+    return InviteResponse{
+        ID:        invite.ID,
+        Role:      invite.Role,
+        MaxUses:   invite.MaxUses,
+        Uses:      invite.Uses,
+        ExpiresAt: invite.ExpiresAt,
+        RevokedAt: invite.RevokedAt,
+        CreatedBy: invite.CreatedBy,
+        CreatedAt: invite.CreatedAt,
+    }
+}
+
+func CreateInvite(c *gin.Context) {
+    room, userID, ok := hostRoom(c)
+    if !ok || roomClosed(c, &room) {
+        return
+    }
+
+    var inviteData CreateInviteRequest
+    if !bindJSON(c, &inviteData) {
+        return
+    }
+
+    if inviteData.Role == "" {
+        inviteData.Role = model.RoleViewer
+    }
+
+    ttl := time.Duration(inviteData.ExpiresIn) * time.Second
+    if ttl == 0 {
+        ttl = config.Duration("INVITE_DEFAULT_TTL", 24*time.Hour)
+    }
+
+    invite := model.RoomInvite{
+        RoomID:    room.ID,
+        CreatedBy: userID,
+        Role:      inviteData.Role,
+        MaxUses:   inviteData.MaxUses,
+        ExpiresAt: time.Now().Add(ttl),
+    }
+
+    token, err := db.CreateInvite(db.DB, &invite)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+        return
+    }
+
+    // The token is only ever returned here.
+    c.JSON(http.StatusCreated, gin.H{
+        "invite": newInviteResponse(invite),
+        "token":  token,
+    })
+}
+
+func ListInvites(c *gin.Context) {
+    room, _, ok := hostRoom(c)
+    if !ok {
+        return
+    }
+
+    invites, err := db.ListInvites(db.DB, room.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list invites"})
+        return
+    }
+
+    resp := make([]InviteResponse, 0, len(invites))
+    for _, invite := range invites {
+        resp = append(resp, newInviteResponse(invite))
+    }
+    c.JSON(http.StatusOK, resp)
+}
+
+func RevokeInvite(c *gin.Context) {
+    room, _, ok := hostRoom(c)
+    if !ok {
+        return
+    }
+
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
+        return
+    }
+
+    if err := db.RevokeInvite(db.DB, room.ID, uint(id)); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+func RedeemInvite(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    room, err := db.JoinWithInvite(db.DB, c.Param("token"), userID.(uint))
+    if err != nil {
+        switch err {
+        case db.ErrInviteInvalid, gorm.ErrRecordNotFound:
+            c.JSON(http.StatusNotFound, gin.H{"error": "Invite is invalid or expired"})
+        case db.ErrRoomFull:
+            c.JSON(http.StatusConflict, gin.H{"error": "Room is full"})
+        case db.ErrBanned:
+            c.JSON(http.StatusForbidden, gin.H{"error": "You are banned from this room"})
+        default:
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem invite"})
+        }
+        return
+    }
+
+    trackPresence(c, &room)
+
+    c.JSON(http.StatusOK, newRoomResponse(room))
+}
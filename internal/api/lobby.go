@@ -0,0 +1,223 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/realtime"
+    "log"
+    "math"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+// JoinRequestResponse is a user waiting in, or decided from, a room's lobby.
+type JoinRequestResponse struct {
+    ID        uint        `json:"id"`
+    User      UserSummary `json:"user"`
+    Status    string      `json:"status"`
+    CreatedAt time.Time   `json:"created_at"`
+    ExpiresAt time.Time   `json:"expires_at"`
+    DecidedAt *time.Time  `json:"decided_at"`
+}
+
+func newJoinRequestResponse(req model.JoinRequest) JoinRequestResponse {
+    return JoinRequestResponse{
+        ID:        req.ID,
+        User:      newUserSummary(req.User),
+        Status:    req.Status,
+        CreatedAt: req.CreatedAt,
+        ExpiresAt: req.ExpiresAt,
+        DecidedAt: req.DecidedAt,
+    }
+}
+
+func newJoinRequestResponses(reqs []model.JoinRequest) []JoinRequestResponse {
+    resp := make([]JoinRequestResponse, 0, len(reqs))
+    for _, req := range reqs {
+        resp = append(resp, newJoinRequestResponse(req))
+    }
+    return resp
+}
+
+// lobbyTTL is how long a join request waits for the host before expiring.
+func lobbyTTL() time.Duration {
+    return config.Duration("LOBBY_REQUEST_TTL", 10*time.Minute)
+}
+
+// lobbyDenyCooldown is how long a user the host turned away must wait
+// before asking to join again.
+func lobbyDenyCooldown() time.Duration {
+    return config.Duration("LOBBY_DENY_COOLDOWN", 5*time.Minute)
+}
+
+// enterLobby answers a join of an approval room by queueing the caller for
+// the host, who is notified of new requests. Users denied within
+// lobbyDenyCooldown get 429 until it has passed.
+func enterLobby(c *gin.Context, room *model.Room, userID uint) {
+    cooldown := lobbyDenyCooldown()
+    req, created, err := db.RequestToJoin(db.DB, room, userID, lobbyTTL(), cooldown)
+    if err == db.ErrDeniedRecently {
+        retryAfter := int(math.Ceil(time.Until(req.DecidedAt.Add(cooldown)).Seconds()))
+        c.Header("Retry-After", strconv.Itoa(retryAfter))
+        c.JSON(http.StatusTooManyRequests, gin.H{
+            "error":       "The host turned down your request, try again later",
+            "retry_after": retryAfter,
+        })
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join room"})
+        return
+    }
+    if err := db.DB.First(&req.User, userID).Error; err != nil {
+        log.Printf("Error loading user %d for join request: %v", userID, err)
+    }
+
+    resp := newJoinRequestResponse(req)
+    if created {
+        realtime.Notify(room.ID, room.HostID, realtime.EventLobby, resp)
+    }
+    c.JSON(http.StatusAccepted, resp)
+}
+
+// GetJoinRequest returns the caller's latest request to join the room, so a
+// waiting client can poll for the host's decision.
+func GetJoinRequest(c *gin.Context) {
+    room, err := db.FindRoom(db.DB, c.Param("code"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+        return
+    }
+
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+
+    req, err := db.LatestJoinRequest(db.DB, room.ID, user.ID)
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "No join request"})
+        return
+    }
+    if req.Status == model.JoinPending && !time.Now().Before(req.ExpiresAt) {
+        req.Status = model.JoinExpired
+    }
+    req.User = user
+
+    c.JSON(http.StatusOK, newJoinRequestResponse(req))
+}
+
+// ListLobby returns the requests waiting for the host's decision.
+func ListLobby(c *gin.Context) {
+    room, _, ok := hostRoom(c)
+    if !ok {
+        return
+    }
+
+    reqs, err := db.PendingJoinRequests(db.DB, room.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list join requests"})
+        return
+    }
+
+    c.JSON(http.StatusOK, newJoinRequestResponses(reqs))
+}
+
+// lobbySelection returns the join requests a decision applies to: the one
+// in the :id parameter, or those listed in the body, where nil means the
+// whole lobby. On failure it writes the error response and returns false.
+func lobbySelection(c *gin.Context) ([]uint, bool) {
+    if param := c.Param("id"); param != "" {
+        id, err := strconv.ParseUint(param, 10, 64)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+            return nil, false
+        }
+        return []uint{uint(id)}, true
+    }
+
+    var decideData DecideJoinRequestsRequest
+    if !bindJSON(c, &decideData) {
+        return nil, false
+    }
+    if decideData.All {
+        return nil, true
+    }
+    return decideData.RequestIDs, true
+}
+
+// AdmitJoinRequests lets lobby users into the room, one by ID or several at
+// once, until the room is full. Admitted users are notified and their lobby
+// connections closed, so they reconnect as participants; banned users are
+// turned away as if denied.
+func AdmitJoinRequests(c *gin.Context) {
+    room, hostID, ok := hostRoom(c)
+    if !ok || roomClosed(c, &room) {
+        return
+    }
+    ids, ok := lobbySelection(c)
+    if !ok {
+        return
+    }
+
+    admitted, denied, full, err := db.AdmitJoinRequests(db.DB, &room, ids, hostID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to admit join requests"})
+        return
+    }
+    if len(admitted) == 0 && full {
+        c.JSON(http.StatusConflict, gin.H{"error": "Room is full"})
+        return
+    }
+    if len(admitted) == 0 && len(denied) == 0 && c.Param("id") != "" {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Join request not found"})
+        return
+    }
+
+    roomResp := newRoomResponse(room)
+    for _, req := range admitted {
+        realtime.Disconnect(room.ID, req.UserID, realtime.EventAdmitted, roomResp)
+    }
+    deniedResp := newJoinRequestResponses(denied)
+    for i, req := range denied {
+        realtime.Disconnect(room.ID, req.UserID, realtime.EventDenied, deniedResp[i])
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "admitted":  newJoinRequestResponses(admitted),
+        "denied":    deniedResp,
+        "room_full": full,
+    })
+}
+
+// DenyJoinRequests turns lobby users away, one by ID or several at once.
+func DenyJoinRequests(c *gin.Context) {
+    room, hostID, ok := hostRoom(c)
+    if !ok {
+        return
+    }
+    ids, ok := lobbySelection(c)
+    if !ok {
+        return
+    }
+
+    denied, err := db.DenyJoinRequests(db.DB, room.ID, ids, hostID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deny join requests"})
+        return
+    }
+    if len(denied) == 0 && c.Param("id") != "" {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Join request not found"})
+        return
+    }
+
+    resp := newJoinRequestResponses(denied)
+    for i, req := range denied {
+        realtime.Disconnect(room.ID, req.UserID, realtime.EventDenied, resp[i])
+    }
+
+    c.JSON(http.StatusOK, gin.H{"denied": resp})
+}
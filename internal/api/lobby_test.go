@@ -0,0 +1,151 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/realtime"
+    "net/http"
+    "testing"
+)
+
+func TestLobby(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    alice := newTestUser(t, conn, "alice")
+    bob := newTestUser(t, conn, "bob")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID, JoinPolicy: model.JoinApproval})
+
+    r := testRouter()
+    r.POST("/rooms/:code/join", JoinRoom)
+    r.GET("/rooms/:code/join-request", GetJoinRequest)
+    r.GET("/rooms/:code/lobby", ListLobby)
+    r.POST("/rooms/:code/lobby/:id/admit", AdmitJoinRequests)
+    r.POST("/rooms/:code/lobby/deny", DenyJoinRequests)
+    path := "/rooms/" + room.Code
+
+    hostEvents := realtime.Subscribe(room.ID, host.ID, false)
+    defer realtime.Unsubscribe(hostEvents)
+
+    w := serve(r, http.MethodPost, path+"/join", alice.ID, nil)
+    if w.Code != http.StatusAccepted {
+        t.Fatalf("join: status = %d, body %s", w.Code, w.Body)
+    }
+    var req JoinRequestResponse
+    json.Unmarshal(w.Body.Bytes(), &req)
+    select {
+    case ev := <-hostEvents.Events:
+        if ev.Type != realtime.EventLobby {
+            t.Errorf("host got %+v, want a lobby event", ev)
+        }
+    default:
+        t.Error("the host was not told someone is waiting")
+    }
+    serve(r, http.MethodPost, path+"/join", bob.ID, nil)
+
+    if w := serve(r, http.MethodGet, path+"/lobby", alice.ID, nil); w.Code != http.StatusForbidden {
+        t.Errorf("lobby listed for a guest: status = %d", w.Code)
+    }
+    var lobby []JoinRequestResponse
+    w = serve(r, http.MethodGet, path+"/lobby", host.ID, nil)
+    json.Unmarshal(w.Body.Bytes(), &lobby)
+    if len(lobby) != 2 {
+        t.Fatalf("lobby = %s, want two requests", w.Body)
+    }
+
+    aliceEvents := realtime.Subscribe(room.ID, alice.ID, true)
+    defer realtime.Unsubscribe(aliceEvents)
+    w = serve(r, http.MethodPost, fmt.Sprintf("%s/lobby/%d/admit", path, req.ID), host.ID, nil)
+    if w.Code != http.StatusOK {
+        t.Fatalf("admit: status = %d, body %s", w.Code, w.Body)
+    }
+    select {
+    case ev := <-aliceEvents.Events:
+        if ev.Type != realtime.EventAdmitted || !ev.Close {
+            t.Errorf("alice got %+v, want a closing admitted event", ev)
+        }
+    default:
+        t.Error("alice was not told about being admitted")
+    }
+    if w := serve(r, http.MethodPost, fmt.Sprintf("%s/lobby/%d/admit", path, req.ID), host.ID, nil); w.Code != http.StatusNotFound {
+        t.Errorf("admitting twice: status = %d, want 404", w.Code)
+    }
+
+    if w := serve(r, http.MethodPost, path+"/lobby/deny", host.ID, map[string]bool{"all": true}); w.Code != http.StatusOK {
+        t.Fatalf("deny: status = %d, body %s", w.Code, w.Body)
+    }
+    var mine JoinRequestResponse
+    w = serve(r, http.MethodGet, path+"/join-request", bob.ID, nil)
+    json.Unmarshal(w.Body.Bytes(), &mine)
+    if mine.Status != model.JoinDenied {
+        t.Errorf("bob's request = %s, want denied", w.Body)
+    }
+    w = serve(r, http.MethodPost, path+"/join", bob.ID, nil)
+    if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+        t.Errorf("asking again right after a denial: status = %d, headers %v", w.Code, w.Header())
+    }
+
+    // Participants skip the lobby when they come back.
+    if w := serve(r, http.MethodPost, path+"/join", alice.ID, nil); w.Code != http.StatusOK {
+        t.Errorf("admitted user rejoining: status = %d, want 200", w.Code)
+    }
+}
+
+func TestAdmitDeniesBannedUsers(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    mallory := newTestUser(t, conn, "mallory")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID, JoinPolicy: model.JoinApproval})
+
+    r := testRouter()
+    r.POST("/rooms/:code/join", JoinRoom)
+    r.POST("/rooms/:code/lobby/admit", AdmitJoinRequests)
+    path := "/rooms/" + room.Code
+
+    if w := serve(r, http.MethodPost, path+"/join", mallory.ID, nil); w.Code != http.StatusAccepted {
+        t.Fatalf("join: status = %d, body %s", w.Code, w.Body)
+    }
+    // A ban that raced the lobby, leaving the request pending.
+    if err := conn.Create(&model.RoomBan{RoomID: room.ID, UserID: mallory.ID, CreatedBy: host.ID}).Error; err != nil {
+        t.Fatal(err)
+    }
+
+    events := realtime.Subscribe(room.ID, mallory.ID, true)
+    defer realtime.Unsubscribe(events)
+    w := serve(r, http.MethodPost, path+"/lobby/admit", host.ID, map[string]bool{"all": true})
+    if w.Code != http.StatusOK {
+        t.Fatalf("admit: status = %d, body %s", w.Code, w.Body)
+    }
+    var resp struct {
+        Admitted []JoinRequestResponse `json:"admitted"`
+        Denied   []JoinRequestResponse `json:"denied"`
+    }
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    if len(resp.Admitted) != 0 || len(resp.Denied) != 1 || resp.Denied[0].Status != model.JoinDenied {
+        t.Errorf("admit = %s, want mallory denied", w.Body)
+    }
+    select {
+    case ev := <-events.Events:
+        if ev.Type != realtime.EventDenied || !ev.Close {
+            t.Errorf("mallory got %+v, want a closing denied event", ev)
+        }
+    default:
+        t.Error("mallory was not told about being denied")
+    }
+}
+
+func TestLobbySelectionRejectsBadID(t *testing.T) {
+    r := testRouter()
+    r.POST("/lobby/:id/admit", func(c *gin.Context) {
+        if ids, ok := lobbySelection(c); ok {
+            c.JSON(http.StatusOK, ids)
+        }
+    })
+    if w := serve(r, http.MethodPost, "/lobby/abc/admit", 1, nil); w.Code != http.StatusBadRequest {
+        t.Errorf("status = %d, want 400", w.Code)
+    }
+    if w := serve(r, http.MethodPost, "/lobby/7/admit", 1, nil); w.Code != http.StatusOK || w.Body.String() != "[7]" {
+        t.Errorf("status = %d, body %s", w.Code, w.Body)
+    }
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/mail"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "gorm.io/gorm"
+    "log"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// magicLinkCooldown stops the endpoint being used to flood an inbox.
+const magicLinkCooldown = time.Minute
+
+// magicLinkSignup reports whether a magic link may create an account for
+// an unknown address.
+func magicLinkSignup() bool {
+    return config.Bool("MAGIC_LINK_SIGNUP", false)
+}
+
+func RequestMagicLink(c *gin.Context) {
+    var linkData MagicLinkRequest
+    if !bindJSON(c, &linkData) || mailThrottled(c) {
+        return
+    }
+    email := strings.ToLower(strings.TrimSpace(linkData.Email))
+
+    // Always answer the same way, and do the work off the request path, so
+    // neither the response nor its timing reveals which addresses have
+    // accounts.
+    queueMail(func() { sendMagicLink(email) })
+
+    c.JSON(http.StatusOK, gin.H{"message": "If that address can log in, a link has been sent"})
+}
+
+// sendMagicLink mails a login link to email if it belongs to an account or
+// signup is allowed, unless one was sent within magicLinkCooldown.
+func sendMagicLink(email string) {
+    recent, err := db.RecentEmailToken(db.DB, email, model.TokenMagicLink, magicLinkCooldown)
+    if err != nil {
+        log.Printf("Error checking recent magic links: %v", err)
+        return
+    }
+    if recent {
+        return
+    }
+
+    var user model.User
+    err = db.DB.Where("LOWER(email) = ?", email).First(&user).Error
+    if err != nil && err != gorm.ErrRecordNotFound {
+        log.Printf("Error looking up magic link address: %v", err)
+        return
+    }
+    if err == gorm.ErrRecordNotFound && !magicLinkSignup() {
+        return
+    }
+
+    ttl := config.Duration("MAGIC_LINK_TTL", 15*time.Minute)
+    token, err := db.CreateEmailToken(db.DB, email, model.TokenMagicLink, ttl)
+    if err != nil {
+        log.Printf("Error creating magic link: %v", err)
+        return
+    }
+
+    err = mail.SendTemplate(model.TokenMagicLink, email, map[string]interface{}{
+        "Link":      appLink("/magic-login", token),
+        "ExpiresIn": ttl.String(),
+    })
+    if err != nil {
+        log.Printf("Error sending magic link: %v", err)
+    }
+}
+
+func LoginMagicLink(c *gin.Context) {
+    var loginData MagicLinkLoginRequest
+    if !bindJSON(c, &loginData) {
+        return
+    }
+
+    token, err := db.ConsumeUserToken(db.DB, loginData.Token, model.TokenMagicLink)
+    if err != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Login link is invalid or expired"})
+        return
+    }
+
+    // The account is looked up now rather than when the link was sent, in
+    // case it was registered in between.
+    var user model.User
+    err = db.DB.Where("LOWER(email) = ?", token.Email).First(&user).Error
+    switch {
+    case err == gorm.ErrRecordNotFound && magicLinkSignup():
+        user, err = db.CreatePasswordlessUser(db.DB, token.Email)
+        if err != nil {
+            log.Printf("Error creating account from magic link: %v", err)
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+            return
+        }
+    case err == gorm.ErrRecordNotFound:
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Login link is invalid or expired"})
+        return
+    case err != nil:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+        return
+    }
+
+    // Following the link proves the user owns the address.
+    if !user.EmailVerified {
+        if err := db.DB.Model(&user).Update("email_verified", true).Error; err != nil {
+            log.Printf("Error verifying email of user %d: %v", user.ID, err)
+        }
+    }
+
+    if user.TOTPEnabled {
+        writeTwoFactorChallenge(c, user)
+        return
+    }
+
+    writeLoginToken(c, user)
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+    "github.com/spacelord16/Videoparty/internal/model"
+    "net/http"
+    "net/url"
+    "regexp"
+    "testing"
+)
+
+var magicLinkPattern = regexp.MustCompile(`/magic-login\?token=([^\s"<]+)`)
+
+// magicLinkToken returns the token of the magic link in a mail body.
+func magicLinkToken(t *testing.T, body string) string {
+    t.Helper()
+    m := magicLinkPattern.FindStringSubmatch(body)
+    if m == nil {
+        t.Fatalf("no magic link in %q", body)
+    }
+    token, err := url.QueryUnescape(m[1])
+    if err != nil {
+        t.Fatal(err)
+    }
+    return token
+}
+
+func TestSendMagicLink(t *testing.T) {
+    conn := testDB(t)
+    outbox := withMemoryMailer(t)
+    newTestUser(t, conn, "alice")
+
+    sendMagicLink("alice@example.com")
+    sent := outbox.Messages()
+    if len(sent) != 1 || sent[0].To != "alice@example.com" {
+        t.Fatalf("sent = %+v, want one link to alice", sent)
+    }
+    magicLinkToken(t, sent[0].Body)
+
+    sendMagicLink("alice@example.com")
+    if n := len(outbox.Messages()); n != 1 {
+        t.Errorf("sent %d messages within the cooldown, want 1", n)
+    }
+
+    t.Setenv("MAGIC_LINK_SIGNUP", "false")
+    sendMagicLink("nobody@example.com")
+    if n := len(outbox.Messages()); n != 1 {
+        t.Error("a link was sent to an unknown address with signup off")
+    }
+    t.Setenv("MAGIC_LINK_SIGNUP", "true")
+    sendMagicLink("nobody@example.com")
+    if n := len(outbox.Messages()); n != 2 {
+        t.Error("no link was sent to a new address with signup on")
+    }
+}
+
+func TestRequestMagicLinkIsThrottledPerAddress(t *testing.T) {
+    testDB(t)
+    withMemoryMailer(t)
+    t.Setenv("MAIL_IP_THRESHOLD", "2")
+
+    r := testRouter()
+    r.POST("/magic-link", RequestMagicLink)
+    for i := 1; i <= 2; i++ {
+        if w := serve(r, http.MethodPost, "/magic-link", 0, MagicLinkRequest{Email: "nobody@example.com"}); w.Code != http.StatusOK {
+            t.Fatalf("request %d: status = %d", i, w.Code)
+        }
+    }
+    w := serve(r, http.MethodPost, "/magic-link", 0, MagicLinkRequest{Email: "nobody@example.com"})
+    if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+        t.Errorf("request past the limit: status = %d, headers %v", w.Code, w.Header())
+    }
+}
+
+func TestLoginMagicLink(t *testing.T) {
+    conn := testDB(t)
+    outbox := withMemoryMailer(t)
+    user := newTestUser(t, conn, "alice")
+    conn.Model(&user).Update("email_verified", false)
+
+    r := testRouter()
+    r.POST("/magic-login", LoginMagicLink)
+
+    sendMagicLink("alice@example.com")
+    token := magicLinkToken(t, outbox.Messages()[0].Body)
+
+    w := serve(r, http.MethodPost, "/magic-login", 0, MagicLinkLoginRequest{Token: token})
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    conn.First(&user, user.ID)
+    if !user.EmailVerified {
+        t.Error("following the link did not verify the address")
+    }
+
+    w = serve(r, http.MethodPost, "/magic-login", 0, MagicLinkLoginRequest{Token: token})
+    if w.Code != http.StatusUnauthorized {
+        t.Errorf("reused link: status = %d, want 401", w.Code)
+    }
+}
+
+func TestLoginMagicLinkSignup(t *testing.T) {
+    conn := testDB(t)
+    outbox := withMemoryMailer(t)
+    t.Setenv("MAGIC_LINK_SIGNUP", "true")
+
+    r := testRouter()
+    r.POST("/magic-login", LoginMagicLink)
+
+    sendMagicLink("new.user@example.com")
+    token := magicLinkToken(t, outbox.Messages()[0].Body)
+
+    // Signup being turned off between sending and following the link wins.
+    t.Setenv("MAGIC_LINK_SIGNUP", "false")
+    if w := serve(r, http.MethodPost, "/magic-login", 0, MagicLinkLoginRequest{Token: token}); w.Code != http.StatusUnauthorized {
+        t.Fatalf("signup off: status = %d, want 401", w.Code)
+    }
+
+    t.Setenv("MAGIC_LINK_SIGNUP", "true")
+    sendMagicLink("other@example.com")
+    token = magicLinkToken(t, outbox.Messages()[1].Body)
+    if w := serve(r, http.MethodPost, "/magic-login", 0, MagicLinkLoginRequest{Token: token}); w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    var user model.User
+    if err := conn.Where("email = ?", "other@example.com").First(&user).Error; err != nil {
+        t.Fatalf("no account was created: %v", err)
+    }
+    if user.Password != "" || !user.EmailVerified || !model.ValidUsername(user.Username) {
+        t.Errorf("new user = %+v", user)
+    }
+}
+
+func TestLoginMagicLinkAsksForSecondFactor(t *testing.T) {
+    conn := testDB(t)
+    outbox := withMemoryMailer(t)
+    user := newTestUser(t, conn, "alice")
+    enableTOTP(t, conn, user)
+
+    r := testRouter()
+    r.POST("/magic-login", LoginMagicLink)
+
+    sendMagicLink("alice@example.com")
+    token := magicLinkToken(t, outbox.Messages()[0].Body)
+    w := serve(r, http.MethodPost, "/magic-login", 0, MagicLinkLoginRequest{Token: token})
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    if body := w.Body.String(); !regexp.MustCompile(`"two_factor_required":\s*true`).MatchString(body) {
+        t.Errorf("response %s is not a 2FA challenge", body)
+    }
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "log"
+    "math"
+    "net/http"
+    "strconv"
+    "sync"
+)
+
+// mailJobs holds mail work, such as looking up an address and sending it a
+// link, waiting for one of the mail workers. Work beyond its capacity is
+// dropped rather than queued, so a flood of requests can neither pile up
+// goroutines nor swamp the mail relay.
+var (
+    mailJobs    chan func()
+    mailWorkers sync.Once
+)
+
+// queueMail runs job off the request path on one of MAIL_WORKERS workers,
+// holding up to MAIL_QUEUE_SIZE jobs. It reports whether job was accepted.
+func queueMail(job func()) bool {
+    mailWorkers.Do(func() {
+        mailJobs = make(chan func(), config.Int("MAIL_QUEUE_SIZE", 100))
+        workers := config.Int("MAIL_WORKERS", 2)
+        if workers < 1 {
+            workers = 1
+        }
+        for i := 0; i < workers; i++ {
+            go func() {
+                for job := range mailJobs {
+                    job()
+                }
+            }()
+        }
+    })
+
+    select {
+    case mailJobs <- job:
+        return true
+    default:
+        log.Println("Mail queue is full, dropping a mail job")
+        return false
+    }
+}
+
+// mailThrottled counts a request for mail from this client and answers 429
+// and returns true if the client has asked for too much mail lately. The
+// limit is per address, so answering 429 reveals nothing about accounts.
+func mailThrottled(c *gin.Context) bool {
+    wait, err := db.ThrottleMail(db.DB, c.ClientIP())
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send mail"})
+        return true
+    }
+    if wait > 0 {
+        retryAfter := int(math.Ceil(wait.Seconds()))
+        c.Header("Retry-After", strconv.Itoa(retryAfter))
+        c.JSON(http.StatusTooManyRequests, gin.H{
+            "error":       "Too many requests, try again later",
+            "retry_after": retryAfter,
+        })
+        return true
+    }
+    return false
+}
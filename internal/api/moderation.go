@@ -0,0 +1,235 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/realtime"
+    "gorm.io/gorm"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+// Realtime events sent to a user removed from a room.
+const (
+    eventKicked = "kicked"
+    eventBanned = "banned"
+)
+
+// BanResponse is one entry of a room's ban list.
+type BanResponse struct {
+    User      UserSummary `json:"user"`
+    Reason    string      `json:"reason"`
+    ExpiresAt *time.Time  `json:"expires_at"`
+    CreatedBy uint        `json:"created_by"`
+    CreatedAt time.Time   `json:"created_at"`
+}
+
+func newBanResponse(ban model.RoomBan) BanResponse {
+    return BanResponse{
+        User:      newUserSummary(ban.User),
+        Reason:    ban.Reason,
+        ExpiresAt: ban.ExpiresAt,
+        CreatedBy: ban.CreatedBy,
+        CreatedAt: ban.CreatedAt,
+    }
+}
+
+// moderatedRoom loads the room named by the :code parameter and checks that
+// the caller may moderate it, as its host or a moderator. On failure it
+// writes the error response and returns false.
+func moderatedRoom(c *gin.Context) (model.Room, uint, bool) {
+    room, err := db.FindRoom(db.DB, c.Param("code"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+        return room, 0, false
+    }
+
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return room, 0, false
+    }
+
+    allowed, err := db.CanControl(db.DB, &room, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load room"})
+        return room, 0, false
+    }
+    if !allowed {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Only room host or moderators can do this"})
+        return room, 0, false
+    }
+
+    return room, userID.(uint), true
+}
+
+// canModerate checks that actorID may remove targetID from room: nobody can
+// remove the host or themselves, and moderators can only remove viewers. On
+// failure it writes the error response and returns false.
+func canModerate(c *gin.Context, room *model.Room, actorID, targetID uint) bool {
+    if targetID == room.HostID || targetID == actorID {
+        c.JSON(http.StatusForbidden, gin.H{"error": "You can't remove this user"})
+        return false
+    }
+    if actorID == room.HostID {
+        return true
+    }
+
+    var target model.RoomParticipant
+    err := db.DB.Where("room_id = ? AND user_id = ?", room.ID, targetID).First(&target).Error
+    if err != nil && err != gorm.ErrRecordNotFound {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participant"})
+        return false
+    }
+    if err == nil && target.Role == model.RoleModerator {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Only the host can remove moderators"})
+        return false
+    }
+    return true
+}
+
+// parseUserID reads the :user_id parameter, writing a 400 on failure.
+func parseUserID(c *gin.Context) (uint, bool) {
+    id, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+    if err != nil || id == 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        return 0, false
+    }
+    return uint(id), true
+}
+
+// KickParticipant removes a participant from the room and closes their
+// connections. Unlike a ban, they may join again.
+func KickParticipant(c *gin.Context) {
+    room, actorID, ok := moderatedRoom(c)
+    if !ok {
+        return
+    }
+    targetID, ok := parseUserID(c)
+    if !ok || !canModerate(c, &room, actorID, targetID) {
+        return
+    }
+
+    if err := db.RemoveParticipant(db.DB, room.ID, targetID); err != nil {
+        if err == gorm.ErrRecordNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"error": "User is not in this room"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to kick participant"})
+        return
+    }
+
+    realtime.Disconnect(room.ID, targetID, eventKicked, gin.H{"room_code": room.Code})
+    c.Status(http.StatusNoContent)
+}
+
+// BanUser bans a user from the room, for a number of seconds or for good,
+// removing them if they are in it.
+func BanUser(c *gin.Context) {
+    room, actorID, ok := moderatedRoom(c)
+    if !ok {
+        return
+    }
+
+    var banData BanUserRequest
+    if !bindJSON(c, &banData) {
+        return
+    }
+    if !canModerate(c, &room, actorID, banData.UserID) {
+        return
+    }
+
+    ban := model.RoomBan{
+        RoomID:    room.ID,
+        UserID:    banData.UserID,
+        Reason:    banData.Reason,
+        CreatedBy: actorID,
+    }
+    if err := db.DB.First(&ban.User, banData.UserID).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+    if banData.Duration > 0 {
+        expiresAt := time.Now().Add(time.Duration(banData.Duration) * time.Second)
+        ban.ExpiresAt = &expiresAt
+    }
+
+    if err := db.BanUser(db.DB, &ban); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ban user"})
+        return
+    }
+
+    realtime.Disconnect(room.ID, ban.UserID, eventBanned, gin.H{
+        "room_code":  room.Code,
+        "reason":     ban.Reason,
+        "expires_at": ban.ExpiresAt,
+    })
+    c.JSON(http.StatusCreated, newBanResponse(ban))
+}
+
+// UnbanUser lifts a user's ban from the room. Moderators may lift bans made
+// by moderators, but only the host may lift the host's.
+func UnbanUser(c *gin.Context) {
+    room, actorID, ok := moderatedRoom(c)
+    if !ok {
+        return
+    }
+    targetID, ok := parseUserID(c)
+    if !ok {
+        return
+    }
+
+    if actorID != room.HostID {
+        ban, _, err := db.ActiveBan(db.DB, room.ID, targetID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unban user"})
+            return
+        }
+        if ban.CreatedBy == room.HostID {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Only the host can lift this ban"})
+            return
+        }
+    }
+
+    if err := db.Unban(db.DB, room.ID, targetID); err != nil {
+        if err == gorm.ErrRecordNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"error": "User is not banned"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unban user"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+// ListBans returns the bans of the room that are in force.
+func ListBans(c *gin.Context) {
+    room, _, ok := moderatedRoom(c)
+    if !ok {
+        return
+    }
+
+    bans, err := db.ListBans(db.DB, room.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list bans"})
+        return
+    }
+
+    resp := make([]BanResponse, 0, len(bans))
+    for _, ban := range bans {
+        resp = append(resp, newBanResponse(ban))
+    }
+    c.JSON(http.StatusOK, resp)
+}
+
+// writeBanned answers 403 for a user banned from the room.
+func writeBanned(c *gin.Context, ban model.RoomBan) {
+    c.JSON(http.StatusForbidden, gin.H{
+        "error":      "You are banned from this room",
+        "reason":     ban.Reason,
+        "expires_at": ban.ExpiresAt,
+    })
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/realtime"
+    "net/http"
+    "testing"
+)
+
+func moderationRouter() *gin.Engine {
+    r := testRouter()
+    r.POST("/rooms/:code/join", JoinRoom)
+    r.POST("/rooms/:code/participants/:user_id/kick", KickParticipant)
+    r.GET("/rooms/:code/bans", ListBans)
+    r.POST("/rooms/:code/bans", BanUser)
+    r.DELETE("/rooms/:code/bans/:user_id", UnbanUser)
+    return r
+}
+
+func TestKickParticipant(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    mod := newTestUser(t, conn, "mod")
+    other := newTestUser(t, conn, "othermod")
+    viewer := newTestUser(t, conn, "viewer")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID})
+    db.AddParticipant(conn, room.ID, mod.ID, model.RoleModerator)
+    db.AddParticipant(conn, room.ID, other.ID, model.RoleModerator)
+    db.AddParticipant(conn, room.ID, viewer.ID, model.RoleViewer)
+
+    r := moderationRouter()
+    kick := func(actor, target uint) int {
+        return serve(r, http.MethodPost, fmt.Sprintf("/rooms/%s/participants/%d/kick", room.Code, target), actor, nil).Code
+    }
+
+    tests := []struct {
+        name          string
+        actor, target uint
+        want          int
+    }{
+        {"viewer kicks", viewer.ID, mod.ID, http.StatusForbidden},
+        {"moderator kicks host", mod.ID, host.ID, http.StatusForbidden},
+        {"moderator kicks self", mod.ID, mod.ID, http.StatusForbidden},
+        {"moderator kicks moderator", mod.ID, other.ID, http.StatusForbidden},
+        {"kicking a stranger", host.ID, 9999, http.StatusNotFound},
+    }
+    for _, tt := range tests {
+        if got := kick(tt.actor, tt.target); got != tt.want {
+            t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+        }
+    }
+    if w := serve(r, http.MethodPost, "/rooms/"+room.Code+"/participants/x/kick", host.ID, nil); w.Code != http.StatusBadRequest {
+        t.Errorf("bad user ID: status = %d, want 400", w.Code)
+    }
+
+    events := realtime.Subscribe(room.ID, viewer.ID, false)
+    defer realtime.Unsubscribe(events)
+    if got := kick(mod.ID, viewer.ID); got != http.StatusNoContent {
+        t.Fatalf("moderator kicks viewer: status = %d", got)
+    }
+    select {
+    case ev := <-events.Events:
+        if ev.Type != eventKicked || !ev.Close {
+            t.Errorf("viewer got %+v, want a closing kick", ev)
+        }
+    default:
+        t.Error("the kicked viewer's connections were not closed")
+    }
+    if got := kick(host.ID, other.ID); got != http.StatusNoContent {
+        t.Errorf("host kicks moderator: status = %d", got)
+    }
+
+    if w := serve(r, http.MethodPost, "/rooms/"+room.Code+"/join", viewer.ID, nil); w.Code != http.StatusOK {
+        t.Errorf("rejoining after a kick: status = %d", w.Code)
+    }
+}
+
+func TestBanUser(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    viewer := newTestUser(t, conn, "viewer")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID})
+    db.AddParticipant(conn, room.ID, viewer.ID, model.RoleViewer)
+
+    r := moderationRouter()
+    path := "/rooms/" + room.Code
+
+    if w := serve(r, http.MethodPost, path+"/bans", host.ID, gin.H{"user_id": viewer.ID, "duration": -1}); w.Code != http.StatusBadRequest {
+        t.Errorf("negative duration: status = %d, want 400", w.Code)
+    }
+    if w := serve(r, http.MethodPost, path+"/bans", host.ID, gin.H{"user_id": 9999}); w.Code != http.StatusNotFound {
+        t.Errorf("banning an unknown user: status = %d, want 404", w.Code)
+    }
+
+    w := serve(r, http.MethodPost, path+"/bans", host.ID, gin.H{"user_id": viewer.ID, "duration": 3600, "reason": "spoilers"})
+    if w.Code != http.StatusCreated {
+        t.Fatalf("ban: status = %d, body %s", w.Code, w.Body)
+    }
+    var ban BanResponse
+    json.Unmarshal(w.Body.Bytes(), &ban)
+    if ban.User.ID != viewer.ID || ban.ExpiresAt == nil || ban.Reason != "spoilers" {
+        t.Errorf("ban = %+v", ban)
+    }
+
+    w = serve(r, http.MethodPost, path+"/join", viewer.ID, nil)
+    var denied struct {
+        Reason string `json:"reason"`
+    }
+    json.Unmarshal(w.Body.Bytes(), &denied)
+    if w.Code != http.StatusForbidden || denied.Reason != "spoilers" {
+        t.Errorf("banned join: status = %d, body %s", w.Code, w.Body)
+    }
+
+    var bans []BanResponse
+    json.Unmarshal(serve(r, http.MethodGet, path+"/bans", host.ID, nil).Body.Bytes(), &bans)
+    if len(bans) != 1 {
+        t.Errorf("bans = %+v, want one", bans)
+    }
+
+    if w := serve(r, http.MethodDelete, fmt.Sprintf("%s/bans/%d", path, viewer.ID), host.ID, nil); w.Code != http.StatusNoContent {
+        t.Fatalf("unban: status = %d", w.Code)
+    }
+    if w := serve(r, http.MethodDelete, fmt.Sprintf("%s/bans/%d", path, viewer.ID), host.ID, nil); w.Code != http.StatusNotFound {
+        t.Errorf("unbanning twice: status = %d, want 404", w.Code)
+    }
+    if w := serve(r, http.MethodPost, path+"/join", viewer.ID, nil); w.Code != http.StatusOK {
+        t.Errorf("join after unban: status = %d", w.Code)
+    }
+}
+
+func TestUnbanUserByModerator(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    mod := newTestUser(t, conn, "mod")
+    other := newTestUser(t, conn, "othermod")
+    alice := newTestUser(t, conn, "alice")
+    bob := newTestUser(t, conn, "bob")
+    carol := newTestUser(t, conn, "carol")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID})
+    db.AddParticipant(conn, room.ID, mod.ID, model.RoleModerator)
+    db.AddParticipant(conn, room.ID, other.ID, model.RoleModerator)
+    for _, ban := range []model.RoomBan{
+        {RoomID: room.ID, UserID: alice.ID, CreatedBy: host.ID},
+        {RoomID: room.ID, UserID: bob.ID, CreatedBy: mod.ID},
+        {RoomID: room.ID, UserID: carol.ID, CreatedBy: other.ID},
+    } {
+        if err := db.BanUser(conn, &ban); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    r := moderationRouter()
+    unban := func(actor, target uint) int {
+        return serve(r, http.MethodDelete, fmt.Sprintf("/rooms/%s/bans/%d", room.Code, target), actor, nil).Code
+    }
+    tests := []struct {
+        name          string
+        actor, target uint
+        want          int
+    }{
+        {"moderator lifts the host's ban", mod.ID, alice.ID, http.StatusForbidden},
+        {"moderator lifts their own ban", mod.ID, bob.ID, http.StatusNoContent},
+        {"moderator lifts another moderator's ban", mod.ID, carol.ID, http.StatusNoContent},
+        {"host lifts their own ban", host.ID, alice.ID, http.StatusNoContent},
+    }
+    for _, tt := range tests {
+        if got := unban(tt.actor, tt.target); got != tt.want {
+            t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/golang-jwt/jwt/v5"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/oidc"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+)
+
+const oidcCookie = "videoparty_oidc"
+
+// oidcCookieTTL bounds how long a user may take to log in at the provider.
+const oidcCookieTTL = 10 * time.Minute
+
+// The state, nonce and PKCE verifier travel in a signed cookie so any
+// instance can handle the callback.
+func setOIDCCookie(c *gin.Context, r oidc.AuthRequest) error {
+    token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+        "state":    r.State,
+        "nonce":    r.Nonce,
+        "verifier": r.Verifier,
+        "exp":      time.Now().Add(oidcCookieTTL).Unix(),
+    })
+    value, err := token.SignedString(config.JWTSecret())
+    if err != nil {
+        return err
+    }
+    c.SetSameSite(http.SameSiteLaxMode)
+    c.SetCookie(oidcCookie, value, int(oidcCookieTTL.Seconds()), "/api/oidc", "", c.Request.TLS != nil, true)
+    return nil
+}
+
+func readOIDCCookie(c *gin.Context) (oidc.AuthRequest, bool) {
+    var r oidc.AuthRequest
+    value, err := c.Cookie(oidcCookie)
+    if err != nil {
+        return r, false
+    }
+    claims := jwt.MapClaims{}
+    _, err = jwt.ParseWithClaims(value, claims, func(token *jwt.Token) (interface{}, error) {
+        return config.JWTSecret(), nil
+    }, jwt.WithValidMethods([]string{"HS256"}))
+    if err != nil {
+        return r, false
+    }
+    r.State, _ = claims["state"].(string)
+    r.Nonce, _ = claims["nonce"].(string)
+    r.Verifier, _ = claims["verifier"].(string)
+    return r, r.State != "" && r.Nonce != "" && r.Verifier != ""
+}
+
+// oidcRedirect sends the browser back to the frontend. Results go in the
+// fragment so they never reach server logs.
+func oidcRedirect(c *gin.Context, values url.Values) {
+    target := config.String("APP_URL", "http://localhost:3000") + "/oidc/callback#" + values.Encode()
+    c.Redirect(http.StatusFound, target)
+}
+
+func OIDCLogin(c *gin.Context) {
+    if oidc.Default == nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+        return
+    }
+
+    authReq, err := oidc.NewAuthRequest()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+        return
+    }
+
+    authURL, err := oidc.Default.AuthCodeURL(c.Request.Context(), authReq)
+    if err != nil {
+        log.Printf("Error starting OIDC login: %v", err)
+        c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+        return
+    }
+
+    if err := setOIDCCookie(c, authReq); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+        return
+    }
+
+    c.Redirect(http.StatusFound, authURL)
+}
+
+func OIDCCallback(c *gin.Context) {
+    if oidc.Default == nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+        return
+    }
+
+    authReq, ok := readOIDCCookie(c)
+    // The cookie is single-use whatever the outcome.
+    c.SetCookie(oidcCookie, "", -1, "/api/oidc", "", c.Request.TLS != nil, true)
+    if !ok || c.Query("state") != authReq.State {
+        oidcRedirect(c, url.Values{"error": {"invalid_state"}})
+        return
+    }
+
+    if providerErr := c.Query("error"); providerErr != "" {
+        oidcRedirect(c, url.Values{"error": {providerErr}})
+        return
+    }
+
+    claims, err := oidc.Default.Exchange(c.Request.Context(), c.Query("code"), authReq)
+    if err != nil {
+        log.Printf("Error completing OIDC login: %v", err)
+        oidcRedirect(c, url.Values{"error": {"login_failed"}})
+        return
+    }
+
+    user, err := db.FindOrLinkUser(db.DB, db.ExternalAccount{
+        Issuer:        claims.Issuer,
+        Subject:       claims.Subject,
+        Email:         claims.Email,
+        EmailVerified: claims.EmailVerified,
+        Username:      claims.PreferredUsername,
+    })
+    if err != nil {
+        log.Printf("Error linking OIDC account: %v", err)
+        oidcRedirect(c, url.Values{"error": {"login_failed"}})
+        return
+    }
+
+    // The provider stands in for the password only; a 2FA account still
+    // has to enter its second factor.
+    if user.TOTPEnabled {
+        challenge, err := newChallenge(user)
+        if err != nil {
+            oidcRedirect(c, url.Values{"error": {"login_failed"}})
+            return
+        }
+        oidcRedirect(c, url.Values{
+            "two_factor_required": {"true"},
+            "challenge_token":     {challenge},
+            "expires_in":          {strconv.Itoa(int(challengeTTL.Seconds()))},
+        })
+        return
+    }
+
+    tokenString, err := issueToken(c, user)
+    if err != nil {
+        oidcRedirect(c, url.Values{"error": {"login_failed"}})
+        return
+    }
+
+    oidcRedirect(c, url.Values{"token": {tokenString}})
+}
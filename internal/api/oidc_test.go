@@ -0,0 +1,234 @@
+package api
+
+import (
+    "crypto/rand"
+    "crypto/rsa"
+    "encoding/base64"
+    "encoding/json"
+    "github.com/gin-gonic/gin"
+    "github.com/golang-jwt/jwt/v5"
+    "github.com/spacelord16/Videoparty/internal/oidc"
+    "math/big"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// testIdP is an identity provider that hands out an ID token with whatever
+// claims the test set, for any code.
+type testIdP struct {
+    *httptest.Server
+    key *rsa.PrivateKey
+
+    mu     sync.Mutex
+    claims jwt.MapClaims
+}
+
+// withOIDCProvider points oidc.Default at a test provider.
+func withOIDCProvider(t *testing.T) *testIdP {
+    t.Helper()
+    key, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil {
+        t.Fatal(err)
+    }
+    idp := &testIdP{key: key}
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+        json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+            "kty": "RSA",
+            "kid": "key-1",
+            "n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+            "e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+        }}})
+    })
+    mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+        idp.mu.Lock()
+        token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims)
+        idp.mu.Unlock()
+        token.Header["kid"] = "key-1"
+        raw, err := token.SignedString(key)
+        if err != nil {
+            w.WriteHeader(http.StatusInternalServerError)
+            return
+        }
+        json.NewEncoder(w).Encode(map[string]string{"id_token": raw})
+    })
+    mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+        json.NewEncoder(w).Encode(map[string]string{
+            "issuer":                 idp.URL,
+            "authorization_endpoint": idp.URL + "/authorize",
+            "token_endpoint":         idp.URL + "/token",
+            "jwks_uri":               idp.URL + "/jwks",
+        })
+    })
+    idp.Server = httptest.NewServer(mux)
+    t.Cleanup(idp.Close)
+
+    old := oidc.Default
+    oidc.Default = &oidc.Provider{
+        Issuer:      idp.URL,
+        ClientID:    "videoparty",
+        RedirectURL: "http://localhost:8080/api/oidc/callback",
+        Scopes:      []string{"openid"},
+        HTTPClient:  idp.Client(),
+    }
+    t.Cleanup(func() { oidc.Default = old })
+    return idp
+}
+
+// login makes the provider's next ID token name subject, with a verified
+// email address if email is set.
+func (idp *testIdP) login(nonce, subject, email string) {
+    idp.mu.Lock()
+    defer idp.mu.Unlock()
+    idp.claims = jwt.MapClaims{
+        "iss":            idp.URL,
+        "aud":            "videoparty",
+        "sub":            subject,
+        "exp":            time.Now().Add(time.Hour).Unix(),
+        "nonce":          nonce,
+        "email":          email,
+        "email_verified": email != "",
+    }
+}
+
+func oidcRouter() *gin.Engine {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.GET("/api/oidc/login", OIDCLogin)
+    r.GET("/api/oidc/callback", OIDCCallback)
+    return r
+}
+
+// startOIDCLogin runs the login redirect and returns the state sent to the
+// provider and the cookie that carries it.
+func startOIDCLogin(t *testing.T, r *gin.Engine) (string, *http.Cookie) {
+    t.Helper()
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/oidc/login", nil))
+    if w.Code != http.StatusFound {
+        t.Fatalf("login status = %d, body %s", w.Code, w.Body)
+    }
+    location, err := url.Parse(w.Header().Get("Location"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, cookie := range w.Result().Cookies() {
+        if cookie.Name == oidcCookie {
+            if !cookie.HttpOnly {
+                t.Error("OIDC cookie is readable from scripts")
+            }
+            return location.Query().Get("state"), cookie
+        }
+    }
+    t.Fatal("login did not set the OIDC cookie")
+    return "", nil
+}
+
+// callbackFragment runs the callback and returns what it sends the
+// frontend in the redirect fragment.
+func callbackFragment(t *testing.T, r *gin.Engine, query string, cookie *http.Cookie) url.Values {
+    t.Helper()
+    req := httptest.NewRequest(http.MethodGet, "/api/oidc/callback?"+query, nil)
+    if cookie != nil {
+        req.AddCookie(cookie)
+    }
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    if w.Code != http.StatusFound {
+        t.Fatalf("callback status = %d, body %s", w.Code, w.Body)
+    }
+    _, fragment, ok := strings.Cut(w.Header().Get("Location"), "#")
+    if !ok {
+        t.Fatalf("callback redirect has no fragment: %s", w.Header().Get("Location"))
+    }
+    values, err := url.ParseQuery(fragment)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return values
+}
+
+// callbackError runs the callback and returns the error it sends the
+// frontend.
+func callbackError(t *testing.T, r *gin.Engine, query string, cookie *http.Cookie) string {
+    t.Helper()
+    return callbackFragment(t, r, query, cookie).Get("error")
+}
+
+func TestOIDCLoginRedirectsToProvider(t *testing.T) {
+    srv := withOIDCProvider(t)
+    r := oidcRouter()
+
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/oidc/login", nil))
+    if w.Code != http.StatusFound {
+        t.Fatalf("status = %d", w.Code)
+    }
+    location := w.Header().Get("Location")
+    if !strings.HasPrefix(location, srv.URL+"/authorize?") {
+        t.Fatalf("Location = %s", location)
+    }
+
+    state, cookie := startOIDCLogin(t, r)
+    authReq, ok := readOIDCCookieValue(t, cookie)
+    if !ok || authReq.State != state {
+        t.Fatalf("cookie does not carry the state sent to the provider")
+    }
+}
+
+func TestOIDCCallbackChecksState(t *testing.T) {
+    withOIDCProvider(t)
+    r := oidcRouter()
+
+    state, cookie := startOIDCLogin(t, r)
+    tampered := *cookie
+    tampered.Value += "x"
+
+    tests := []struct {
+        name   string
+        query  string
+        cookie *http.Cookie
+        want   string
+    }{
+        {"no cookie", "state=" + url.QueryEscape(state) + "&code=c", nil, "invalid_state"},
+        {"wrong state", "state=other&code=c", cookie, "invalid_state"},
+        {"forged cookie", "state=" + url.QueryEscape(state) + "&code=c", &tampered, "invalid_state"},
+        {"provider error", "state=" + url.QueryEscape(state) + "&error=access_denied", cookie, "access_denied"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := callbackError(t, r, tt.query, tt.cookie); got != tt.want {
+                t.Errorf("error = %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestOIDCNotConfigured(t *testing.T) {
+    old := oidc.Default
+    oidc.Default = nil
+    t.Cleanup(func() { oidc.Default = old })
+
+    r := oidcRouter()
+    for _, path := range []string{"/api/oidc/login", "/api/oidc/callback"} {
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+        if w.Code != http.StatusNotFound {
+            t.Errorf("%s: status = %d, want 404", path, w.Code)
+        }
+    }
+}
+
+func readOIDCCookieValue(t *testing.T, cookie *http.Cookie) (oidc.AuthRequest, bool) {
+    t.Helper()
+    c, _ := gin.CreateTestContext(httptest.NewRecorder())
+    c.Request = httptest.NewRequest(http.MethodGet, "/api/oidc/callback", nil)
+    c.Request.AddCookie(cookie)
+    return readOIDCCookie(c)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+    "fmt"
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/password"
+    "log"
+    "net/http"
+    "time"
+)
+
+func DeleteAccount(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    var deleteData DeleteAccountRequest
+    if !bindJSON(c, &deleteData, true) {
+        return
+    }
+
+    var user model.User
+    if err := db.DB.First(&user, userID).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+
+    if user.Password != "" {
+        ok, _, err := password.Verify(user.Password, deleteData.Password)
+        if err != nil || !ok {
+            writeFieldError(c, "password", "invalid", "does not match your current password")
+            return
+        }
+    }
+
+    if err := db.DeleteUser(db.DB, user.ID); err != nil {
+        log.Printf("Error deleting user %d: %v", user.ID, err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+// participationExport is one room the user joined.
+type participationExport struct {
+    RoomID     uint      `json:"room_id"`
+    RoomCode   string    `json:"room_code"`
+    RoomName   string    `json:"room_name"`
+    Role       string    `json:"role"`
+    JoinedAt   time.Time `json:"joined_at"`
+    LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type identityExport struct {
+    Issuer    string    `json:"issuer"`
+    Subject   string    `json:"subject"`
+    Email     string    `json:"email"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+func ExportAccount(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    var user model.User
+    if err := db.DB.First(&user, userID).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+
+    var hosted []model.Room
+    if err := db.DB.Where("host_id = ?", user.ID).Order("created_at").Find(&hosted).Error; err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+        return
+    }
+    hostedRooms := make([]RoomResponse, 0, len(hosted))
+    for _, room := range hosted {
+        room.Host = user
+        hostedRooms = append(hostedRooms, newRoomResponse(room))
+    }
+
+    participation := []participationExport{}
+    err := db.DB.Table("room_participants").
+        Select("rooms.id AS room_id, rooms.code AS room_code, rooms.name AS room_name, room_participants.role, room_participants.joined_at, room_participants.last_seen_at").
+        Joins("JOIN rooms ON rooms.id = room_participants.room_id").
+        Where("room_participants.user_id = ?", user.ID).
+        Order("room_participants.joined_at").
+        Scan(&participation).Error
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+        return
+    }
+
+    invites := []model.RoomInvite{}
+    if err := db.DB.Where("created_by = ?", user.ID).Order("created_at").Find(&invites).Error; err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+        return
+    }
+
+    tokens, err := db.ListAPITokens(db.DB, user.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+        return
+    }
+
+    sessions := []model.Session{}
+    if err := db.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&sessions).Error; err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+        return
+    }
+
+    var identities []model.UserIdentity
+    if err := db.DB.Where("user_id = ?", user.ID).Find(&identities).Error; err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+        return
+    }
+    identityList := make([]identityExport, 0, len(identities))
+    for _, identity := range identities {
+        identityList = append(identityList, identityExport{
+            Issuer:    identity.Issuer,
+            Subject:   identity.Subject,
+            Email:     identity.Email,
+            CreatedAt: identity.CreatedAt,
+        })
+    }
+
+    c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="videoparty-export-%d.json"`, user.ID))
+    c.JSON(http.StatusOK, gin.H{
+        "exported_at":   time.Now(),
+        "profile":       newUserResponse(user),
+        "hosted_rooms":  hostedRooms,
+        "participation": participation,
+        "invites":       invites,
+        "api_tokens":    tokens,
+        "identities":    identityList,
+        "sessions":      sessions,
+    })
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/password"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestDeleteAccountNeedsPassword(t *testing.T) {
+    conn := testDB(t)
+    user := newTestUser(t, conn, "alice")
+    hash, err := password.Hash("a long and unusual passphrase 42")
+    if err != nil {
+        t.Fatal(err)
+    }
+    conn.Model(&user).Update("password", hash)
+
+    r := testRouter()
+    r.DELETE("/account", DeleteAccount)
+
+    w := serve(r, http.MethodDelete, "/account", user.ID, gin.H{"password": "wrong"})
+    if w.Code != http.StatusBadRequest {
+        t.Fatalf("wrong password: status = %d, body %s", w.Code, w.Body)
+    }
+    var count int64
+    conn.Model(&model.User{}).Where("id = ?", user.ID).Count(&count)
+    if count != 1 {
+        t.Fatal("the account was deleted with the wrong password")
+    }
+
+    w = serve(r, http.MethodDelete, "/account", user.ID, gin.H{"password": "a long and unusual passphrase 42"})
+    if w.Code != http.StatusNoContent {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    conn.Model(&model.User{}).Where("id = ?", user.ID).Count(&count)
+    if count != 0 {
+        t.Error("the account was not deleted")
+    }
+}
+
+func TestExportAccountLeavesOutSecrets(t *testing.T) {
+    conn := testDB(t)
+    user := newTestUser(t, conn, "alice")
+    conn.Model(&user).Updates(map[string]interface{}{"password": "secret-password-hash", "totp_secret": "SECRETTOTPSEED"})
+    newTestRoom(t, conn, model.Room{HostID: user.ID, PasswordHash: "secret-room-password"})
+    for _, v := range []interface{}{
+        &model.Session{UserID: user.ID, JTI: "secret-session-jti", ExpiresAt: time.Now().Add(time.Hour)},
+        &model.APIToken{UserID: user.ID, Name: "cli", TokenHash: "secret-token-hash"},
+    } {
+        if err := conn.Create(v).Error; err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    r := testRouter()
+    r.GET("/account/export", ExportAccount)
+    w := serve(r, http.MethodGet, "/account/export", user.ID, nil)
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    if !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment;") {
+        t.Errorf("Content-Disposition = %q", w.Header().Get("Content-Disposition"))
+    }
+    body := w.Body.String()
+    for _, secret := range []string{"secret-password-hash", "SECRETTOTPSEED", "secret-room-password", "secret-session-jti", "secret-token-hash"} {
+        if strings.Contains(body, secret) {
+            t.Errorf("export contains %q", secret)
+        }
+    }
+    if !strings.Contains(body, `"cli"`) || !strings.Contains(body, "Movie night") {
+        t.Errorf("export is missing the user's tokens or rooms: %s", body)
+    }
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/avatar"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "log"
+    "net/http"
+    "os"
+    "strconv"
+)
+
+func UploadAvatar(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    // Leave room for the multipart envelope around the file itself.
+    c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, avatar.MaxBytes()+64<<10)
+    file, err := c.FormFile("avatar")
+    if err != nil {
+        writeFieldError(c, "avatar", "required", "must be an uploaded image file")
+        return
+    }
+    if file.Size > avatar.MaxBytes() {
+        writeFieldError(c, "avatar", "max", avatar.ErrTooLarge.Error())
+        return
+    }
+
+    f, err := file.Open()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read avatar"})
+        return
+    }
+    defer f.Close()
+
+    key, err := avatar.Save(f)
+    switch err {
+    case nil:
+    case avatar.ErrTooLarge:
+        writeFieldError(c, "avatar", "max", err.Error())
+        return
+    case avatar.ErrUnsupportedType:
+        writeFieldError(c, "avatar", "type", err.Error())
+        return
+    case avatar.ErrBadDimensions:
+        writeFieldError(c, "avatar", "dimensions", err.Error())
+        return
+    default:
+        log.Printf("Error storing avatar: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store avatar"})
+        return
+    }
+
+    var user model.User
+    if err := db.DB.First(&user, userID).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+    user.AvatarKey = key
+    if err := db.DB.Model(&user).Update("avatar_key", key).Error; err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update avatar"})
+        return
+    }
+
+    c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+func DeleteAvatar(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    // Stored files are shared by everyone who uploaded the same image, so
+    // only the reference is removed.
+    if err := db.DB.Model(&model.User{}).Where("id = ?", userID).Update("avatar_key", "").Error; err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove avatar"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+func GetAvatar(c *gin.Context) {
+    size, err := strconv.Atoi(c.Param("size"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Avatar not found"})
+        return
+    }
+    path, ok := avatar.Path(c.Param("key"), size)
+    if !ok {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Avatar not found"})
+        return
+    }
+    if _, err := os.Stat(path); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Avatar not found"})
+        return
+    }
+
+    // The content behind a key never changes.
+    c.Header("Cache-Control", "public, max-age=31536000, immutable")
+    c.Header("Content-Type", "image/png")
+    c.File(path)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+    "bytes"
+    "github.com/spacelord16/Videoparty/internal/avatar"
+    "image"
+    "image/png"
+    "net/http"
+    "strings"
+    "testing"
+)
+
+func TestGetAvatar(t *testing.T) {
+    t.Setenv("AVATAR_DIR", t.TempDir())
+    var buf bytes.Buffer
+    if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 10, 10))); err != nil {
+        t.Fatal(err)
+    }
+    key, err := avatar.Save(&buf)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    r := testRouter()
+    r.GET("/api/avatars/:key/:size", GetAvatar)
+
+    w := serve(r, http.MethodGet, "/api/avatars/"+key+"/64", 0, nil)
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    if w.Header().Get("Content-Type") != "image/png" || !strings.Contains(w.Header().Get("Cache-Control"), "immutable") {
+        t.Errorf("headers = %v", w.Header())
+    }
+
+    for _, path := range []string{
+        "/api/avatars/" + key + "/65",
+        "/api/avatars/" + key + "/large",
+        "/api/avatars/" + strings.Repeat("0", 64) + "/64",
+        "/api/avatars/..%2F..%2Fetc/64",
+    } {
+        if w := serve(r, http.MethodGet, path, 0, nil); w.Code != http.StatusNotFound {
+            t.Errorf("%s: status = %d, want 404", path, w.Code)
+        }
+    }
+}
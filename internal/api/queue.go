@@ -0,0 +1,115 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/realtime"
+    "gorm.io/gorm"
+    "log"
+    "net/http"
+    "strconv"
+)
+
+// ListQueue returns the videos lined up in the room, in play order.
+func ListQueue(c *gin.Context) {
+    room, err := db.FindRoom(db.DB, c.Param("code"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+        return
+    }
+
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    allowed, err := canViewRoom(&room, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load room"})
+        return
+    }
+    if !allowed {
+        c.JSON(http.StatusForbidden, gin.H{"error": "This room is private"})
+        return
+    }
+
+    items, err := db.ListQueue(db.DB, room.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load queue"})
+        return
+    }
+
+    c.JSON(http.StatusOK, items)
+}
+
+// AddToQueue appends a video to the room's queue, which holds up to
+// ROOM_QUEUE_LIMIT videos.
+func AddToQueue(c *gin.Context) {
+    room, userID, ok := moderatedRoom(c)
+    if !ok || roomClosed(c, &room) {
+        return
+    }
+
+    var itemData QueueItemRequest
+    if !bindJSON(c, &itemData) {
+        return
+    }
+
+    item := model.QueueItem{
+        RoomID:   room.ID,
+        VideoURL: itemData.VideoURL,
+        Title:    itemData.Title,
+        Platform: model.DetectPlatform(itemData.VideoURL),
+        AddedBy:  userID,
+    }
+    if err := db.AddToQueue(db.DB, &item, config.Int("ROOM_QUEUE_LIMIT", 100)); err != nil {
+        if err == db.ErrQueueFull {
+            c.JSON(http.StatusConflict, gin.H{"error": "Queue is full"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to queue"})
+        return
+    }
+
+    broadcastQueue(&room)
+    c.JSON(http.StatusCreated, item)
+}
+
+// RemoveFromQueue takes a video off the room's queue.
+func RemoveFromQueue(c *gin.Context) {
+    room, _, ok := moderatedRoom(c)
+    if !ok {
+        return
+    }
+
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid queue item ID"})
+        return
+    }
+
+    if err := db.RemoveFromQueue(db.DB, room.ID, uint(id)); err != nil {
+        if err == gorm.ErrRecordNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Queue item not found"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove from queue"})
+        return
+    }
+
+    broadcastQueue(&room)
+    c.Status(http.StatusNoContent)
+}
+
+// broadcastQueue sends the room's current queue to its subscribers.
+func broadcastQueue(room *model.Room) {
+    items, err := db.ListQueue(db.DB, room.ID)
+    if err != nil {
+        log.Printf("Error loading queue of room %s: %v", room.Code, err)
+        return
+    }
+    realtime.Broadcast(room.ID, realtime.EventQueue, items)
+}
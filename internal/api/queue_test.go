@@ -0,0 +1,79 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/realtime"
+    "net/http"
+    "testing"
+)
+
+func TestQueue(t *testing.T) {
+    conn := testDB(t)
+    t.Setenv("ROOM_QUEUE_LIMIT", "1")
+    host := newTestUser(t, conn, "host")
+    viewer := newTestUser(t, conn, "viewer")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID})
+    db.AddParticipant(conn, room.ID, viewer.ID, model.RoleViewer)
+
+    r := testRouter()
+    r.GET("/rooms/:code/queue", ListQueue)
+    r.POST("/rooms/:code/queue", AddToQueue)
+    r.DELETE("/rooms/:code/queue/:id", RemoveFromQueue)
+    path := "/rooms/" + room.Code + "/queue"
+    video := gin.H{"video_url": "https://www.youtube.com/watch?v=dQw4w9WgXcQ", "title": "Classic"}
+
+    if w := serve(r, http.MethodPost, path, viewer.ID, video); w.Code != http.StatusForbidden {
+        t.Errorf("viewer adds: status = %d, want 403", w.Code)
+    }
+    if w := serve(r, http.MethodPost, path, host.ID, gin.H{"video_url": "not a url"}); w.Code != http.StatusBadRequest {
+        t.Errorf("bad URL: status = %d, want 400", w.Code)
+    }
+
+    sub := realtime.Subscribe(room.ID, viewer.ID, false)
+    defer realtime.Unsubscribe(sub)
+    w := serve(r, http.MethodPost, path, host.ID, video)
+    if w.Code != http.StatusCreated {
+        t.Fatalf("add: status = %d, body %s", w.Code, w.Body)
+    }
+    var item model.QueueItem
+    json.Unmarshal(w.Body.Bytes(), &item)
+    if item.Position != 1 || item.Platform != model.PlatformYouTube || item.AddedBy != host.ID {
+        t.Errorf("item = %+v", item)
+    }
+    select {
+    case ev := <-sub.Events:
+        if ev.Type != realtime.EventQueue {
+            t.Errorf("event = %+v, want %s", ev, realtime.EventQueue)
+        }
+    default:
+        t.Error("the new queue was not broadcast")
+    }
+
+    if w := serve(r, http.MethodPost, path, host.ID, video); w.Code != http.StatusConflict {
+        t.Errorf("full queue: status = %d, want 409", w.Code)
+    }
+
+    var items []model.QueueItem
+    json.Unmarshal(serve(r, http.MethodGet, path, viewer.ID, nil).Body.Bytes(), &items)
+    if len(items) != 1 || items[0].ID != item.ID {
+        t.Errorf("queue = %+v", items)
+    }
+
+    if w := serve(r, http.MethodDelete, path+"/x", host.ID, nil); w.Code != http.StatusBadRequest {
+        t.Errorf("bad item ID: status = %d, want 400", w.Code)
+    }
+    itemPath := fmt.Sprintf("%s/%d", path, item.ID)
+    if w := serve(r, http.MethodDelete, itemPath, viewer.ID, nil); w.Code != http.StatusForbidden {
+        t.Errorf("viewer removes: status = %d, want 403", w.Code)
+    }
+    if w := serve(r, http.MethodDelete, itemPath, host.ID, nil); w.Code != http.StatusNoContent {
+        t.Errorf("remove: status = %d", w.Code)
+    }
+    if w := serve(r, http.MethodDelete, itemPath, host.ID, nil); w.Code != http.StatusNotFound {
+        t.Errorf("remove twice: status = %d, want 404", w.Code)
+    }
+}
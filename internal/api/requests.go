@@ -0,0 +1,188 @@
+package api
+
+import "time"
+
+// Request bodies accepted by the API. Binding rules are checked by bindJSON;
+// custom rules such as "username" are registered in validation.go.
+
+type RegisterRequest struct {
+    Username string `json:"username" binding:"required,username"`
+    Email    string `json:"email" binding:"required,email,max=254"`
+    Password string `json:"password" binding:"required,max=256"`
+}
+
+// LoginRequest's Username may hold either the username or the email address.
+type LoginRequest struct {
+    Username string `json:"username" binding:"required,max=254"`
+    Password string `json:"password" binding:"required,max=256"`
+}
+
+// UpdateUserRequest changes only the fields that are present; an empty
+// display_name or bio clears it. A new password must come with the current
+// one, unless the account doesn't have a password yet.
+type UpdateUserRequest struct {
+    Username        string  `json:"username" binding:"omitempty,username"`
+    Password        string  `json:"password" binding:"max=256"`
+    CurrentPassword string  `json:"current_password" binding:"max=256"`
+    DisplayName     *string `json:"display_name" binding:"omitempty,max=50"`
+    Bio             *string `json:"bio" binding:"omitempty,max=500"`
+}
+
+type VerifyEmailRequest struct {
+    Token string `json:"token" binding:"required,max=128"`
+}
+
+type ForgotPasswordRequest struct {
+    Email string `json:"email" binding:"required,email,max=254"`
+}
+
+type ResetPasswordRequest struct {
+    Token    string `json:"token" binding:"required,max=128"`
+    Password string `json:"password" binding:"required,max=256"`
+}
+
+type CreateRoomRequest struct {
+    Name       string `json:"name" binding:"required,max=100,roomname"`
+    VideoURL   string `json:"video_url" binding:"omitempty,max=2048,videourl"`
+    Visibility string `json:"visibility" binding:"omitempty,visibility"`
+    Password   string `json:"password" binding:"omitempty,min=4,max=72"`
+    Code       string `json:"code" binding:"omitempty,roomcode"` // vanity code reserved by the caller
+    JoinPolicy string `json:"join_policy" binding:"omitempty,joinpolicy"`
+    MaxParticipants int `json:"max_participants" binding:"gte=0,lte=10000"` // 0 for the server default
+    Schedule   *ScheduleRequest `json:"schedule"`
+}
+
+// ScheduleRequest schedules a room to start at starts_at, every week if
+// recurrence is weekly. Recurring rooms keep the local time of day in
+// timezone, UTC by default.
+type ScheduleRequest struct {
+    StartsAt   time.Time `json:"starts_at" binding:"required"`
+    Timezone   string    `json:"timezone" binding:"omitempty,timezone"`
+    Recurrence string    `json:"recurrence" binding:"omitempty,oneof=weekly"`
+}
+
+// CloneRoomRequest creates a room from another room or a template. The new
+// room keeps the source's name unless name is given.
+type CloneRoomRequest struct {
+    Name     string           `json:"name" binding:"omitempty,max=100,roomname"`
+    Code     string           `json:"code" binding:"omitempty,roomcode"` // vanity code reserved by the caller
+    Schedule *ScheduleRequest `json:"schedule"`
+}
+
+// SaveRoomTemplateRequest saves a room's setup under name.
+type SaveRoomTemplateRequest struct {
+    Name string `json:"name" binding:"required,max=100,roomname"`
+}
+
+type QueueItemRequest struct {
+    VideoURL string `json:"video_url" binding:"required,max=2048,videourl"`
+    Title    string `json:"title" binding:"max=200"`
+}
+
+// UpdateRoomRequest changes only the fields that are present. An empty
+// video_url removes the video and an empty password removes the password.
+type UpdateRoomRequest struct {
+    Name       *string `json:"name" binding:"omitempty,max=100,roomname"`
+    VideoURL   *string `json:"video_url" binding:"omitempty,max=2048,len=0|videourl"`
+    Visibility *string `json:"visibility" binding:"omitempty,visibility"`
+    Password   *string `json:"password" binding:"omitempty,len=0|min=4,max=72"`
+    JoinPolicy *string `json:"join_policy" binding:"omitempty,joinpolicy"`
+    MaxParticipants *int `json:"max_participants" binding:"omitempty,gte=0,lte=10000"`
+}
+
+// ListRoomsRequest is bound from the query string of GET /api/rooms.
+type ListRoomsRequest struct {
+    Scope    string `form:"scope" json:"scope" binding:"omitempty,oneof=public hosted joined"`
+    Query    string `form:"q" json:"q" binding:"max=100"`
+    Platform string `form:"platform" json:"platform" binding:"omitempty,platform"`
+    Playing  *bool  `form:"playing" json:"playing"`
+    Sort     string `form:"sort" json:"sort" binding:"omitempty,oneof=activity participants"`
+    Limit    int    `form:"limit" json:"limit" binding:"gte=0,lte=100"`
+    Cursor   string `form:"cursor" json:"cursor" binding:"max=256"`
+}
+
+type JoinRoomRequest struct {
+    Password string `json:"password" binding:"max=72"`
+}
+
+// UpdateRoomStateRequest bounds current_time at one week, longer than any
+// video.
+type UpdateRoomStateRequest struct {
+    IsPlaying   bool    `json:"is_playing"`
+    CurrentTime float64 `json:"current_time" binding:"gte=0,lte=604800"`
+}
+
+// DecideJoinRequestsRequest selects lobby requests to admit or deny: those
+// listed, or all of them.
+type DecideJoinRequestsRequest struct {
+    RequestIDs []uint `json:"request_ids" binding:"required_without=All,max=100,dive,gt=0"`
+    All        bool   `json:"all"`
+}
+
+// BanUserRequest bans user_id for duration seconds, at most a year, or
+// for good if duration is 0.
+type BanUserRequest struct {
+    UserID   uint   `json:"user_id" binding:"required"`
+    Duration int    `json:"duration" binding:"gte=0,lte=31536000"`
+    Reason   string `json:"reason" binding:"max=500"`
+}
+
+type TransferHostRequest struct {
+    UserID uint `json:"user_id" binding:"required"`
+}
+
+type CreateInviteRequest struct {
+    ExpiresIn int    `json:"expires_in" binding:"gte=0,lte=2592000"` // seconds, at most 30 days
+    MaxUses   int    `json:"max_uses" binding:"gte=0,lte=10000"`
+    Role      string `json:"role" binding:"omitempty,role"`
+}
+
+type CreateAPITokenRequest struct {
+    Name      string   `json:"name" binding:"required,max=100"`
+    Scopes    []string `json:"scopes" binding:"required,min=1,dive,scope"`
+    ExpiresIn int      `json:"expires_in" binding:"required,min=1,lte=31536000"` // seconds, at most a year
+}
+
+type UnlockLoginRequest struct {
+    Username string `json:"username" binding:"required_without=IP,max=254"`
+    IP       string `json:"ip" binding:"omitempty,ip"`
+}
+
+// DeleteAccountRequest confirms deletion with the current password, which
+// accounts created through an identity provider don't have.
+type DeleteAccountRequest struct {
+    Password string `json:"password" binding:"max=256"`
+}
+
+// TwoFactorLoginRequest completes a login that returned a challenge token,
+// with either a TOTP code or a recovery code.
+type TwoFactorLoginRequest struct {
+    ChallengeToken string `json:"challenge_token" binding:"required"`
+    Code           string `json:"code" binding:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+    RecoveryCode   string `json:"recovery_code" binding:"omitempty,max=32"`
+}
+
+type TOTPCodeRequest struct {
+    Code string `json:"code" binding:"required,numeric,len=6"`
+}
+
+type DisableTOTPRequest struct {
+    Code         string `json:"code" binding:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+    RecoveryCode string `json:"recovery_code" binding:"omitempty,max=32"`
+}
+
+type MagicLinkRequest struct {
+    Email string `json:"email" binding:"required,email,max=254"`
+}
+
+type MagicLinkLoginRequest struct {
+    Token string `json:"token" binding:"required,max=128"`
+}
+
+type ReserveRoomCodeRequest struct {
+    Code string `json:"code" binding:"required,roomcode"`
+}
+
+type SetPremiumRequest struct {
+    Premium bool `json:"premium"`
+}
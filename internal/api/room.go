@@ -4,28 +4,125 @@ import (
     "github.com/gin-gonic/gin"
     "github.com/spacelord16/Videoparty/internal/model"
     "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/realtime"
+    "golang.org/x/crypto/bcrypt"
+    "log"
     "net/http"
-    "math/rand"
+    "strings"
     "time"
 )
 
-func generateRoomCode() string {
-    const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-    const length = 6
-    b := make([]byte, length)
-    for i := range b {
-        b[i] = charset[rand.Intn(len(charset))]
+// trackPresence marks the caller as present and promotes a new host if the
+// current one has been gone too long. Callers who haven't joined the room
+// leave it untouched, so they can't keep it from going idle. Failures are
+// logged rather than surfaced, since presence is best-effort.
+func trackPresence(c *gin.Context, room *model.Room) {
+    userID, exists := c.Get("userID")
+    if !exists || room.Closed() {
+        return
+    }
+    if err := db.TouchParticipant(db.DB, room, userID.(uint)); err != nil {
+        if err != db.ErrNotParticipant {
+            log.Printf("Error recording presence in room %s: %v", room.Code, err)
+        }
+        return
+    }
+    if err := db.TouchRoom(db.DB, room); err != nil {
+        log.Printf("Error recording activity in room %s: %v", room.Code, err)
+    }
+    migrated, err := db.MigrateHost(db.DB, room, db.HostGracePeriod())
+    if err != nil {
+        log.Printf("Error migrating host of room %s: %v", room.Code, err)
+    } else if migrated {
+        log.Printf("Room %s host migrated to user %d", room.Code, room.HostID)
+        realtime.Broadcast(room.ID, realtime.EventHost, newRoomResponse(*room))
+    }
+}
+
+func validVisibility(v string) bool {
+    switch v {
+    case model.VisibilityPublic, model.VisibilityUnlisted, model.VisibilityPrivate:
+        return true
+    }
+    return false
+}
+
+// canViewRoom reports whether userID may read a room's details and state.
+// Private rooms, and rooms only joined through approval or an invite, are
+// only visible to their participants.
+func canViewRoom(room *model.Room, userID uint) (bool, error) {
+    restricted := room.Visibility == model.VisibilityPrivate ||
+        room.JoinPolicy == model.JoinApproval || room.JoinPolicy == model.JoinInvite
+    if !restricted || room.HostID == userID {
+        return true, nil
     }
-    return string(b)
+    return db.IsParticipant(db.DB, room.ID, userID)
+}
+
+// roomClosed answers 410 and returns true if room's party has ended.
+func roomClosed(c *gin.Context, room *model.Room) bool {
+    if !room.Closed() {
+        return false
+    }
+    c.JSON(http.StatusGone, gin.H{"error": "Room is closed"})
+    return true
+}
+
+// hostRoom loads the room named by the :code parameter and checks that the
+// caller hosts it. On failure it writes the error response and returns false.
+func hostRoom(c *gin.Context) (model.Room, uint, bool) {
+    room, err := db.FindRoom(db.DB, c.Param("code"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+        return room, 0, false
+    }
+
+    userID, exists := c.Get("userID")
+    if !exists || userID.(uint) != room.HostID {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Only room host can do this"})
+        return room, 0, false
+    }
+
+    return room, userID.(uint), true
 }
 
 func CreateRoom(c *gin.Context) {
-    var room model.Room
-    if err := c.ShouldBindJSON(&room); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    var createData CreateRoomRequest
+    if !bindJSON(c, &createData) {
         return
     }
 
+    if createData.Visibility == "" {
+        createData.Visibility = model.VisibilityPublic
+    }
+    if createData.JoinPolicy == "" {
+        createData.JoinPolicy = model.JoinOpen
+    }
+
+    room := model.Room{
+        Name:       createData.Name,
+        VideoURL:   createData.VideoURL,
+        Visibility: createData.Visibility,
+        Platform:   model.DetectPlatform(createData.VideoURL),
+        JoinPolicy: createData.JoinPolicy,
+        MaxParticipants: createData.MaxParticipants,
+    }
+    if createData.Password != "" {
+        hashedPassword, err := bcrypt.GenerateFromPassword([]byte(createData.Password), bcrypt.DefaultCost)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
+            return
+        }
+        room.PasswordHash = string(hashedPassword)
+    }
+
+    createRoom(c, room, createData.Code, createData.Schedule, db.RoomSetup{})
+}
+
+// createRoom stores room for the caller with setup, giving it the vanity
+// code if one is requested and the schedule if one is given, and writes the
+// response.
+func createRoom(c *gin.Context, room model.Room, code string, schedule *ScheduleRequest, setup db.RoomSetup) {
     // Get user ID from context (set by auth middleware)
     userID, exists := c.Get("userID")
     if !exists {
@@ -34,25 +131,53 @@ func CreateRoom(c *gin.Context) {
     }
 
     room.HostID = userID.(uint)
-    room.Code = generateRoomCode()
     room.CreatedAt = time.Now()
     room.UpdatedAt = time.Now()
+    room.LastActivityAt = time.Now()
+
+    if schedule != nil {
+        start, ok := scheduleStart(c, *schedule)
+        if !ok {
+            return
+        }
+        room.Schedule(start, schedule.Recurrence, time.Now())
+    }
+
+    // Vanity codes must be reserved first, which only premium users can do.
+    if code != "" {
+        reserved, err := db.HasRoomCodeReservation(db.DB, room.HostID, code)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
+            return
+        }
+        if !reserved {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Room code is not reserved by you"})
+            return
+        }
+        room.Code = code
+    }
 
-    if err := db.DB.Create(&room).Error; err != nil {
+    if err := db.CreateRoomWithSetup(db.DB, &room, setup); err != nil {
+        if err == db.ErrCodeTaken {
+            c.JSON(http.StatusConflict, gin.H{"error": "Room code is already in use"})
+            return
+        }
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
         return
     }
 
-    c.JSON(http.StatusCreated, room)
+    c.JSON(http.StatusCreated, newRoomResponse(room))
 }
 
 func JoinRoom(c *gin.Context) {
-    code := c.Param("code")
-    var room model.Room
-    if err := db.DB.Where("code = ?", code).First(&room).Error; err != nil {
+    room, err := db.FindRoom(db.DB, c.Param("code"))
+    if err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
         return
     }
+    if roomClosed(c, &room) {
+        return
+    }
 
     userID, exists := c.Get("userID")
     if !exists {
@@ -60,63 +185,404 @@ func JoinRoom(c *gin.Context) {
         return
     }
 
-    participant := model.RoomParticipant{
-        RoomID:   room.ID,
-        UserID:   userID.(uint),
-        JoinedAt: time.Now(),
+    var joinData JoinRoomRequest
+    if !bindJSON(c, &joinData, true) {
+        return
     }
 
-    if err := db.DB.Create(&participant).Error; err != nil {
+    ban, banned, err := db.ActiveBan(db.DB, room.ID, userID.(uint))
+    if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join room"})
         return
     }
+    if banned {
+        writeBanned(c, ban)
+        return
+    }
+
+    joined, err := db.IsParticipant(db.DB, room.ID, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join room"})
+        return
+    }
+
+    // Returning participants and the host skip the access checks.
+    if !joined && userID.(uint) != room.HostID {
+        if room.HasPassword() {
+            if joinData.Password == "" {
+                c.JSON(http.StatusUnauthorized, gin.H{"error": "Room password required"})
+                return
+            }
+            if bcrypt.CompareHashAndPassword([]byte(room.PasswordHash), []byte(joinData.Password)) != nil {
+                c.JSON(http.StatusForbidden, gin.H{"error": "Invalid room password"})
+                return
+            }
+        } else if room.Visibility == model.VisibilityPrivate {
+            c.JSON(http.StatusForbidden, gin.H{"error": "This room is private"})
+            return
+        }
 
-    c.JSON(http.StatusOK, room)
+        switch room.JoinPolicy {
+        case model.JoinInvite:
+            c.JSON(http.StatusForbidden, gin.H{"error": "This room can only be joined with an invite"})
+            return
+        case model.JoinApproval:
+            enterLobby(c, &room, userID.(uint))
+            return
+        }
+    }
+
+    if err := db.JoinRoom(db.DB, &room, userID.(uint), model.RoleViewer); err != nil {
+        if err == db.ErrRoomFull {
+            c.JSON(http.StatusConflict, gin.H{"error": "Room is full"})
+            return
+        }
+        if err == db.ErrBanned {
+            c.JSON(http.StatusForbidden, gin.H{"error": "You are banned from this room"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join room"})
+        return
+    }
+
+    trackPresence(c, &room)
+
+    c.JSON(http.StatusOK, newRoomResponse(room))
+}
+
+// defaultRoomPageSize is the directory page size when none is requested.
+const defaultRoomPageSize = 20
+
+// ListRooms serves the room directory: public rooms by default, or the rooms
+// the caller hosts or has joined, filtered and sorted per the query string
+// and paginated with an opaque cursor.
+func ListRooms(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    var req ListRoomsRequest
+    if !bindQuery(c, &req) {
+        return
+    }
+
+    query := db.RoomQuery{
+        Scope:    req.Scope,
+        UserID:   userID.(uint),
+        Search:   strings.TrimSpace(req.Query),
+        Platform: req.Platform,
+        Playing:  req.Playing,
+        Sort:     req.Sort,
+        Limit:    req.Limit,
+    }
+    if query.Limit == 0 {
+        query.Limit = defaultRoomPageSize
+    }
+    if req.Cursor != "" {
+        cursor, err := db.DecodeRoomCursor(req.Cursor)
+        if err != nil {
+            writeFieldError(c, "cursor", "invalid", "is not a cursor returned by this endpoint")
+            return
+        }
+        query.After = cursor
+    }
+
+    listings, next, err := db.ListRooms(db.DB, query)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list rooms"})
+        return
+    }
+
+    rooms := make([]RoomListingResponse, len(listings))
+    for i, l := range listings {
+        rooms[i] = RoomListingResponse{
+            RoomResponse:     newRoomResponse(l.Room),
+            ParticipantCount: l.ParticipantCount,
+        }
+    }
+    resp := gin.H{"rooms": rooms, "next_cursor": nil}
+    if next != nil {
+        resp["next_cursor"] = next.Encode()
+    }
+    c.JSON(http.StatusOK, resp)
 }
 
 func GetRoom(c *gin.Context) {
-    code := c.Param("code")
-    var room model.Room
-    if err := db.DB.Where("code = ?", code).First(&room).Error; err != nil {
+    room, err := db.FindRoom(db.DB, c.Param("code"))
+    if err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
         return
     }
 
-    c.JSON(http.StatusOK, room)
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    allowed, err := canViewRoom(&room, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load room"})
+        return
+    }
+    if !allowed {
+        c.JSON(http.StatusForbidden, gin.H{"error": "This room is private"})
+        return
+    }
+
+    trackPresence(c, &room)
+
+    c.JSON(http.StatusOK, newRoomResponse(room))
 }
 
 func UpdateRoomState(c *gin.Context) {
-    code := c.Param("code")
-    var room model.Room
-    if err := db.DB.Where("code = ?", code).First(&room).Error; err != nil {
+    room, err := db.FindRoom(db.DB, c.Param("code"))
+    if err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
         return
     }
+    if roomClosed(c, &room) {
+        return
+    }
 
     userID, exists := c.Get("userID")
-    if !exists || userID.(uint) != room.HostID {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Only room host can update state"})
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
         return
     }
 
-    var updateData struct {
-        IsPlaying   bool    `json:"is_playing"`
-        CurrentTime float64 `json:"current_time"`
+    allowed, err := db.CanControl(db.DB, &room, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
+        return
+    }
+    if !allowed {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Only room host or moderators can update state"})
+        return
     }
 
-    if err := c.ShouldBindJSON(&updateData); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    trackPresence(c, &room)
+
+    var updateData UpdateRoomStateRequest
+    if !bindJSON(c, &updateData) {
         return
     }
 
+    before := room
     room.IsPlaying = updateData.IsPlaying
     room.CurrentTime = updateData.CurrentTime
     room.UpdatedAt = time.Now()
+    room.LastActivityAt = room.UpdatedAt
+
+    // Only write the playback columns, so a concurrent edit or close of the
+    // room isn't undone.
+    res := db.DB.Model(&model.Room{}).
+        Where("id = ? AND closed_at IS NULL", room.ID).
+        UpdateColumns(map[string]interface{}{
+            "is_playing":       room.IsPlaying,
+            "current_time":     room.CurrentTime,
+            "updated_at":       room.UpdatedAt,
+            "last_activity_at": room.LastActivityAt,
+        })
+    if res.Error != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
+        return
+    }
+    if res.RowsAffected == 0 {
+        c.JSON(http.StatusGone, gin.H{"error": "Room is closed"})
+        return
+    }
+
+    if err := db.RecordPlayback(db.DB, &before, &room, userID.(uint)); err != nil {
+        log.Printf("Error recording playback in room %s: %v", room.Code, err)
+    }
+
+    resp := newRoomResponse(room)
+    realtime.Broadcast(room.ID, realtime.EventState, resp)
+    c.JSON(http.StatusOK, resp)
+}
+
+// UpdateRoom lets the host rename the room, change its video and change its
+// settings. A new video starts paused from the beginning.
+func UpdateRoom(c *gin.Context) {
+    room, userID, ok := hostRoom(c)
+    if !ok || roomClosed(c, &room) {
+        return
+    }
+
+    var updateData UpdateRoomRequest
+    if !bindJSON(c, &updateData) {
+        return
+    }
+
+    updates := map[string]interface{}{}
+    if updateData.Name != nil {
+        updates["name"] = *updateData.Name
+    }
+    if updateData.VideoURL != nil && *updateData.VideoURL != room.VideoURL {
+        updates["video_url"] = *updateData.VideoURL
+        updates["platform"] = model.DetectPlatform(*updateData.VideoURL)
+        updates["is_playing"] = false
+        updates["current_time"] = 0
+    }
+    if updateData.Visibility != nil && *updateData.Visibility != "" {
+        updates["visibility"] = *updateData.Visibility
+    }
+    if updateData.JoinPolicy != nil && *updateData.JoinPolicy != "" {
+        updates["join_policy"] = *updateData.JoinPolicy
+    }
+    if updateData.MaxParticipants != nil {
+        updates["max_participants"] = *updateData.MaxParticipants
+    }
+    if updateData.Password != nil {
+        updates["password_hash"] = ""
+        if *updateData.Password != "" {
+            hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*updateData.Password), bcrypt.DefaultCost)
+            if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
+                return
+            }
+            updates["password_hash"] = string(hashedPassword)
+        }
+    }
+    if len(updates) == 0 {
+        c.JSON(http.StatusOK, newRoomResponse(room))
+        return
+    }
+    updates["updated_at"] = time.Now()
+    updates["last_activity_at"] = updates["updated_at"]
 
-    if err := db.DB.Save(&room).Error; err != nil {
+    res := db.DB.Model(&model.Room{}).Where("id = ? AND closed_at IS NULL", room.ID).Updates(updates)
+    if res.Error != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
+        return
+    }
+    if res.RowsAffected == 0 {
+        c.JSON(http.StatusGone, gin.H{"error": "Room is closed"})
+        return
+    }
+    before := room
+    if err := db.DB.First(&room, room.ID).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
         return
     }
 
-    c.JSON(http.StatusOK, room)
-} 
\ No newline at end of file
+    if room.VideoURL != before.VideoURL {
+        if err := db.RecordPlayback(db.DB, &before, &room, userID); err != nil {
+            log.Printf("Error recording playback in room %s: %v", room.Code, err)
+        }
+    }
+
+    resp := newRoomResponse(room)
+    realtime.Broadcast(room.ID, realtime.EventRoom, resp)
+    c.JSON(http.StatusOK, resp)
+}
+
+// CloseRoom ends the party. The room stays readable, but can no longer be
+// joined or played, and connected clients are disconnected.
+func CloseRoom(c *gin.Context) {
+    room, _, ok := hostRoom(c)
+    if !ok {
+        return
+    }
+
+    if err := db.CloseRoom(db.DB, &room); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close room"})
+        return
+    }
+
+    resp := newRoomResponse(room)
+    realtime.Disconnect(room.ID, 0, realtime.EventClosed, resp)
+    c.JSON(http.StatusOK, resp)
+}
+
+// DeleteRoom closes the room and hides it. The row is only soft-deleted, so
+// its history is kept, but its code no longer resolves and can be reused.
+func DeleteRoom(c *gin.Context) {
+    room, _, ok := hostRoom(c)
+    if !ok {
+        return
+    }
+
+    if err := db.DeleteRoom(db.DB, &room); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room"})
+        return
+    }
+
+    realtime.Disconnect(room.ID, 0, realtime.EventDeleted, nil)
+    c.Status(http.StatusNoContent)
+}
+
+func TransferHost(c *gin.Context) {
+    room, _, ok := hostRoom(c)
+    if !ok || roomClosed(c, &room) {
+        return
+    }
+
+    var transferData TransferHostRequest
+    if !bindJSON(c, &transferData) {
+        return
+    }
+
+    joined, err := db.IsParticipant(db.DB, room.ID, transferData.UserID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer host"})
+        return
+    }
+    if !joined {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "New host must be a participant of the room"})
+        return
+    }
+
+    if err := db.SetHost(db.DB, &room, transferData.UserID); err != nil {
+        if err == db.ErrHostChanged {
+            c.JSON(http.StatusConflict, gin.H{"error": "Room host changed, please retry"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer host"})
+        return
+    }
+
+    resp := newRoomResponse(room)
+    realtime.Broadcast(room.ID, realtime.EventHost, resp)
+    c.JSON(http.StatusOK, resp)
+}
+
+func ListParticipants(c *gin.Context) {
+    room, err := db.FindRoom(db.DB, c.Param("code"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+        return
+    }
+
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    allowed, err := canViewRoom(&room, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load room"})
+        return
+    }
+    if !allowed {
+        c.JSON(http.StatusForbidden, gin.H{"error": "This room is private"})
+        return
+    }
+
+    var participants []model.RoomParticipant
+    if err := db.DB.Preload("User").Where("room_id = ?", room.ID).Order("joined_at").Find(&participants).Error; err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list participants"})
+        return
+    }
+
+    resp := make([]ParticipantResponse, 0, len(participants))
+    for _, p := range participants {
+        resp = append(resp, newParticipantResponse(room, p))
+    }
+
+    c.JSON(http.StatusOK, resp)
+}
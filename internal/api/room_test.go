@@ -0,0 +1,371 @@
+package api
+
+import (
+    "encoding/json"
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/realtime"
+    "golang.org/x/crypto/bcrypt"
+    "net/http"
+    "net/url"
+    "testing"
+    "time"
+)
+
+func TestCanViewRoomWithoutDatabase(t *testing.T) {
+    open := &model.Room{ID: 1, HostID: 1, Visibility: model.VisibilityUnlisted, JoinPolicy: model.JoinOpen}
+    if ok, err := canViewRoom(open, 2); err != nil || !ok {
+        t.Errorf("unlisted open room hidden from a stranger: %v, %v", ok, err)
+    }
+
+    for _, room := range []*model.Room{
+        {ID: 1, HostID: 1, Visibility: model.VisibilityPrivate, JoinPolicy: model.JoinOpen},
+        {ID: 1, HostID: 1, Visibility: model.VisibilityPublic, JoinPolicy: model.JoinApproval},
+        {ID: 1, HostID: 1, Visibility: model.VisibilityPublic, JoinPolicy: model.JoinInvite},
+    } {
+        if ok, err := canViewRoom(room, 1); err != nil || !ok {
+            t.Errorf("%s %s room hidden from its host: %v, %v", room.Visibility, room.JoinPolicy, ok, err)
+        }
+    }
+}
+
+func TestJoinPolicies(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    member := newTestUser(t, conn, "member")
+    stranger := newTestUser(t, conn, "stranger")
+
+    r := testRouter()
+    r.GET("/rooms/:code", GetRoom)
+    r.POST("/rooms/:code/join", JoinRoom)
+
+    for _, policy := range []string{model.JoinApproval, model.JoinInvite} {
+        room := newTestRoom(t, conn, model.Room{HostID: host.ID, JoinPolicy: policy})
+        if err := db.AddParticipant(conn, room.ID, member.ID, model.RoleViewer); err != nil {
+            t.Fatal(err)
+        }
+        path := "/rooms/" + room.Code
+
+        if w := serve(r, http.MethodGet, path, stranger.ID, nil); w.Code != http.StatusForbidden {
+            t.Errorf("%s room shown to a stranger: status = %d", policy, w.Code)
+        }
+        if w := serve(r, http.MethodGet, path, member.ID, nil); w.Code != http.StatusOK {
+            t.Errorf("%s room hidden from a participant: status = %d", policy, w.Code)
+        }
+    }
+
+    invite := newTestRoom(t, conn, model.Room{HostID: host.ID, JoinPolicy: model.JoinInvite})
+    if w := serve(r, http.MethodPost, "/rooms/"+invite.Code+"/join", stranger.ID, nil); w.Code != http.StatusForbidden {
+        t.Errorf("joining an invite room directly: status = %d, want 403", w.Code)
+    }
+    if ok, _ := db.IsParticipant(conn, invite.ID, stranger.ID); ok {
+        t.Error("stranger joined an invite room without an invite")
+    }
+}
+
+func TestJoinFullRoom(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    alice := newTestUser(t, conn, "alice")
+    bob := newTestUser(t, conn, "bob")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID, MaxParticipants: 2})
+
+    r := testRouter()
+    r.POST("/rooms/:code/join", JoinRoom)
+    path := "/rooms/" + room.Code + "/join"
+
+    if w := serve(r, http.MethodPost, path, alice.ID, nil); w.Code != http.StatusOK {
+        t.Fatalf("first join: status = %d, body %s", w.Code, w.Body)
+    }
+    if w := serve(r, http.MethodPost, path, bob.ID, nil); w.Code != http.StatusConflict {
+        t.Errorf("joining a full room: status = %d, want 409", w.Code)
+    }
+    if w := serve(r, http.MethodPost, path, alice.ID, nil); w.Code != http.StatusOK {
+        t.Errorf("rejoining a full room: status = %d, want 200", w.Code)
+    }
+}
+
+func TestPrivateRoomAccess(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    guest := newTestUser(t, conn, "guest")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID, Visibility: model.VisibilityPrivate})
+
+    r := testRouter()
+    r.GET("/rooms/:code", GetRoom)
+    r.POST("/rooms/:code/join", JoinRoom)
+
+    if w := serve(r, http.MethodGet, "/rooms/"+room.Code, guest.ID, nil); w.Code != http.StatusForbidden {
+        t.Fatalf("stranger reading a private room: status %d", w.Code)
+    }
+    if w := serve(r, http.MethodPost, "/rooms/"+room.Code+"/join", guest.ID, nil); w.Code != http.StatusForbidden {
+        t.Fatalf("stranger joining a private room: status %d", w.Code)
+    }
+    if w := serve(r, http.MethodGet, "/rooms/"+room.Code, host.ID, nil); w.Code != http.StatusOK {
+        t.Fatalf("host reading their private room: status %d", w.Code)
+    }
+}
+
+func TestRoomPassword(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    guest := newTestUser(t, conn, "guest")
+    hash, err := bcrypt.GenerateFromPassword([]byte("popcorn"), bcrypt.MinCost)
+    if err != nil {
+        t.Fatal(err)
+    }
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID, Visibility: model.VisibilityPrivate, PasswordHash: string(hash)})
+
+    r := testRouter()
+    r.POST("/rooms/:code/join", JoinRoom)
+    path := "/rooms/" + room.Code + "/join"
+
+    tests := []struct {
+        name string
+        body interface{}
+        want int
+    }{
+        {"no password", nil, http.StatusUnauthorized},
+        {"wrong password", map[string]string{"password": "nachos"}, http.StatusForbidden},
+        {"right password", map[string]string{"password": "popcorn"}, http.StatusOK},
+        {"returning participant", nil, http.StatusOK},
+    }
+    for _, tt := range tests {
+        if w := serve(r, http.MethodPost, path, guest.ID, tt.body); w.Code != tt.want {
+            t.Errorf("%s: status %d, want %d (%s)", tt.name, w.Code, tt.want, w.Body)
+        }
+    }
+}
+
+func TestListRoomsValidatesQuery(t *testing.T) {
+    r := testRouter()
+    r.GET("/rooms", ListRooms)
+
+    for _, query := range []string{
+        "scope=everyone",
+        "sort=name",
+        "limit=101",
+        "limit=-1",
+        "platform=betamax",
+        "cursor=not-a-cursor",
+    } {
+        w := serve(r, http.MethodGet, "/rooms?"+query, 1, nil)
+        if w.Code != http.StatusBadRequest {
+            t.Errorf("%s: status = %d, want 400", query, w.Code)
+        }
+    }
+}
+
+func TestListRoomsPaginates(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    for i := 0; i < 3; i++ {
+        newTestRoom(t, conn, model.Room{HostID: host.ID})
+    }
+
+    r := testRouter()
+    r.GET("/rooms", ListRooms)
+
+    var resp struct {
+        Rooms      []RoomListingResponse `json:"rooms"`
+        NextCursor *string               `json:"next_cursor"`
+    }
+    w := serve(r, http.MethodGet, "/rooms?limit=2", host.ID, nil)
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    if len(resp.Rooms) != 2 || resp.NextCursor == nil {
+        t.Fatalf("first page = %d rooms, cursor %v", len(resp.Rooms), resp.NextCursor)
+    }
+
+    w = serve(r, http.MethodGet, "/rooms?limit=2&cursor="+url.QueryEscape(*resp.NextCursor), host.ID, nil)
+    resp.NextCursor = nil
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    if len(resp.Rooms) != 1 || resp.NextCursor != nil {
+        t.Errorf("second page = %d rooms, cursor %v", len(resp.Rooms), resp.NextCursor)
+    }
+}
+
+func TestUpdateRoom(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    guest := newTestUser(t, conn, "guest")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID, VideoURL: "https://www.youtube.com/watch?v=a", IsPlaying: true, CurrentTime: 90})
+
+    r := testRouter()
+    r.PATCH("/rooms/:code", UpdateRoom)
+    path := "/rooms/" + room.Code
+
+    if w := serve(r, http.MethodPatch, path, guest.ID, gin.H{"name": "Mine now"}); w.Code != http.StatusForbidden {
+        t.Errorf("guest update: status = %d, want 403", w.Code)
+    }
+
+    sub := realtime.Subscribe(room.ID, guest.ID, false)
+    defer realtime.Unsubscribe(sub)
+
+    w := serve(r, http.MethodPatch, path, host.ID, gin.H{"name": "Late show", "video_url": "https://vimeo.com/1"})
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    var stored model.Room
+    conn.First(&stored, room.ID)
+    if stored.Name != "Late show" || stored.VideoURL != "https://vimeo.com/1" || stored.Platform != model.PlatformVimeo {
+        t.Errorf("room = %+v", stored)
+    }
+    if stored.IsPlaying || stored.CurrentTime != 0 {
+        t.Errorf("new video playing %v at %v, want paused at the start", stored.IsPlaying, stored.CurrentTime)
+    }
+    select {
+    case ev := <-sub.Events:
+        if ev.Type != realtime.EventRoom {
+            t.Errorf("event = %+v", ev)
+        }
+    default:
+        t.Error("connected clients were not told about the change")
+    }
+
+    // Changing only the name leaves playback alone.
+    conn.Model(&stored).UpdateColumns(map[string]interface{}{"is_playing": true, "current_time": 12})
+    serve(r, http.MethodPatch, path, host.ID, gin.H{"name": "Later show"})
+    conn.First(&stored, room.ID)
+    if !stored.IsPlaying || stored.CurrentTime != 12 {
+        t.Errorf("rename changed playback to playing %v at %v", stored.IsPlaying, stored.CurrentTime)
+    }
+
+    if w := serve(r, http.MethodPatch, path, host.ID, gin.H{"visibility": "secret"}); w.Code != http.StatusBadRequest {
+        t.Errorf("bad visibility: status = %d, want 400", w.Code)
+    }
+}
+
+func TestCloseAndDeleteRoom(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    guest := newTestUser(t, conn, "guest")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID})
+
+    r := testRouter()
+    r.PATCH("/rooms/:code", UpdateRoom)
+    r.POST("/rooms/:code/close", CloseRoom)
+    r.DELETE("/rooms/:code", DeleteRoom)
+    r.GET("/rooms/:code", GetRoom)
+    path := "/rooms/" + room.Code
+
+    if w := serve(r, http.MethodPost, path+"/close", guest.ID, nil); w.Code != http.StatusForbidden {
+        t.Errorf("guest close: status = %d, want 403", w.Code)
+    }
+
+    sub := realtime.Subscribe(room.ID, guest.ID, false)
+    defer realtime.Unsubscribe(sub)
+    if w := serve(r, http.MethodPost, path+"/close", host.ID, nil); w.Code != http.StatusOK {
+        t.Fatalf("close: status = %d, body %s", w.Code, w.Body)
+    }
+    select {
+    case ev := <-sub.Events:
+        if ev.Type != realtime.EventClosed || !ev.Close {
+            t.Errorf("event = %+v, want a closing event", ev)
+        }
+    default:
+        t.Error("connected clients were not disconnected")
+    }
+
+    if w := serve(r, http.MethodGet, path, host.ID, nil); w.Code != http.StatusOK {
+        t.Errorf("closed room is no longer readable: status = %d", w.Code)
+    }
+    if w := serve(r, http.MethodPatch, path, host.ID, gin.H{"name": "Reopened"}); w.Code != http.StatusGone {
+        t.Errorf("update of a closed room: status = %d, want 410", w.Code)
+    }
+
+    if w := serve(r, http.MethodDelete, path, host.ID, nil); w.Code != http.StatusNoContent {
+        t.Fatalf("delete: status = %d, body %s", w.Code, w.Body)
+    }
+    if w := serve(r, http.MethodGet, path, host.ID, nil); w.Code != http.StatusNotFound {
+        t.Errorf("deleted room: status = %d, want 404", w.Code)
+    }
+    var count int64
+    conn.Unscoped().Model(&model.Room{}).Where("id = ?", room.ID).Count(&count)
+    if count != 1 {
+        t.Error("deleting the room erased its history")
+    }
+}
+
+func TestPresenceNeedsParticipation(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    stranger := newTestUser(t, conn, "stranger")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID})
+    idle := time.Now().Add(-time.Hour).Truncate(time.Second)
+    conn.Model(&room).UpdateColumn("last_activity_at", idle)
+
+    r := testRouter()
+    r.GET("/rooms/:code", GetRoom)
+    r.PUT("/rooms/:code/state", UpdateRoomState)
+    path := "/rooms/" + room.Code
+
+    if w := serve(r, http.MethodGet, path, stranger.ID, nil); w.Code != http.StatusOK {
+        t.Fatalf("stranger reads a public room: status = %d", w.Code)
+    }
+    if w := serve(r, http.MethodPut, path+"/state", stranger.ID, gin.H{"is_playing": true}); w.Code != http.StatusUnauthorized {
+        t.Errorf("stranger updates state: status = %d, want 401", w.Code)
+    }
+
+    var stored model.Room
+    conn.First(&stored, room.ID)
+    if !stored.LastActivityAt.Equal(idle) {
+        t.Errorf("a stranger kept the room active: last activity %v, want %v", stored.LastActivityAt, idle)
+    }
+    if joined, _ := db.IsParticipant(conn, room.ID, stranger.ID); joined {
+        t.Error("reading the room made the stranger a participant")
+    }
+
+    serve(r, http.MethodGet, path, host.ID, nil)
+    conn.First(&stored, room.ID)
+    if !stored.LastActivityAt.After(idle) {
+        t.Error("the host's visit did not count as activity")
+    }
+}
+
+func TestTransferHost(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    guest := newTestUser(t, conn, "guest")
+    stranger := newTestUser(t, conn, "stranger")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID})
+    db.AddParticipant(conn, room.ID, guest.ID, model.RoleViewer)
+
+    r := testRouter()
+    r.POST("/rooms/:code/transfer", TransferHost)
+    path := "/rooms/" + room.Code + "/transfer"
+
+    tests := []struct {
+        name   string
+        userID uint
+        to     uint
+        want   int
+    }{
+        {"not the host", guest.ID, guest.ID, http.StatusForbidden},
+        {"to a stranger", host.ID, stranger.ID, http.StatusBadRequest},
+    }
+    for _, tt := range tests {
+        if w := serve(r, http.MethodPost, path, tt.userID, gin.H{"user_id": tt.to}); w.Code != tt.want {
+            t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+        }
+    }
+
+    closed := newTestRoom(t, conn, model.Room{HostID: host.ID})
+    db.AddParticipant(conn, closed.ID, guest.ID, model.RoleViewer)
+    if err := db.CloseRoom(conn, &closed); err != nil {
+        t.Fatal(err)
+    }
+    if w := serve(r, http.MethodPost, "/rooms/"+closed.Code+"/transfer", host.ID, gin.H{"user_id": guest.ID}); w.Code != http.StatusGone {
+        t.Errorf("closed room: status = %d, want 410", w.Code)
+    }
+
+    w := serve(r, http.MethodPost, path, host.ID, gin.H{"user_id": guest.ID})
+    var resp RoomResponse
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    if w.Code != http.StatusOK || resp.HostID != guest.ID {
+        t.Errorf("transfer: status = %d, body %s", w.Code, w.Body)
+    }
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "net/http"
+    "time"
+)
+
+// RoomCodeResponse is one of the caller's reserved vanity codes.
+type RoomCodeResponse struct {
+    Code      string    `json:"code"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+func newRoomCodeResponse(reservation model.RoomCodeReservation) RoomCodeResponse {
+    return RoomCodeResponse{
+        Code:      reservation.Code,
+        CreatedAt: reservation.CreatedAt,
+    }
+}
+
+// ListRoomCodes returns the vanity codes the caller has reserved.
+func ListRoomCodes(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    reservations, err := db.ListRoomCodeReservations(db.DB, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list room codes"})
+        return
+    }
+
+    resp := make([]RoomCodeResponse, 0, len(reservations))
+    for _, reservation := range reservations {
+        resp = append(resp, newRoomCodeResponse(reservation))
+    }
+    c.JSON(http.StatusOK, resp)
+}
+
+// ReserveRoomCode reserves a vanity code for a premium user, up to
+// ROOM_CODE_RESERVATIONS codes each.
+func ReserveRoomCode(c *gin.Context) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    if !user.Premium {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Vanity room codes require a premium account"})
+        return
+    }
+
+    var reserveData ReserveRoomCodeRequest
+    if !bindJSON(c, &reserveData) {
+        return
+    }
+
+    limit := config.Int("ROOM_CODE_RESERVATIONS", 5)
+    reservation, err := db.ReserveRoomCode(db.DB, user.ID, reserveData.Code, limit)
+    switch err {
+    case nil:
+    case db.ErrCodeTaken:
+        c.JSON(http.StatusConflict, gin.H{"error": "Room code is already taken"})
+        return
+    case db.ErrReservationLimit:
+        c.JSON(http.StatusConflict, gin.H{"error": "Room code reservation limit reached"})
+        return
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve room code"})
+        return
+    }
+
+    c.JSON(http.StatusCreated, newRoomCodeResponse(reservation))
+}
+
+// ReleaseRoomCode gives up one of the caller's reserved codes.
+func ReleaseRoomCode(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    if err := db.ReleaseRoomCode(db.DB, userID.(uint), c.Param("code")); err != nil {
+        if err == db.ErrReservationNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Room code not reserved"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release room code"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
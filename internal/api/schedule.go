@@ -0,0 +1,160 @@
+package api
+
+import (
+    "fmt"
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/ical"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/realtime"
+    "net/http"
+    "net/url"
+    "time"
+)
+
+// ScheduleResponse describes when a scheduled room next starts. StartsIn is
+// the countdown in whole seconds.
+type ScheduleResponse struct {
+    StartsAt   time.Time `json:"starts_at"`
+    Timezone   string    `json:"timezone"`
+    Recurrence string    `json:"recurrence"`
+    StartsIn   int64     `json:"starts_in"`
+}
+
+// newScheduleResponse returns the countdown to room's next start, or nil if
+// none is coming up.
+func newScheduleResponse(room model.Room) *ScheduleResponse {
+    if room.NextStartAt == nil {
+        return nil
+    }
+    startsIn := time.Until(*room.NextStartAt)
+    if startsIn < 0 {
+        startsIn = 0
+    }
+    return &ScheduleResponse{
+        StartsAt:   room.NextStartAt.In(room.Location()),
+        Timezone:   room.Location().String(),
+        Recurrence: room.Recurrence,
+        StartsIn:   int64(startsIn.Round(time.Second) / time.Second),
+    }
+}
+
+// scheduleStart checks req and returns its start time in its timezone. On
+// failure it writes the error response and returns false.
+func scheduleStart(c *gin.Context, req ScheduleRequest) (time.Time, bool) {
+    if !req.StartsAt.After(time.Now()) {
+        writeFieldError(c, "starts_at", "future", "must be in the future")
+        return time.Time{}, false
+    }
+    tz := req.Timezone
+    if tz == "" {
+        tz = "UTC"
+    }
+    loc, err := time.LoadLocation(tz)
+    if err != nil {
+        writeFieldError(c, "timezone", "timezone", "must be an IANA timezone")
+        return time.Time{}, false
+    }
+    return req.StartsAt.In(loc), true
+}
+
+// SetSchedule schedules the room to start playing at a set time, optionally
+// every week.
+func SetSchedule(c *gin.Context) {
+    room, _, ok := hostRoom(c)
+    if !ok || roomClosed(c, &room) {
+        return
+    }
+
+    var scheduleData ScheduleRequest
+    if !bindJSON(c, &scheduleData) {
+        return
+    }
+    start, ok := scheduleStart(c, scheduleData)
+    if !ok {
+        return
+    }
+
+    if err := db.SetSchedule(db.DB, &room, start, scheduleData.Recurrence); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule room"})
+        return
+    }
+
+    resp := newRoomResponse(room)
+    realtime.Broadcast(room.ID, realtime.EventRoom, resp)
+    c.JSON(http.StatusOK, resp)
+}
+
+// ClearSchedule cancels the room's schedule.
+func ClearSchedule(c *gin.Context) {
+    room, _, ok := hostRoom(c)
+    if !ok {
+        return
+    }
+
+    if err := db.ClearSchedule(db.DB, &room); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear schedule"})
+        return
+    }
+
+    resp := newRoomResponse(room)
+    realtime.Broadcast(room.ID, realtime.EventRoom, resp)
+    c.JSON(http.StatusOK, resp)
+}
+
+// RoomCalendar exports the room's schedule as an iCalendar event, repeating
+// weekly for weekly parties, for import into calendar apps.
+func RoomCalendar(c *gin.Context) {
+    room, err := db.FindRoom(db.DB, c.Param("code"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+        return
+    }
+
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    allowed, err := canViewRoom(&room, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load room"})
+        return
+    }
+    if !allowed {
+        c.JSON(http.StatusForbidden, gin.H{"error": "This room is private"})
+        return
+    }
+    if room.ScheduledAt == nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Room is not scheduled"})
+        return
+    }
+
+    appURL := config.String("APP_URL", "http://localhost:3000")
+    link := appURL + "/room/" + url.PathEscape(room.Code)
+    host := "videoparty"
+    if u, err := url.Parse(appURL); err == nil && u.Hostname() != "" {
+        host = u.Hostname()
+    }
+
+    ev := ical.Event{
+        UID:         fmt.Sprintf("room-%d@%s", room.ID, host),
+        Start:       room.ScheduledAt.In(room.Location()),
+        Duration:    config.Duration("ROOM_EVENT_DURATION", 2*time.Hour),
+        Summary:     room.Name,
+        Description: "Join the watch party: " + link,
+        URL:         link,
+        Weekly:      room.Recurrence == model.RecurWeekly,
+        Stamp:       room.UpdatedAt,
+    }
+
+    c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ics"`, room.Code))
+    c.Data(http.StatusOK, "text/calendar; charset=utf-8", ical.Calendar(ev))
+}
+
+// RoomStarted tells a room's clients that the scheduler started playback.
+func RoomStarted(room model.Room) {
+    realtime.Broadcast(room.ID, realtime.EventState, newRoomResponse(room))
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestSetSchedule(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    viewer := newTestUser(t, conn, "viewer")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID})
+
+    r := testRouter()
+    r.PUT("/rooms/:code/schedule", SetSchedule)
+    r.DELETE("/rooms/:code/schedule", ClearSchedule)
+    path := "/rooms/" + room.Code + "/schedule"
+    start := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+    tests := []struct {
+        name   string
+        userID uint
+        body   gin.H
+        want   int
+    }{
+        {"not the host", viewer.ID, gin.H{"starts_at": start}, http.StatusForbidden},
+        {"in the past", host.ID, gin.H{"starts_at": time.Now().Add(-time.Minute)}, http.StatusBadRequest},
+        {"unknown timezone", host.ID, gin.H{"starts_at": start, "timezone": "Mars/Olympus"}, http.StatusBadRequest},
+        {"unknown recurrence", host.ID, gin.H{"starts_at": start, "recurrence": "daily"}, http.StatusBadRequest},
+    }
+    for _, tt := range tests {
+        if w := serve(r, http.MethodPut, path, tt.userID, tt.body); w.Code != tt.want {
+            t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+        }
+    }
+
+    w := serve(r, http.MethodPut, path, host.ID, gin.H{"starts_at": start, "timezone": "Europe/Berlin", "recurrence": "weekly"})
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    var resp RoomResponse
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    if s := resp.Schedule; s == nil || !s.StartsAt.Equal(start) || s.Timezone != "Europe/Berlin" || s.Recurrence != model.RecurWeekly || s.StartsIn < 86300 {
+        t.Errorf("schedule = %+v", resp.Schedule)
+    }
+
+    w = serve(r, http.MethodDelete, path, host.ID, nil)
+    resp = RoomResponse{}
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    if w.Code != http.StatusOK || resp.Schedule != nil {
+        t.Errorf("clear: status = %d, schedule %+v", w.Code, resp.Schedule)
+    }
+}
+
+func TestRoomCalendar(t *testing.T) {
+    conn := testDB(t)
+    t.Setenv("APP_URL", "https://party.example.com")
+    host := newTestUser(t, conn, "host")
+    stranger := newTestUser(t, conn, "stranger")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID, Name: "Dune, again"})
+    hidden := newTestRoom(t, conn, model.Room{HostID: host.ID, Visibility: model.VisibilityPrivate})
+
+    r := testRouter()
+    r.GET("/rooms/:code/calendar.ics", RoomCalendar)
+    r.PUT("/rooms/:code/schedule", SetSchedule)
+
+    if w := serve(r, http.MethodGet, "/rooms/"+room.Code+"/calendar.ics", host.ID, nil); w.Code != http.StatusNotFound {
+        t.Errorf("unscheduled room: status = %d, want 404", w.Code)
+    }
+
+    start := time.Now().Add(24 * time.Hour)
+    for _, code := range []string{room.Code, hidden.Code} {
+        if w := serve(r, http.MethodPut, "/rooms/"+code+"/schedule", host.ID, gin.H{"starts_at": start, "timezone": "America/New_York", "recurrence": "weekly"}); w.Code != http.StatusOK {
+            t.Fatalf("schedule: status = %d, body %s", w.Code, w.Body)
+        }
+    }
+
+    if w := serve(r, http.MethodGet, "/rooms/"+hidden.Code+"/calendar.ics", stranger.ID, nil); w.Code != http.StatusForbidden {
+        t.Errorf("private room: status = %d, want 403", w.Code)
+    }
+
+    w := serve(r, http.MethodGet, "/rooms/"+room.Code+"/calendar.ics", stranger.ID, nil)
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/calendar") {
+        t.Errorf("Content-Type = %q", w.Header().Get("Content-Type"))
+    }
+    body := strings.ReplaceAll(w.Body.String(), "\r\n ", "")
+    for _, want := range []string{
+        fmt.Sprintf("UID:room-%d@party.example.com\r\n", room.ID),
+        "DTSTART;TZID=America/New_York:",
+        "BEGIN:VTIMEZONE\r\nTZID:America/New_York\r\n",
+        "RRULE:FREQ=WEEKLY\r\n",
+        `SUMMARY:Dune\, again` + "\r\n",
+        "URL:https://party.example.com/room/" + room.Code + "\r\n",
+    } {
+        if !strings.Contains(body, want) {
+            t.Errorf("calendar is missing %q:\n%s", want, body)
+        }
+    }
+}
+
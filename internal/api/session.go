@@ -0,0 +1,76 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "net/http"
+    "strconv"
+)
+
+// SessionResponse is one device the user is logged in on.
+type SessionResponse struct {
+    model.Session
+    Current bool `json:"current"`
+}
+
+func ListSessions(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+    current, _ := c.Get("sessionID")
+
+    sessions, err := db.ListSessions(db.DB, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+        return
+    }
+
+    resp := make([]SessionResponse, 0, len(sessions))
+    for _, s := range sessions {
+        resp = append(resp, SessionResponse{Session: s, Current: s.ID == current.(uint)})
+    }
+
+    c.JSON(http.StatusOK, resp)
+}
+
+func RevokeSession(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+        return
+    }
+
+    if err := db.RevokeSession(db.DB, userID.(uint), uint(id)); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+// RevokeOtherSessions signs the user out everywhere except the current
+// device.
+func RevokeOtherSessions(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+    sessionID, _ := c.Get("sessionID")
+
+    if err := db.RevokeOtherSessions(db.DB, userID.(uint), sessionID.(uint)); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
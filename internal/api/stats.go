@@ -0,0 +1,199 @@
+package api
+
+import (
+    "bytes"
+    "encoding/csv"
+    "fmt"
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "math"
+    "net/http"
+    "sort"
+    "strconv"
+    "time"
+)
+
+// RoomStatsResponse sums up how a room's party went. Durations are in
+// seconds.
+type RoomStatsResponse struct {
+    PeakParticipants    int                   `json:"peak_participants"`
+    PeakAt              *time.Time            `json:"peak_at"`
+    AverageParticipants float64               `json:"average_participants"`
+    TotalWatchSeconds   int64                 `json:"total_watch_seconds"`
+    Viewers             []ViewerStatsResponse `json:"viewers"`
+    Timeline            []TimelineResponse    `json:"timeline"`
+    Plays               int                   `json:"plays"`
+    Pauses              int                   `json:"pauses"`
+    Seeks               int                   `json:"seeks"`
+    VideosPlayed        int                   `json:"videos_played"`
+    Videos              []VideoPlayResponse   `json:"videos"`
+}
+
+// ViewerStatsResponse is the time one user spent in the room.
+type ViewerStatsResponse struct {
+    User         UserSummary `json:"user"`
+    WatchSeconds int64       `json:"watch_seconds"`
+    Visits       int         `json:"visits"`
+}
+
+// TimelineResponse is a user joining or leaving the room.
+type TimelineResponse struct {
+    At   time.Time   `json:"at"`
+    Type string      `json:"type"`
+    User UserSummary `json:"user"`
+}
+
+// VideoPlayResponse is a video played in the room.
+type VideoPlayResponse struct {
+    VideoURL  string    `json:"video_url"`
+    StartedAt time.Time `json:"started_at"`
+}
+
+func newRoomStatsResponse(stats db.RoomStats, users map[uint]model.User) RoomStatsResponse {
+    resp := RoomStatsResponse{
+        PeakParticipants:    stats.PeakParticipants,
+        PeakAt:              stats.PeakAt,
+        AverageParticipants: math.Round(stats.AverageParticipants*100) / 100,
+        TotalWatchSeconds:   int64(stats.TotalWatchTime.Seconds()),
+        Viewers:             make([]ViewerStatsResponse, 0, len(stats.Viewers)),
+        Timeline:            make([]TimelineResponse, 0, len(stats.Timeline)),
+        Plays:               stats.Plays,
+        Pauses:              stats.Pauses,
+        Seeks:               stats.Seeks,
+        VideosPlayed:        len(stats.Videos),
+        Videos:              make([]VideoPlayResponse, 0, len(stats.Videos)),
+    }
+    for _, v := range stats.Viewers {
+        resp.Viewers = append(resp.Viewers, ViewerStatsResponse{
+            User:         statsUser(users, v.UserID),
+            WatchSeconds: int64(v.WatchTime.Seconds()),
+            Visits:       v.Visits,
+        })
+    }
+    for _, entry := range stats.Timeline {
+        resp.Timeline = append(resp.Timeline, TimelineResponse{
+            At:   entry.At,
+            Type: entry.Type,
+            User: statsUser(users, entry.UserID),
+        })
+    }
+    for _, video := range stats.Videos {
+        resp.Videos = append(resp.Videos, VideoPlayResponse{VideoURL: video.VideoURL, StartedAt: video.StartedAt})
+    }
+    return resp
+}
+
+// statsUser summarises userID, who may have deleted their account since.
+func statsUser(users map[uint]model.User, userID uint) UserSummary {
+    user, ok := users[userID]
+    if !ok {
+        user.ID = userID
+    }
+    return newUserSummary(user)
+}
+
+// roomStats loads the stats of the room named by the :code parameter, which
+// only its host may see, along with the users they mention. On failure it
+// writes the error response and returns false.
+func roomStats(c *gin.Context) (model.Room, db.RoomStats, map[uint]model.User, bool) {
+    room, _, ok := hostRoom(c)
+    if !ok {
+        return room, db.RoomStats{}, nil, false
+    }
+
+    stats, err := db.ComputeRoomStats(db.DB, &room)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load stats"})
+        return room, stats, nil, false
+    }
+
+    var ids []uint
+    for _, v := range stats.Viewers {
+        ids = append(ids, v.UserID)
+    }
+    for _, ev := range stats.Playback {
+        if ev.UserID != 0 {
+            ids = append(ids, ev.UserID)
+        }
+    }
+    users := make(map[uint]model.User)
+    if len(ids) > 0 {
+        var found []model.User
+        if err := db.DB.Where("id IN ?", ids).Find(&found).Error; err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load stats"})
+            return room, stats, nil, false
+        }
+        for _, user := range found {
+            users[user.ID] = user
+        }
+    }
+    return room, stats, users, true
+}
+
+// RoomStats returns the host a summary of the room's participation and
+// playback.
+func RoomStats(c *gin.Context) {
+    _, stats, users, ok := roomStats(c)
+    if !ok {
+        return
+    }
+
+    c.JSON(http.StatusOK, newRoomStatsResponse(stats, users))
+}
+
+// RoomStatsCSV exports the room's joins, leaves and playback changes as CSV,
+// one row per event in time order.
+func RoomStatsCSV(c *gin.Context) {
+    room, stats, users, ok := roomStats(c)
+    if !ok {
+        return
+    }
+
+    type row struct {
+        at     time.Time
+        record []string
+    }
+    username := func(userID uint) string {
+        if userID == 0 {
+            return ""
+        }
+        return users[userID].Username
+    }
+    userField := func(userID uint) string {
+        if userID == 0 {
+            return ""
+        }
+        return strconv.FormatUint(uint64(userID), 10)
+    }
+
+    rows := make([]row, 0, len(stats.Timeline)+len(stats.Playback))
+    for _, entry := range stats.Timeline {
+        rows = append(rows, row{entry.At, []string{
+            entry.At.UTC().Format(time.RFC3339), entry.Type,
+            userField(entry.UserID), username(entry.UserID), "", "",
+        }})
+    }
+    for _, ev := range stats.Playback {
+        rows = append(rows, row{ev.At, []string{
+            ev.At.UTC().Format(time.RFC3339), ev.Type,
+            userField(ev.UserID), username(ev.UserID),
+            strconv.FormatFloat(ev.Position, 'f', 3, 64), ev.VideoURL,
+        }})
+    }
+    sort.SliceStable(rows, func(i, j int) bool { return rows[i].at.Before(rows[j].at) })
+
+    records := make([][]string, 0, len(rows)+1)
+    records = append(records, []string{"time", "event", "user_id", "username", "position", "video_url"})
+    for _, r := range rows {
+        records = append(records, r.record)
+    }
+    var buf bytes.Buffer
+    if err := csv.NewWriter(&buf).WriteAll(records); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export stats"})
+        return
+    }
+
+    c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-stats.csv"`, room.Code))
+    c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
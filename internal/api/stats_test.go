@@ -0,0 +1,82 @@
+package api
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestNewRoomStatsResponse(t *testing.T) {
+    stats := db.RoomStats{
+        AverageParticipants: 4.0 / 3,
+        TotalWatchTime:      90*time.Second + 500*time.Millisecond,
+        Viewers:             []db.ViewerStats{{UserID: 1, WatchTime: time.Minute, Visits: 2}, {UserID: 2}},
+        Videos:              []db.VideoPlay{{VideoURL: "https://example.com/a"}},
+    }
+    users := map[uint]model.User{1: {ID: 1, Username: "alice"}}
+
+    resp := newRoomStatsResponse(stats, users)
+    if resp.AverageParticipants != 1.33 || resp.TotalWatchSeconds != 90 || resp.VideosPlayed != 1 {
+        t.Errorf("response = %+v", resp)
+    }
+    if resp.Viewers[0].User.Username != "alice" || resp.Viewers[0].WatchSeconds != 60 || resp.Viewers[0].Visits != 2 {
+        t.Errorf("viewer = %+v", resp.Viewers[0])
+    }
+    // A user who has since deleted their account keeps their ID only.
+    if u := resp.Viewers[1].User; u.ID != 2 || u.Username != "" {
+        t.Errorf("deleted viewer = %+v", u)
+    }
+    if resp.Timeline == nil {
+        t.Error("an empty timeline encodes as null")
+    }
+}
+
+func TestRoomStats(t *testing.T) {
+    conn := testDB(t)
+    host := newTestUser(t, conn, "host")
+    guest := newTestUser(t, conn, "guest")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID})
+    if err := db.JoinRoom(conn, &room, guest.ID, model.RoleViewer); err != nil {
+        t.Fatal(err)
+    }
+    conn.Create(&model.PlaybackEvent{RoomID: room.ID, Type: model.PlaybackPlay, Position: 1.5, VideoURL: "https://example.com/a", At: time.Now()})
+
+    r := testRouter()
+    r.GET("/rooms/:code/stats", RoomStats)
+    r.GET("/rooms/:code/stats.csv", RoomStatsCSV)
+
+    for _, path := range []string{"/stats", "/stats.csv"} {
+        if w := serve(r, http.MethodGet, "/rooms/"+room.Code+path, guest.ID, nil); w.Code != http.StatusForbidden {
+            t.Errorf("guest reads %s: status = %d, want 403", path, w.Code)
+        }
+    }
+
+    w := serve(r, http.MethodGet, "/rooms/"+room.Code+"/stats", host.ID, nil)
+    var resp RoomStatsResponse
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    if w.Code != http.StatusOK || resp.PeakParticipants != 2 || resp.Plays != 1 || len(resp.Viewers) != 2 {
+        t.Errorf("stats: status = %d, body %s", w.Code, w.Body)
+    }
+
+    w = serve(r, http.MethodGet, "/rooms/"+room.Code+"/stats.csv", host.ID, nil)
+    if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+        t.Fatalf("csv: status = %d, headers %v", w.Code, w.Header())
+    }
+    records, err := csv.NewReader(w.Body).ReadAll()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(records) != 4 || strings.Join(records[0], ",") != "time,event,user_id,username,position,video_url" {
+        t.Fatalf("csv = %q", records)
+    }
+    // The scheduler's play has no user.
+    last := records[3]
+    if last[1] != model.PlaybackPlay || last[2] != "" || last[4] != "1.500" {
+        t.Errorf("play row = %q", last)
+    }
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "gorm.io/gorm"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+// RoomTemplateResponse is one of the caller's saved room templates.
+type RoomTemplateResponse struct {
+    ID              uint                   `json:"id"`
+    Name            string                 `json:"name"`
+    RoomName        string                 `json:"room_name"`
+    VideoURL        string                 `json:"video_url"`
+    Visibility      string                 `json:"visibility"`
+    HasPassword     bool                   `json:"has_password"`
+    JoinPolicy      string                 `json:"join_policy"`
+    MaxParticipants int                    `json:"max_participants"` // 0 for the server default
+    ModeratorIDs    []uint                 `json:"moderator_ids"`
+    Queue           []TemplateItemResponse `json:"queue"`
+    CreatedAt       time.Time              `json:"created_at"`
+}
+
+// TemplateItemResponse is a queued video of a room template.
+type TemplateItemResponse struct {
+    VideoURL string `json:"video_url"`
+    Title    string `json:"title"`
+}
+
+func newRoomTemplateResponse(tpl model.RoomTemplate) RoomTemplateResponse {
+    resp := RoomTemplateResponse{
+        ID:              tpl.ID,
+        Name:            tpl.Name,
+        RoomName:        tpl.RoomName,
+        VideoURL:        tpl.VideoURL,
+        Visibility:      tpl.Visibility,
+        HasPassword:     tpl.PasswordHash != "",
+        JoinPolicy:      tpl.JoinPolicy,
+        MaxParticipants: tpl.MaxParticipants,
+        ModeratorIDs:    make([]uint, 0, len(tpl.Moderators)),
+        Queue:           make([]TemplateItemResponse, 0, len(tpl.Queue)),
+        CreatedAt:       tpl.CreatedAt,
+    }
+    for _, m := range tpl.Moderators {
+        resp.ModeratorIDs = append(resp.ModeratorIDs, m.UserID)
+    }
+    for _, item := range tpl.Queue {
+        resp.Queue = append(resp.Queue, TemplateItemResponse{VideoURL: item.VideoURL, Title: item.Title})
+    }
+    return resp
+}
+
+// CloneRoom creates a new room for the host with the settings, video,
+// moderators and queue of an existing one. Playback state and schedule are
+// not copied; a schedule for the new room may be given.
+func CloneRoom(c *gin.Context) {
+    source, _, ok := hostRoom(c)
+    if !ok {
+        return
+    }
+
+    var cloneData CloneRoomRequest
+    if !bindJSON(c, &cloneData, true) {
+        return
+    }
+
+    setup, err := db.RoomSetupOf(db.DB, &source)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load room"})
+        return
+    }
+
+    room := model.Room{
+        Name:            source.Name,
+        VideoURL:        source.VideoURL,
+        Visibility:      source.Visibility,
+        PasswordHash:    source.PasswordHash,
+        Platform:        source.Platform,
+        JoinPolicy:      source.JoinPolicy,
+        MaxParticipants: source.MaxParticipants,
+    }
+    if cloneData.Name != "" {
+        room.Name = cloneData.Name
+    }
+
+    createRoom(c, room, cloneData.Code, cloneData.Schedule, setup)
+}
+
+// SaveRoomTemplate saves the setup of a room the caller hosts as a named
+// template, up to ROOM_TEMPLATE_LIMIT templates per user.
+func SaveRoomTemplate(c *gin.Context) {
+    room, userID, ok := hostRoom(c)
+    if !ok {
+        return
+    }
+
+    var saveData SaveRoomTemplateRequest
+    if !bindJSON(c, &saveData) {
+        return
+    }
+
+    setup, err := db.RoomSetupOf(db.DB, &room)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load room"})
+        return
+    }
+
+    tpl := model.RoomTemplate{
+        UserID:          userID,
+        Name:            saveData.Name,
+        RoomName:        room.Name,
+        VideoURL:        room.VideoURL,
+        Visibility:      room.Visibility,
+        PasswordHash:    room.PasswordHash,
+        JoinPolicy:      room.JoinPolicy,
+        MaxParticipants: room.MaxParticipants,
+    }
+    for _, moderatorID := range setup.Moderators {
+        tpl.Moderators = append(tpl.Moderators, model.RoomTemplateModerator{UserID: moderatorID})
+    }
+    for i, item := range setup.Queue {
+        tpl.Queue = append(tpl.Queue, model.RoomTemplateItem{Position: i + 1, VideoURL: item.VideoURL, Title: item.Title})
+    }
+
+    err = db.SaveRoomTemplate(db.DB, &tpl, config.Int("ROOM_TEMPLATE_LIMIT", 20))
+    switch err {
+    case nil:
+    case db.ErrTemplateExists:
+        c.JSON(http.StatusConflict, gin.H{"error": "You already have a template with this name"})
+        return
+    case db.ErrTemplateLimit:
+        c.JSON(http.StatusConflict, gin.H{"error": "Room template limit reached"})
+        return
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save template"})
+        return
+    }
+
+    c.JSON(http.StatusCreated, newRoomTemplateResponse(tpl))
+}
+
+// ListRoomTemplates returns the caller's room templates by name.
+func ListRoomTemplates(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    templates, err := db.ListRoomTemplates(db.DB, userID.(uint))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list templates"})
+        return
+    }
+
+    resp := make([]RoomTemplateResponse, 0, len(templates))
+    for _, tpl := range templates {
+        resp = append(resp, newRoomTemplateResponse(tpl))
+    }
+    c.JSON(http.StatusOK, resp)
+}
+
+// DeleteRoomTemplate deletes one of the caller's room templates. Rooms
+// created from it are unaffected.
+func DeleteRoomTemplate(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+        return
+    }
+
+    if err := db.DeleteRoomTemplate(db.DB, userID.(uint), uint(id)); err != nil {
+        if err == gorm.ErrRecordNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+// CreateRoomFromTemplate creates a room from one of the caller's templates.
+func CreateRoomFromTemplate(c *gin.Context) {
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return
+    }
+
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+        return
+    }
+
+    var createData CloneRoomRequest
+    if !bindJSON(c, &createData, true) {
+        return
+    }
+
+    tpl, err := db.FindRoomTemplate(db.DB, userID.(uint), uint(id))
+    if err != nil {
+        if err == gorm.ErrRecordNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load template"})
+        return
+    }
+
+    room := model.Room{
+        Name:            tpl.RoomName,
+        VideoURL:        tpl.VideoURL,
+        Visibility:      tpl.Visibility,
+        PasswordHash:    tpl.PasswordHash,
+        Platform:        model.DetectPlatform(tpl.VideoURL),
+        JoinPolicy:      tpl.JoinPolicy,
+        MaxParticipants: tpl.MaxParticipants,
+    }
+    if createData.Name != "" {
+        room.Name = createData.Name
+    }
+
+    var setup db.RoomSetup
+    for _, m := range tpl.Moderators {
+        setup.Moderators = append(setup.Moderators, m.UserID)
+    }
+    for _, item := range tpl.Queue {
+        setup.Queue = append(setup.Queue, model.QueueItem{
+            VideoURL: item.VideoURL,
+            Title:    item.Title,
+            Platform: model.DetectPlatform(item.VideoURL),
+        })
+    }
+
+    createRoom(c, room, createData.Code, createData.Schedule, setup)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "net/http"
+    "testing"
+)
+
+func TestRoomTemplates(t *testing.T) {
+    conn := testDB(t)
+    t.Setenv("ROOM_TEMPLATE_LIMIT", "1")
+    host := newTestUser(t, conn, "host")
+    mod := newTestUser(t, conn, "mod")
+    room := newTestRoom(t, conn, model.Room{HostID: host.ID, Name: "Friday film club", JoinPolicy: model.JoinApproval, MaxParticipants: 5})
+    db.AddParticipant(conn, room.ID, mod.ID, model.RoleModerator)
+    item := model.QueueItem{RoomID: room.ID, VideoURL: "https://vimeo.com/76979871", Title: "Short", Platform: model.PlatformVimeo}
+    if err := db.AddToQueue(conn, &item, 10); err != nil {
+        t.Fatal(err)
+    }
+
+    r := testRouter()
+    r.POST("/rooms/:code/clone", CloneRoom)
+    r.POST("/rooms/:code/template", SaveRoomTemplate)
+    r.GET("/templates", ListRoomTemplates)
+    r.DELETE("/templates/:id", DeleteRoomTemplate)
+    r.POST("/templates/:id/rooms", CreateRoomFromTemplate)
+
+    if w := serve(r, http.MethodPost, "/rooms/"+room.Code+"/template", mod.ID, gin.H{"name": "Friday"}); w.Code != http.StatusForbidden {
+        t.Errorf("moderator saves: status = %d, want 403", w.Code)
+    }
+    w := serve(r, http.MethodPost, "/rooms/"+room.Code+"/template", host.ID, gin.H{"name": "Friday"})
+    if w.Code != http.StatusCreated {
+        t.Fatalf("save: status = %d, body %s", w.Code, w.Body)
+    }
+    var tpl RoomTemplateResponse
+    json.Unmarshal(w.Body.Bytes(), &tpl)
+    if tpl.RoomName != "Friday film club" || tpl.JoinPolicy != model.JoinApproval || tpl.MaxParticipants != 5 ||
+        len(tpl.ModeratorIDs) != 1 || tpl.ModeratorIDs[0] != mod.ID || len(tpl.Queue) != 1 || tpl.Queue[0].Title != "Short" {
+        t.Errorf("template = %+v", tpl)
+    }
+    if w := serve(r, http.MethodPost, "/rooms/"+room.Code+"/template", host.ID, gin.H{"name": "Friday"}); w.Code != http.StatusConflict {
+        t.Errorf("duplicate name: status = %d, want 409", w.Code)
+    }
+    if w := serve(r, http.MethodPost, "/rooms/"+room.Code+"/template", host.ID, gin.H{"name": "Sunday"}); w.Code != http.StatusConflict {
+        t.Errorf("past the limit: status = %d, want 409", w.Code)
+    }
+
+    var list []RoomTemplateResponse
+    json.Unmarshal(serve(r, http.MethodGet, "/templates", host.ID, nil).Body.Bytes(), &list)
+    if len(list) != 1 || list[0].ID != tpl.ID {
+        t.Errorf("templates = %+v", list)
+    }
+    json.Unmarshal(serve(r, http.MethodGet, "/templates", mod.ID, nil).Body.Bytes(), &list)
+    if len(list) != 0 {
+        t.Errorf("another user sees templates %+v", list)
+    }
+
+    fromTemplate := fmt.Sprintf("/templates/%d/rooms", tpl.ID)
+    if w := serve(r, http.MethodPost, fromTemplate, mod.ID, gin.H{}); w.Code != http.StatusNotFound {
+        t.Errorf("using another user's template: status = %d, want 404", w.Code)
+    }
+    w = serve(r, http.MethodPost, fromTemplate, host.ID, gin.H{"name": "Next Friday"})
+    if w.Code != http.StatusCreated {
+        t.Fatalf("create from template: status = %d, body %s", w.Code, w.Body)
+    }
+    assertCopied(t, w.Body.Bytes(), "Next Friday", mod.ID)
+
+    w = serve(r, http.MethodPost, "/rooms/"+room.Code+"/clone", host.ID, gin.H{})
+    if w.Code != http.StatusCreated {
+        t.Fatalf("clone: status = %d, body %s", w.Code, w.Body)
+    }
+    assertCopied(t, w.Body.Bytes(), "Friday film club", mod.ID)
+
+    if w := serve(r, http.MethodDelete, "/templates/x", host.ID, nil); w.Code != http.StatusBadRequest {
+        t.Errorf("bad template ID: status = %d, want 400", w.Code)
+    }
+    if w := serve(r, http.MethodDelete, fmt.Sprintf("/templates/%d", tpl.ID), host.ID, nil); w.Code != http.StatusNoContent {
+        t.Fatalf("delete: status = %d", w.Code)
+    }
+    if w := serve(r, http.MethodPost, fromTemplate, host.ID, gin.H{}); w.Code != http.StatusNotFound {
+        t.Errorf("deleted template: status = %d, want 404", w.Code)
+    }
+}
+
+// assertCopied checks that the room in body was created under name with
+// the source room's join policy, moderator and queue.
+func assertCopied(t *testing.T, body []byte, name string, moderatorID uint) {
+    t.Helper()
+    var resp RoomResponse
+    json.Unmarshal(body, &resp)
+    if resp.Name != name || resp.JoinPolicy != model.JoinApproval || resp.MaxParticipants != 5 {
+        t.Errorf("room = %+v", resp)
+    }
+    setup, err := db.RoomSetupOf(db.DB, &model.Room{ID: resp.ID, HostID: resp.HostID})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(setup.Moderators) != 1 || setup.Moderators[0] != moderatorID {
+        t.Errorf("moderators = %v, want [%d]", setup.Moderators, moderatorID)
+    }
+    if len(setup.Queue) != 1 || setup.Queue[0].Title != "Short" {
+        t.Errorf("queue = %+v", setup.Queue)
+    }
+}
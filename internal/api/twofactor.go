@@ -0,0 +1,294 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/golang-jwt/jwt/v5"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/totp"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+// challengeTTL is how long a user has to enter their second factor.
+const challengeTTL = 5 * time.Minute
+
+const challengePurpose = "2fa_challenge"
+
+// newChallenge returns a short-lived challenge token for user to redeem
+// with their second factor. The challenge has no session behind it, so
+// AuthMiddleware never accepts it.
+func newChallenge(user model.User) (string, error) {
+    token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+        "sub":     strconv.FormatUint(uint64(user.ID), 10),
+        "purpose": challengePurpose,
+        "exp":     time.Now().Add(challengeTTL).Unix(),
+    })
+    return token.SignedString(config.JWTSecret())
+}
+
+// writeTwoFactorChallenge answers a correct password for a 2FA account with
+// a challenge token instead of a session.
+func writeTwoFactorChallenge(c *gin.Context, user model.User) {
+    challenge, err := newChallenge(user)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "two_factor_required": true,
+        "challenge_token":     challenge,
+        "expires_in":          int(challengeTTL.Seconds()),
+    })
+}
+
+func parseChallenge(challenge string) (uint, bool) {
+    claims := jwt.MapClaims{}
+    _, err := jwt.ParseWithClaims(challenge, claims, func(token *jwt.Token) (interface{}, error) {
+        return config.JWTSecret(), nil
+    }, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+    if err != nil || claims["purpose"] != challengePurpose {
+        return 0, false
+    }
+    sub, _ := claims["sub"].(string)
+    id, err := strconv.ParseUint(sub, 10, 64)
+    if err != nil {
+        return 0, false
+    }
+    return uint(id), true
+}
+
+// checkSecondFactor verifies a TOTP code, or failing that a recovery code,
+// for user.
+func checkSecondFactor(user *model.User, code, recoveryCode string) (bool, error) {
+    if code != "" {
+        return db.VerifyTOTP(db.DB, user, code)
+    }
+    if recoveryCode != "" {
+        return db.UseRecoveryCode(db.DB, user.ID, recoveryCode)
+    }
+    return false, nil
+}
+
+func LoginTwoFactor(c *gin.Context) {
+    var loginData TwoFactorLoginRequest
+    if !bindJSON(c, &loginData) {
+        return
+    }
+
+    userID, ok := parseChallenge(loginData.ChallengeToken)
+    if !ok {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or expired"})
+        return
+    }
+
+    var user model.User
+    if err := db.DB.First(&user, userID).Error; err != nil || !user.TOTPEnabled {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or expired"})
+        return
+    }
+
+    // Second factor guesses count against the same lockout as passwords.
+    if loginLocked(c, user.Username) {
+        return
+    }
+
+    ok, err := checkSecondFactor(&user, loginData.Code, loginData.RecoveryCode)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+        return
+    }
+    if !ok {
+        recordLoginFailure(c, user.Username)
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+        return
+    }
+
+    recordLoginSuccess(user)
+    writeLoginToken(c, user)
+}
+
+// currentUser loads the authenticated user, writing an error response and
+// returning false if that fails.
+func currentUser(c *gin.Context) (model.User, bool) {
+    var user model.User
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        return user, false
+    }
+    if err := db.DB.First(&user, userID).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return user, false
+    }
+    return user, true
+}
+
+func SetupTOTP(c *gin.Context) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    if user.TOTPEnabled {
+        c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+        return
+    }
+
+    secret, err := totp.GenerateSecret()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication"})
+        return
+    }
+    if err := db.BeginTOTP(db.DB, user.ID, secret); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication"})
+        return
+    }
+
+    account := user.Email
+    if account == "" {
+        account = user.Username
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "secret":      secret,
+        "otpauth_uri": totp.URI(config.String("TOTP_ISSUER", "Videoparty"), account, secret),
+    })
+}
+
+func EnableTOTP(c *gin.Context) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+
+    var enableData TOTPCodeRequest
+    if !bindJSON(c, &enableData) {
+        return
+    }
+
+    if user.TOTPEnabled {
+        c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+        return
+    }
+    if user.TOTPSecret == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+        return
+    }
+
+    valid, err := db.VerifyTOTP(db.DB, &user, enableData.Code)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+        return
+    }
+    if !valid {
+        writeFieldError(c, "code", "invalid", "does not match your authenticator app")
+        return
+    }
+
+    codes, err := db.EnableTOTP(db.DB, user.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+        return
+    }
+
+    // Recovery codes are only ever shown here and on regeneration.
+    c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func DisableTOTP(c *gin.Context) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+
+    var disableData DisableTOTPRequest
+    if !bindJSON(c, &disableData) {
+        return
+    }
+
+    if !user.TOTPEnabled {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+        return
+    }
+
+    valid, err := checkSecondFactor(&user, disableData.Code, disableData.RecoveryCode)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+        return
+    }
+    if !valid {
+        writeFieldError(c, "code", "invalid", "is not a valid authentication or recovery code")
+        return
+    }
+
+    if err := db.DisableTOTP(db.DB, user.ID); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+
+    var regenData TOTPCodeRequest
+    if !bindJSON(c, &regenData) {
+        return
+    }
+
+    if !user.TOTPEnabled {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+        return
+    }
+
+    valid, err := db.VerifyTOTP(db.DB, &user, regenData.Code)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+        return
+    }
+    if !valid {
+        writeFieldError(c, "code", "invalid", "does not match your authenticator app")
+        return
+    }
+
+    codes, err := db.RegenerateRecoveryCodes(db.DB, user.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// AdminResetTOTP turns off two-factor authentication for a user who has lost
+// both their device and their recovery codes.
+func AdminResetTOTP(c *gin.Context) {
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        return
+    }
+
+    var user model.User
+    if err := db.DB.First(&user, id).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+
+    if err := db.DisableTOTP(db.DB, user.ID); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+        return
+    }
+
+    adminID, _ := c.Get("userID")
+    log.Printf("Admin %v reset two-factor authentication for user %d", adminID, user.ID)
+
+    c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "github.com/golang-jwt/jwt/v5"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/totp"
+    "gorm.io/gorm"
+    "net/http"
+    "net/url"
+    "strconv"
+    "testing"
+    "time"
+)
+
+func TestParseChallenge(t *testing.T) {
+    challenge, err := newChallenge(model.User{ID: 7})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if id, ok := parseChallenge(challenge); !ok || id != 7 {
+        t.Fatalf("parseChallenge = %d, %v; want 7", id, ok)
+    }
+
+    sign := func(claims jwt.MapClaims, method jwt.SigningMethod, key interface{}) string {
+        raw, err := jwt.NewWithClaims(method, claims).SignedString(key)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return raw
+    }
+    exp := time.Now().Add(time.Minute).Unix()
+    tests := []struct {
+        name  string
+        token string
+    }{
+        {"session token", sign(jwt.MapClaims{"sub": "7", "exp": exp}, jwt.SigningMethodHS256, config.JWTSecret())},
+        {"other purpose", sign(jwt.MapClaims{"sub": "7", "purpose": "email", "exp": exp}, jwt.SigningMethodHS256, config.JWTSecret())},
+        {"expired", sign(jwt.MapClaims{"sub": "7", "purpose": challengePurpose, "exp": time.Now().Add(-time.Minute).Unix()}, jwt.SigningMethodHS256, config.JWTSecret())},
+        {"no expiry", sign(jwt.MapClaims{"sub": "7", "purpose": challengePurpose}, jwt.SigningMethodHS256, config.JWTSecret())},
+        {"other secret", sign(jwt.MapClaims{"sub": "7", "purpose": challengePurpose, "exp": exp}, jwt.SigningMethodHS256, []byte("not the secret"))},
+        {"unsigned", sign(jwt.MapClaims{"sub": "7", "purpose": challengePurpose, "exp": exp}, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType)},
+        {"bad subject", sign(jwt.MapClaims{"sub": "seven", "purpose": challengePurpose, "exp": exp}, jwt.SigningMethodHS256, config.JWTSecret())},
+        {"garbage", "not.a.token"},
+    }
+    for _, tt := range tests {
+        if _, ok := parseChallenge(tt.token); ok {
+            t.Errorf("%s: challenge accepted", tt.name)
+        }
+    }
+}
+
+// enableTOTP turns on 2FA for user and returns its secret.
+func enableTOTP(t *testing.T, conn *gorm.DB, user model.User) string {
+    t.Helper()
+    secret, err := totp.GenerateSecret()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := db.BeginTOTP(conn, user.ID, secret); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := db.EnableTOTP(conn, user.ID); err != nil {
+        t.Fatal(err)
+    }
+    return secret
+}
+
+// wrongCode returns a code that secret does not produce around now.
+func wrongCode(secret string) string {
+    step := totp.Step(time.Now())
+    for n := 0; ; n++ {
+        code := fmt.Sprintf("%06d", n)
+        valid := false
+        for s := step - 2; s <= step+2; s++ {
+            if want, _ := totp.Code(secret, s); want == code {
+                valid = true
+            }
+        }
+        if !valid {
+            return code
+        }
+    }
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+    conn := testDB(t)
+    user := newTestUser(t, conn, "alice")
+    secret := enableTOTP(t, conn, user)
+    challenge, _ := newChallenge(user)
+
+    r := testRouter()
+    r.POST("/login/2fa", LoginTwoFactor)
+
+    w := serve(r, http.MethodPost, "/login/2fa", 0, TwoFactorLoginRequest{ChallengeToken: challenge, Code: wrongCode(secret)})
+    if w.Code != http.StatusUnauthorized {
+        t.Fatalf("wrong code: status = %d, body %s", w.Code, w.Body)
+    }
+
+    code, _ := totp.Code(secret, totp.Step(time.Now()))
+    w = serve(r, http.MethodPost, "/login/2fa", 0, TwoFactorLoginRequest{ChallengeToken: challenge, Code: code})
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    var resp struct {
+        Token string `json:"token"`
+    }
+    if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Token == "" {
+        t.Fatalf("response %s has no token", w.Body)
+    }
+    if _, ok := parseChallenge(resp.Token); ok {
+        t.Error("the session token passes as a challenge")
+    }
+
+    w = serve(r, http.MethodPost, "/login/2fa", 0, TwoFactorLoginRequest{ChallengeToken: challenge, Code: code})
+    if w.Code != http.StatusUnauthorized {
+        t.Errorf("replayed code: status = %d, want 401", w.Code)
+    }
+}
+
+func TestLoginTwoFactorNeedsEnabledTOTP(t *testing.T) {
+    conn := testDB(t)
+    user := newTestUser(t, conn, "alice")
+    challenge, _ := newChallenge(user)
+
+    r := testRouter()
+    r.POST("/login/2fa", LoginTwoFactor)
+    w := serve(r, http.MethodPost, "/login/2fa", 0, TwoFactorLoginRequest{ChallengeToken: challenge, RecoveryCode: "abcde-fghjk"})
+    if w.Code != http.StatusUnauthorized {
+        t.Errorf("status = %d, want 401", w.Code)
+    }
+}
+
+func TestOIDCCallbackAsksForSecondFactor(t *testing.T) {
+    conn := testDB(t)
+    idp := withOIDCProvider(t)
+    r := oidcRouter()
+
+    user := newTestUser(t, conn, "alice")
+    enableTOTP(t, conn, user)
+    if err := conn.Create(&model.UserIdentity{UserID: user.ID, Issuer: idp.URL, Subject: "alice-1"}).Error; err != nil {
+        t.Fatal(err)
+    }
+
+    state, cookie := startOIDCLogin(t, r)
+    authReq, _ := readOIDCCookieValue(t, cookie)
+    idp.login(authReq.Nonce, "alice-1", "")
+
+    values := callbackFragment(t, r, "state="+url.QueryEscape(state)+"&code=c", cookie)
+    if values.Get("token") != "" {
+        t.Fatal("the provider login skipped the second factor")
+    }
+    if values.Get("two_factor_required") != "true" {
+        t.Fatalf("fragment = %v, want a 2FA challenge", values)
+    }
+    if id, ok := parseChallenge(values.Get("challenge_token")); !ok || id != user.ID {
+        t.Errorf("challenge is for user %d, %v; want %d", id, ok, user.ID)
+    }
+    if values.Get("expires_in") != strconv.Itoa(int(challengeTTL.Seconds())) {
+        t.Errorf("expires_in = %q", values.Get("expires_in"))
+    }
+}
+
+func TestOIDCCallbackLogsIn(t *testing.T) {
+    testDB(t)
+    idp := withOIDCProvider(t)
+    r := oidcRouter()
+
+    state, cookie := startOIDCLogin(t, r)
+    authReq, _ := readOIDCCookieValue(t, cookie)
+    idp.login(authReq.Nonce, "bob-1", "bob@example.com")
+
+    values := callbackFragment(t, r, "state="+url.QueryEscape(state)+"&code=c", cookie)
+    if values.Get("token") == "" || values.Get("error") != "" {
+        t.Fatalf("fragment = %v, want a session token", values)
+    }
+}
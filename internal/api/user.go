@@ -2,65 +2,165 @@ package api
 
 import (
     "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/config"
     "github.com/spacelord16/Videoparty/internal/model"
     "github.com/spacelord16/Videoparty/internal/db"
+    "log"
+    "math"
     "net/http"
+    "strconv"
+    "strings"
     "github.com/golang-jwt/jwt/v5"
+    "gorm.io/gorm"
     "time"
-    "golang.org/x/crypto/bcrypt"
+    "github.com/spacelord16/Videoparty/internal/password"
 )
 
+// issueToken records a session for the request and creates the session JWT
+// returned by every login method.
+func issueToken(c *gin.Context, user model.User) (string, error) {
+    ttl := config.Duration("SESSION_TTL", 24*time.Hour)
+    session := model.Session{
+        UserID:    user.ID,
+        UserAgent: c.Request.UserAgent(),
+        IP:        c.ClientIP(),
+    }
+    if err := db.CreateSession(db.DB, &session, ttl); err != nil {
+        return "", err
+    }
+
+    token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+        "user_id": user.ID,
+        "jti":     session.JTI,
+        "exp":     session.ExpiresAt.Unix(),
+    })
+
+    return token.SignedString(config.JWTSecret())
+}
+
+// writeDuplicateUserError answers 409 for the duplicate errors returned by
+// db.CreateUser and db.CheckUserAvailable, and reports whether err was one.
+func writeDuplicateUserError(c *gin.Context, err error) bool {
+    switch err {
+    case db.ErrUsernameTaken:
+        c.JSON(http.StatusConflict, gin.H{"error": "Username is already taken"})
+    case db.ErrEmailTaken:
+        c.JSON(http.StatusConflict, gin.H{"error": "Email is already registered"})
+    case gorm.ErrDuplicatedKey:
+        c.JSON(http.StatusConflict, gin.H{"error": "Username or email is already registered"})
+    default:
+        return false
+    }
+    return true
+}
+
 func Register(c *gin.Context) {
-    var user model.User
-    if err := c.ShouldBindJSON(&user); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    var registerData RegisterRequest
+    if !bindJSON(c, &registerData) {
         return
     }
 
-    if err := db.CreateUser(db.DB, user); err != nil {
+    if err := password.Validate(registerData.Password); err != nil {
+        writeFieldError(c, "password", "password_policy", err.Error())
+        return
+    }
+
+    user := model.User{
+        Username: registerData.Username,
+        Email:    registerData.Email,
+        Password: registerData.Password,
+    }
+
+    if err := db.CreateUser(db.DB, &user); err != nil {
+        if writeDuplicateUserError(c, err) {
+            return
+        }
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
         return
     }
 
+    sendVerificationEmail(user)
+
     c.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
 }
 
+// loginLocked answers 429 and returns true if login attempts for login from
+// this client are currently locked out.
+func loginLocked(c *gin.Context, login string) bool {
+    wait, err := db.LoginLockedFor(db.DB, login, c.ClientIP())
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+        return true
+    }
+    if wait > 0 {
+        retryAfter := int(math.Ceil(wait.Seconds()))
+        c.Header("Retry-After", strconv.Itoa(retryAfter))
+        c.JSON(http.StatusTooManyRequests, gin.H{
+            "error":       "Too many failed login attempts, try again later",
+            "retry_after": retryAfter,
+        })
+        return true
+    }
+    return false
+}
+
+func recordLoginFailure(c *gin.Context, login string) {
+    if err := db.RecordLoginFailure(db.DB, login, c.ClientIP()); err != nil {
+        log.Printf("Error recording failed login: %v", err)
+    }
+}
+
+func recordLoginSuccess(user model.User) {
+    if err := db.RecordLoginSuccess(db.DB, user.ID); err != nil {
+        log.Printf("Error clearing failed logins: %v", err)
+    }
+}
+
+// writeLoginToken answers a completed login with a new session token.
+func writeLoginToken(c *gin.Context, user model.User) {
+    tokenString, err := issueToken(c, user)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "token": tokenString,
+        "user":  newUserResponse(user),
+    })
+}
+
 func Login(c *gin.Context) {
-    var loginData struct {
-        Username string `json:"username"`
-        Password string `json:"password"`
+    var loginData LoginRequest
+    if !bindJSON(c, &loginData) {
+        return
     }
 
-    if err := c.ShouldBindJSON(&loginData); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    if loginLocked(c, loginData.Username) {
         return
     }
 
     user, err := db.AuthenticateUser(db.DB, loginData.Username, loginData.Password)
     if err != nil {
+        if err != db.ErrInvalidCredentials {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+            return
+        }
+        recordLoginFailure(c, loginData.Username)
         c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
         return
     }
 
-    // Create JWT token
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-        "user_id": user.ID,
-        "exp":     time.Now().Add(time.Hour * 24).Unix(),
-    })
-
-    tokenString, err := token.SignedString([]byte("your-secret-key")) // Use environment variable in production
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+    // With two-factor enabled the failure count is only cleared once the
+    // second factor is also correct, so code guesses can't be reset by
+    // logging in with the password again.
+    if user.TOTPEnabled {
+        writeTwoFactorChallenge(c, user)
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{
-        "token": tokenString,
-        "user": gin.H{
-            "id":       user.ID,
-            "username": user.Username,
-        },
-    })
+    recordLoginSuccess(user)
+    writeLoginToken(c, user)
 }
 
 func GetUser(c *gin.Context) {
@@ -76,10 +176,7 @@ func GetUser(c *gin.Context) {
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{
-        "id":       user.ID,
-        "username": user.Username,
-    })
+    c.JSON(http.StatusOK, newUserResponse(user))
 }
 
 func UpdateUser(c *gin.Context) {
@@ -89,13 +186,8 @@ func UpdateUser(c *gin.Context) {
         return
     }
 
-    var updateData struct {
-        Username string `json:"username"`
-        Password string `json:"password"`
-    }
-
-    if err := c.ShouldBindJSON(&updateData); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    var updateData UpdateUserRequest
+    if !bindJSON(c, &updateData) {
         return
     }
 
@@ -105,25 +197,57 @@ func UpdateUser(c *gin.Context) {
         return
     }
 
-    if updateData.Username != "" {
+    if updateData.Username != "" && updateData.Username != user.Username {
+        if err := db.CheckUserAvailable(db.DB, user.ID, updateData.Username, ""); err != nil {
+            if writeDuplicateUserError(c, err) {
+                return
+            }
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+            return
+        }
         user.Username = updateData.Username
     }
+    if updateData.DisplayName != nil {
+        user.DisplayName = strings.TrimSpace(*updateData.DisplayName)
+    }
+    if updateData.Bio != nil {
+        user.Bio = strings.TrimSpace(*updateData.Bio)
+    }
     if updateData.Password != "" {
-        hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updateData.Password), bcrypt.DefaultCost)
+        if user.Password != "" {
+            ok, _, err := password.Verify(user.Password, updateData.CurrentPassword)
+            if err != nil || !ok {
+                writeFieldError(c, "current_password", "invalid", "does not match your current password")
+                return
+            }
+        }
+        if err := password.Validate(updateData.Password); err != nil {
+            writeFieldError(c, "password", "password_policy", err.Error())
+            return
+        }
+        hashedPassword, err := password.Hash(updateData.Password)
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
             return
         }
-        user.Password = string(hashedPassword)
+        user.Password = hashedPassword
     }
 
     if err := db.DB.Save(&user).Error; err != nil {
+        if writeDuplicateUserError(c, err) {
+            return
+        }
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{
-        "id":       user.ID,
-        "username": user.Username,
-    })
+    // A new password signs out every other device.
+    if updateData.Password != "" {
+        sessionID, _ := c.Get("sessionID")
+        if err := db.RevokeOtherSessions(db.DB, user.ID, sessionID.(uint)); err != nil {
+            log.Printf("Error revoking sessions of user %d: %v", user.ID, err)
+        }
+    }
+
+    c.JSON(http.StatusOK, newUserResponse(user))
 }
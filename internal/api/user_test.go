@@ -0,0 +1,47 @@
+package api
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "github.com/spacelord16/Videoparty/internal/password"
+    "net/http"
+    "testing"
+)
+
+func TestUpdateUserPasswordNeedsCurrentPassword(t *testing.T) {
+    conn := testDB(t)
+    user := newTestUser(t, conn, "alice")
+    hash, err := password.Hash("a long and unusual passphrase 42")
+    if err != nil {
+        t.Fatal(err)
+    }
+    conn.Model(&user).Update("password", hash)
+
+    r := testRouter()
+    r.Use(func(c *gin.Context) { c.Set("sessionID", uint(0)) })
+    r.PUT("/user", UpdateUser)
+
+    const newPassword = "another long and unusual passphrase 43"
+    for _, body := range []gin.H{
+        {"password": newPassword},
+        {"password": newPassword, "current_password": "wrong"},
+    } {
+        if w := serve(r, http.MethodPut, "/user", user.ID, body); w.Code != http.StatusBadRequest {
+            t.Fatalf("%v: status = %d, body %s", body, w.Code, w.Body)
+        }
+    }
+    var stored model.User
+    conn.First(&stored, user.ID)
+    if stored.Password != hash {
+        t.Fatal("the password changed without the current one")
+    }
+
+    w := serve(r, http.MethodPut, "/user", user.ID, gin.H{"password": newPassword, "current_password": "a long and unusual passphrase 42"})
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", w.Code, w.Body)
+    }
+    conn.First(&stored, user.ID)
+    if ok, _, _ := password.Verify(stored.Password, newPassword); !ok {
+        t.Error("the password was not changed")
+    }
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "github.com/gin-gonic/gin"
+    "github.com/gin-gonic/gin/binding"
+    "github.com/go-playground/validator/v10"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "io"
+    "net/http"
+    "net/url"
+    "reflect"
+    "regexp"
+    "strings"
+    "unicode"
+)
+
+// FieldError describes one invalid field of a request body.
+type FieldError struct {
+    Field   string `json:"field"`
+    Code    string `json:"code"`
+    Message string `json:"message"`
+}
+
+// roomCodePattern is what a vanity room code may look like.
+var roomCodePattern = regexp.MustCompile(`^[A-Za-z0-9-]{4,20}$`)
+
+func init() {
+    v, ok := binding.Validator.Engine().(*validator.Validate)
+    if !ok {
+        return
+    }
+
+    // Report fields by their JSON names.
+    v.RegisterTagNameFunc(func(f reflect.StructField) string {
+        name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+        if name == "-" {
+            return ""
+        }
+        return name
+    })
+
+    v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+        return model.ValidUsername(fl.Field().String())
+    })
+    v.RegisterValidation("roomcode", func(fl validator.FieldLevel) bool {
+        return roomCodePattern.MatchString(fl.Field().String())
+    })
+    v.RegisterValidation("roomname", func(fl validator.FieldLevel) bool {
+        name := fl.Field().String()
+        if strings.TrimSpace(name) == "" {
+            return false
+        }
+        for _, r := range name {
+            if !unicode.IsPrint(r) {
+                return false
+            }
+        }
+        return true
+    })
+    v.RegisterValidation("videourl", func(fl validator.FieldLevel) bool {
+        u, err := url.Parse(fl.Field().String())
+        return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+    })
+    v.RegisterValidation("visibility", func(fl validator.FieldLevel) bool {
+        return validVisibility(fl.Field().String())
+    })
+    v.RegisterValidation("platform", func(fl validator.FieldLevel) bool {
+        return model.ValidPlatform(fl.Field().String())
+    })
+    v.RegisterValidation("joinpolicy", func(fl validator.FieldLevel) bool {
+        switch fl.Field().String() {
+        case model.JoinOpen, model.JoinApproval, model.JoinInvite:
+            return true
+        }
+        return false
+    })
+    v.RegisterValidation("role", func(fl validator.FieldLevel) bool {
+        role := fl.Field().String()
+        return role == model.RoleViewer || role == model.RoleModerator
+    })
+    v.RegisterValidation("scope", func(fl validator.FieldLevel) bool {
+        return model.ValidScope(fl.Field().String())
+    })
+}
+
+// fieldErrorMessage turns a validator failure into a sentence for clients.
+func fieldErrorMessage(fe validator.FieldError) string {
+    switch fe.Tag() {
+    case "required":
+        return "is required"
+    case "required_without":
+        return fmt.Sprintf("is required when %s is not given", snakeCase(fe.Param()))
+    case "email":
+        return "must be a valid email address"
+    case "min", "gte":
+        if fe.Kind() == reflect.String || fe.Kind() == reflect.Slice {
+            return fmt.Sprintf("must have at least %s items or characters", fe.Param())
+        }
+        return fmt.Sprintf("must be at least %s", fe.Param())
+    case "max", "lte":
+        if fe.Kind() == reflect.String || fe.Kind() == reflect.Slice {
+            return fmt.Sprintf("must have at most %s items or characters", fe.Param())
+        }
+        return fmt.Sprintf("must be at most %s", fe.Param())
+    case "oneof":
+        return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+    case "username":
+        return "must be 3-32 letters, digits, dots, dashes or underscores"
+    case "roomcode":
+        return "must be 4-20 letters, digits or dashes"
+    case "roomname":
+        return "must contain visible characters"
+    case "videourl":
+        return "must be an http or https URL"
+    case "len=0|videourl":
+        return "must be empty or an http or https URL"
+    case "len=0|min=4":
+        return "must be empty or at least 4 characters"
+    case "visibility":
+        return "must be public, unlisted or private"
+    case "platform":
+        return "must be youtube, vimeo, twitch or other"
+    case "joinpolicy":
+        return "must be open, approval or invite"
+    case "role":
+        return "must be viewer or moderator"
+    case "scope":
+        return "must be a known scope"
+    case "timezone":
+        return "must be an IANA timezone"
+    case "ip":
+        return "must be an IP address"
+    case "numeric":
+        return "must contain only digits"
+    case "len":
+        return fmt.Sprintf("must be exactly %s characters", fe.Param())
+    }
+    return "is invalid"
+}
+
+// snakeCase turns a Go field name such as RecoveryCode into its JSON form.
+func snakeCase(s string) string {
+    var b strings.Builder
+    for i, r := range s {
+        if unicode.IsUpper(r) {
+            if i > 0 && unicode.IsLower(rune(s[i-1])) {
+                b.WriteByte('_')
+            }
+            r = unicode.ToLower(r)
+        }
+        b.WriteRune(r)
+    }
+    return b.String()
+}
+
+// writeValidationErrors answers 400 with the list of invalid fields.
+func writeValidationErrors(c *gin.Context, errs []FieldError) {
+    c.JSON(http.StatusBadRequest, gin.H{
+        "error":  "Validation failed",
+        "errors": errs,
+    })
+}
+
+// writeFieldError answers 400 for a single invalid field, for checks made
+// outside the binding rules.
+func writeFieldError(c *gin.Context, field, code, message string) {
+    writeValidationErrors(c, []FieldError{{Field: field, Code: code, Message: message}})
+}
+
+// bindJSON binds and validates the request body into req. On failure it
+// writes a 400 listing every invalid field and returns false. An empty body
+// is accepted when optional is set.
+func bindJSON(c *gin.Context, req interface{}, optional ...bool) bool {
+    err := c.ShouldBindJSON(req)
+    if err == nil {
+        return true
+    }
+    if errors.Is(err, io.EOF) {
+        if len(optional) > 0 && optional[0] {
+            // Still validate the zero value, so required fields are caught.
+            if err = binding.Validator.ValidateStruct(req); err == nil {
+                return true
+            }
+        } else {
+            writeFieldError(c, "", "invalid_json", "request body is required")
+            return false
+        }
+    }
+
+    if writeBindingErrors(c, err) {
+        return false
+    }
+
+    var typeErr *json.UnmarshalTypeError
+    if errors.As(err, &typeErr) {
+        writeFieldError(c, typeErr.Field, "type", "must be a "+typeErr.Type.String())
+        return false
+    }
+
+    writeFieldError(c, "", "invalid_json", "request body is not valid JSON")
+    return false
+}
+
+// bindQuery binds the query string into req and validates it. Like bindJSON,
+// it writes a 400 response and returns false on failure.
+func bindQuery(c *gin.Context, req interface{}) bool {
+    err := c.ShouldBindQuery(req)
+    if err == nil {
+        return true
+    }
+    if writeBindingErrors(c, err) {
+        return false
+    }
+    writeFieldError(c, "", "invalid_query", err.Error())
+    return false
+}
+
+// writeBindingErrors answers 400 with the fields err reports as invalid, if
+// err is a validation failure.
+func writeBindingErrors(c *gin.Context, err error) bool {
+    var verrs validator.ValidationErrors
+    if !errors.As(err, &verrs) {
+        return false
+    }
+    errs := make([]FieldError, 0, len(verrs))
+    for _, fe := range verrs {
+        field := fe.Namespace()
+        // Drop the struct name prefix, keeping nested paths.
+        if _, rest, found := strings.Cut(field, "."); found {
+            field = rest
+        }
+        errs = append(errs, FieldError{
+            Field:   field,
+            Code:    fe.Tag(),
+            Message: fieldErrorMessage(fe),
+        })
+    }
+    writeValidationErrors(c, errs)
+    return true
+}
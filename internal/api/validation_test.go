@@ -0,0 +1,133 @@
+package api
+
+import (
+    "encoding/json"
+    "github.com/gin-gonic/gin"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+// bindResult runs bindJSON on body into a fresh value of the request type
+// made by newReq and returns the field errors it reported.
+func bindResult(t *testing.T, body string, newReq func() interface{}, optional bool) (bool, []FieldError) {
+    t.Helper()
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+    c.Request.Header.Set("Content-Type", "application/json")
+
+    if bindJSON(c, newReq(), optional) {
+        return true, nil
+    }
+    if w.Code != http.StatusBadRequest {
+        t.Fatalf("status %d, want 400", w.Code)
+    }
+    var resp struct {
+        Errors []FieldError `json:"errors"`
+    }
+    if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+        t.Fatal(err)
+    }
+    return false, resp.Errors
+}
+
+func TestBindJSONReportsFields(t *testing.T) {
+    ok, errs := bindResult(t, `{"username":"a b","email":"nope","password":""}`,
+        func() interface{} { return &RegisterRequest{} }, false)
+    if ok {
+        t.Fatal("invalid registration accepted")
+    }
+
+    got := make(map[string]string)
+    for _, fe := range errs {
+        got[fe.Field] = fe.Code
+        if fe.Message == "" {
+            t.Errorf("%s: no message", fe.Field)
+        }
+    }
+    want := map[string]string{"username": "username", "email": "email", "password": "required"}
+    for field, code := range want {
+        if got[field] != code {
+            t.Errorf("%s: code %q, want %q (all: %+v)", field, got[field], code, errs)
+        }
+    }
+}
+
+func TestBindJSONBodies(t *testing.T) {
+    register := func() interface{} { return &RegisterRequest{} }
+    join := func() interface{} { return &JoinRoomRequest{} }
+
+    tests := []struct {
+        name      string
+        body      string
+        newReq    func() interface{}
+        optional  bool
+        ok        bool
+        wantField string
+        wantCode  string
+    }{
+        {"valid", `{"username":"alice","email":"a@example.com","password":"x"}`, register, false, true, "", ""},
+        {"empty body", ``, register, false, false, "", "invalid_json"},
+        {"malformed JSON", `{"username":`, register, false, false, "", "invalid_json"},
+        {"wrong type", `{"username":42}`, register, false, false, "username", "type"},
+        {"optional empty body", ``, join, true, true, "", ""},
+        {"overlong password", `{"username":"alice","email":"a@example.com","password":"` + strings.Repeat("x", 257) + `"}`, register, false, false, "password", "max"},
+        {"overlong new password", `{"password":"` + strings.Repeat("x", 257) + `"}`, func() interface{} { return &UpdateUserRequest{} }, false, false, "password", "max"},
+        {"overlong reset password", `{"token":"t","password":"` + strings.Repeat("x", 257) + `"}`, func() interface{} { return &ResetPasswordRequest{} }, false, false, "password", "max"},
+        {"optional body still validated", `{"password":"` + strings.Repeat("x", 73) + `"}`, join, true, false, "password", "max"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ok, errs := bindResult(t, tt.body, tt.newReq, tt.optional)
+            if ok != tt.ok {
+                t.Fatalf("ok = %v, want %v (errors %+v)", ok, tt.ok, errs)
+            }
+            if tt.ok {
+                return
+            }
+            if len(errs) != 1 || errs[0].Field != tt.wantField || errs[0].Code != tt.wantCode {
+                t.Errorf("errors = %+v, want %s/%s", errs, tt.wantField, tt.wantCode)
+            }
+        })
+    }
+}
+
+func TestCustomValidators(t *testing.T) {
+    room := func() interface{} { return &CreateRoomRequest{} }
+    tests := []struct {
+        body string
+        ok   bool
+    }{
+        {`{"name":"Movie night"}`, true},
+        {`{"name":"   "}`, false},
+        {`{"name":"Bell\u0007"}`, false},
+        {`{"name":"x","video_url":"https://youtu.be/abc"}`, true},
+        {`{"name":"x","video_url":"javascript:alert(1)"}`, false},
+        {`{"name":"x","visibility":"secret"}`, false},
+        {`{"name":"x","join_policy":"approval"}`, true},
+        {`{"name":"x","join_policy":"anyone"}`, false},
+        {`{"name":"x","code":"MY-ROOM"}`, true},
+        {`{"name":"x","code":"no spaces"}`, false},
+        {`{"name":"x","max_participants":10001}`, false},
+    }
+    for _, tt := range tests {
+        if ok, errs := bindResult(t, tt.body, room, false); ok != tt.ok {
+            t.Errorf("%s: ok = %v, want %v (%+v)", tt.body, ok, tt.ok, errs)
+        }
+    }
+}
+
+func TestSnakeCase(t *testing.T) {
+    for in, want := range map[string]string{
+        "IP":           "ip",
+        "Username":     "username",
+        "RecoveryCode": "recovery_code",
+        "VideoURL":     "video_url",
+    } {
+        if got := snakeCase(in); got != want {
+            t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+        }
+    }
+}
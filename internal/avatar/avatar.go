@@ -0,0 +1,201 @@
+// Package avatar validates uploaded profile pictures, resizes them to the
+// standard sizes and stores them content-addressed on disk.
+package avatar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+)
+
+// Sizes are the square edge lengths, in pixels, every avatar is stored at.
+var Sizes = []int{32, 64, 128, 256}
+
+// maxDimension rejects images whose decoded size would be unreasonable,
+// before any pixels are decoded.
+const maxDimension = 4096
+
+var (
+	ErrTooLarge        = errors.New("avatar file is too large")
+	ErrUnsupportedType = errors.New("avatar must be a PNG, JPEG or GIF image")
+	ErrBadDimensions   = errors.New("avatar dimensions are too large")
+)
+
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// MaxBytes is the largest accepted upload, from AVATAR_MAX_BYTES.
+func MaxBytes() int64 {
+	return int64(config.Int("AVATAR_MAX_BYTES", 2<<20))
+}
+
+// Dir is where avatars are stored, from AVATAR_DIR.
+func Dir() string {
+	return config.String("AVATAR_DIR", "uploads/avatars")
+}
+
+// Save validates the image read from r, stores it at every size and
+// returns its key, the SHA-256 of the uploaded bytes. Uploading the same
+// image twice reuses the stored files.
+func Save(r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxBytes()+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > MaxBytes() {
+		return "", ErrTooLarge
+	}
+
+	switch http.DetectContentType(data) {
+	case "image/png", "image/jpeg", "image/gif":
+	default:
+		return "", ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrUnsupportedType
+	}
+	if cfg.Width > maxDimension || cfg.Height > maxDimension || cfg.Width == 0 || cfg.Height == 0 {
+		return "", ErrBadDimensions
+	}
+
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	dir := filepath.Join(Dir(), key)
+	if _, err := os.Stat(filepath.Join(dir, fileName(Sizes[len(Sizes)-1]))); err == nil {
+		return key, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrUnsupportedType
+	}
+	square := cropSquare(src)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	for _, size := range Sizes {
+		if err := writePNG(filepath.Join(dir, fileName(size)), resize(square, size)); err != nil {
+			return "", err
+		}
+	}
+	return key, nil
+}
+
+// Path returns the file holding avatar key at size, or false if the key or
+// size is not valid.
+func Path(key string, size int) (string, bool) {
+	if !keyPattern.MatchString(key) || !validSize(size) {
+		return "", false
+	}
+	return filepath.Join(Dir(), key, fileName(size)), true
+}
+
+// URL returns the API path serving avatar key at size.
+func URL(key string, size int) string {
+	return fmt.Sprintf("/api/avatars/%s/%d", key, size)
+}
+
+func validSize(size int) bool {
+	for _, s := range Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+func fileName(size int) string {
+	return fmt.Sprintf("%d.png", size)
+}
+
+func writePNG(path string, img image.Image) error {
+	// Write to a temporary file first so readers never see a partial image.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".avatar-*")
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(tmp, img); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// cropSquare returns the centred square of src as RGBA.
+func cropSquare(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	edge := b.Dx()
+	if b.Dy() < edge {
+		edge = b.Dy()
+	}
+	x0 := b.Min.X + (b.Dx()-edge)/2
+	y0 := b.Min.Y + (b.Dy()-edge)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, edge, edge))
+	draw.Draw(dst, dst.Bounds(), src, image.Pt(x0, y0), draw.Src)
+	return dst
+}
+
+// resize scales the square src to size x size. Each destination pixel
+// averages the source pixels it covers, which gives smooth downscaling;
+// upscaling degrades to nearest neighbour.
+func resize(src *image.RGBA, size int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	n := src.Bounds().Dx()
+
+	for dy := 0; dy < size; dy++ {
+		sy0 := dy * n / size
+		sy1 := (dy + 1) * n / size
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for dx := 0; dx < size; dx++ {
+			sx0 := dx * n / size
+			sx1 := (dx + 1) * n / size
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, b, a, count uint32
+			for sy := sy0; sy < sy1; sy++ {
+				off := src.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint32(src.Pix[off])
+					g += uint32(src.Pix[off+1])
+					b += uint32(src.Pix[off+2])
+					a += uint32(src.Pix[off+3])
+					off += 4
+					count++
+				}
+			}
+
+			off := dst.PixOffset(dx, dy)
+			dst.Pix[off] = uint8(r / count)
+			dst.Pix[off+1] = uint8(g / count)
+			dst.Pix[off+2] = uint8(b / count)
+			dst.Pix[off+3] = uint8(a / count)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,142 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// split returns a w x h image, red on the left half and blue on the right.
+func split(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestSaveStoresEverySize(t *testing.T) {
+	t.Setenv("AVATAR_DIR", t.TempDir())
+	data := encodePNG(t, split(300, 200))
+
+	key, err := Save(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if !keyPattern.MatchString(key) {
+		t.Fatalf("key = %q", key)
+	}
+	for _, size := range Sizes {
+		path, ok := Path(key, size)
+		if !ok {
+			t.Fatalf("Path(%q, %d) not valid", key, size)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+			t.Errorf("size %d stored as %dx%d", size, b.Dx(), b.Dy())
+		}
+	}
+
+	again, err := Save(bytes.NewReader(data))
+	if err != nil || again != key {
+		t.Errorf("saving the same image again = %q, %v; want %q", again, err, key)
+	}
+}
+
+func TestSaveRejects(t *testing.T) {
+	t.Setenv("AVATAR_DIR", t.TempDir())
+	t.Setenv("AVATAR_MAX_BYTES", "4096")
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text", []byte("definitely not an image"), ErrUnsupportedType},
+		{"truncated png", encodePNG(t, split(8, 8))[:40], ErrUnsupportedType},
+		{"too large", bytes.Repeat([]byte{0}, 4097), ErrTooLarge},
+		{"too wide", encodePNG(t, image.NewGray(image.Rect(0, 0, maxDimension+1, 1))), ErrBadDimensions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Save(bytes.NewReader(tt.data)); err != tt.want {
+				t.Errorf("Save = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv("AVATAR_DIR", "/avatars")
+	key := strings.Repeat("ab", 32)
+
+	if path, ok := Path(key, 64); !ok || path != "/avatars/"+key+"/64.png" {
+		t.Errorf("Path = %q, %v", path, ok)
+	}
+	for _, tt := range []struct {
+		key  string
+		size int
+	}{
+		{key, 100},
+		{"../../etc/passwd", 64},
+		{strings.ToUpper(key), 64},
+		{key[:63], 64},
+	} {
+		if _, ok := Path(tt.key, tt.size); ok {
+			t.Errorf("Path(%q, %d) accepted", tt.key, tt.size)
+		}
+	}
+	if got := URL(key, 32); got != "/api/avatars/"+key+"/32" {
+		t.Errorf("URL = %q", got)
+	}
+}
+
+func TestCropSquareAndResize(t *testing.T) {
+	// The centred square of a 4x2 red|blue image is half red, half blue.
+	square := cropSquare(split(4, 2))
+	if b := square.Bounds(); b.Dx() != 2 || b.Dy() != 2 {
+		t.Fatalf("crop = %v", b)
+	}
+	if square.RGBAAt(0, 0).R != 255 || square.RGBAAt(1, 0).B != 255 {
+		t.Errorf("crop is not centred: %v %v", square.RGBAAt(0, 0), square.RGBAAt(1, 0))
+	}
+
+	// Shrinking to one pixel averages everything.
+	got := resize(square, 1).RGBAAt(0, 0)
+	if want := (color.RGBA{R: 127, B: 127, A: 255}); got != want {
+		t.Errorf("resize to 1 = %v, want %v", got, want)
+	}
+
+	// Growing repeats pixels.
+	big := resize(square, 4)
+	if big.RGBAAt(1, 3).R != 255 || big.RGBAAt(2, 0).B != 255 {
+		t.Errorf("upscaled pixels = %v %v", big.RGBAAt(1, 3), big.RGBAAt(2, 0))
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String returns the environment variable key, or def when it is unset.
+func String(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Int returns the environment variable key parsed as an integer, or def when
+// it is unset or malformed.
+func Int(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// Bool returns the environment variable key parsed as a boolean, or def when
+// it is unset or malformed.
+func Bool(key string, def bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+// Duration returns the environment variable key parsed with
+// time.ParseDuration (e.g. "90s", "5m"), or def when it is unset or malformed.
+func Duration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+// List returns the environment variable key split on commas, with blanks
+// trimmed and empty entries dropped, or nil when it is unset.
+func List(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// JWTSecret returns the key used to sign session tokens.
+func JWTSecret() []byte {
+	return []byte(String("JWT_SECRET", "your-secret-key"))
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLookups(t *testing.T) {
+	t.Setenv("TEST_STRING", "value")
+	t.Setenv("TEST_INT", "42")
+	t.Setenv("TEST_BAD_INT", "forty-two")
+	t.Setenv("TEST_BOOL", "true")
+	t.Setenv("TEST_DURATION", "90s")
+	t.Setenv("TEST_BAD_DURATION", "90")
+
+	if got := String("TEST_STRING", "def"); got != "value" {
+		t.Errorf("String = %q", got)
+	}
+	if got := String("TEST_UNSET", "def"); got != "def" {
+		t.Errorf("String unset = %q", got)
+	}
+	if got := Int("TEST_INT", 1); got != 42 {
+		t.Errorf("Int = %d", got)
+	}
+	if got := Int("TEST_BAD_INT", 1); got != 1 {
+		t.Errorf("Int malformed = %d, want the default", got)
+	}
+	if got := Bool("TEST_BOOL", false); !got {
+		t.Error("Bool = false")
+	}
+	if got := Bool("TEST_UNSET", true); !got {
+		t.Error("Bool unset = false, want the default")
+	}
+	if got := Duration("TEST_DURATION", time.Second); got != 90*time.Second {
+		t.Errorf("Duration = %v", got)
+	}
+	if got := Duration("TEST_BAD_DURATION", time.Second); got != time.Second {
+		t.Errorf("Duration malformed = %v, want the default", got)
+	}
+}
+
+func TestList(t *testing.T) {
+	t.Setenv("TEST_LIST", " 10.0.0.1, ,10.0.0.0/8 ,")
+	got := List("TEST_LIST")
+	if len(got) != 2 || got[0] != "10.0.0.1" || got[1] != "10.0.0.0/8" {
+		t.Errorf("List = %q", got)
+	}
+	if got := List("TEST_UNSET"); got != nil {
+		t.Errorf("List unset = %q, want nil", got)
+	}
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"log"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// DeleteUser removes userID and everything attached to them. Rooms they
+// host pass to their longest-present remaining participant; rooms nobody
+// else joined are deleted.
+func DeleteUser(db *gorm.DB, userID uint) error {
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rooms []model.Room
+		if err := tx.Where("host_id = ?", userID).Find(&rooms).Error; err != nil {
+			return err
+		}
+		for i := range rooms {
+			room := &rooms[i]
+			var successor model.RoomParticipant
+			err := tx.Where("room_id = ? AND user_id <> ?", room.ID, userID).Order("joined_at ASC").First(&successor).Error
+			if err == nil {
+				if err := SetHost(tx, room, successor.UserID); err != nil {
+					return err
+				}
+				log.Printf("Room %s passed to user %d after account deletion", room.Code, successor.UserID)
+				continue
+			}
+			if err != gorm.ErrRecordNotFound {
+				return err
+			}
+			if err := deleteRoom(tx, room.ID); err != nil {
+				return err
+			}
+			log.Printf("Room %s deleted with its host's account", room.Code)
+		}
+
+		// Deleted rooms are only soft-deleted; erase those too.
+		var deleted []uint
+		if err := tx.Unscoped().Model(&model.Room{}).
+			Where("host_id = ? AND deleted_at IS NOT NULL", userID).
+			Pluck("id", &deleted).Error; err != nil {
+			return err
+		}
+		for _, roomID := range deleted {
+			if err := deleteRoom(tx, roomID); err != nil {
+				return err
+			}
+		}
+
+		for _, m := range []interface{}{
+			&model.RoomParticipant{},
+			&model.UserToken{},
+			&model.APIToken{},
+			&model.UserIdentity{},
+			&model.Session{},
+			&model.RecoveryCode{},
+			&model.RoomCodeReservation{},
+			&model.JoinRequest{},
+			&model.RoomBan{},
+			&model.RoomTemplateModerator{},
+			&model.RoomVisit{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("created_by = ?", userID).Delete(&model.RoomInvite{}).Error; err != nil {
+			return err
+		}
+		// Playback stays in the rooms' stats, no longer attributed to anyone.
+		if err := tx.Model(&model.PlaybackEvent{}).Where("user_id = ?", userID).
+			UpdateColumn("user_id", 0).Error; err != nil {
+			return err
+		}
+		var templates []uint
+		if err := tx.Model(&model.RoomTemplate{}).Where("user_id = ?", userID).Pluck("id", &templates).Error; err != nil {
+			return err
+		}
+		if len(templates) > 0 {
+			if err := deleteTemplateParts(tx, templates); err != nil {
+				return err
+			}
+			if err := tx.Delete(&model.RoomTemplate{}, templates).Error; err != nil {
+				return err
+			}
+		}
+		if err := ClearLoginThrottle(tx, UserSubject(user.ID)); err != nil {
+			return err
+		}
+
+		return tx.Delete(&model.User{}, userID).Error
+	})
+}
+
+// deleteRoom removes roomID together with its participants, invites and
+// everything else kept per room.
+func deleteRoom(tx *gorm.DB, roomID uint) error {
+	if err := tx.Where("room_id = ?", roomID).Delete(&model.RoomParticipant{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("room_id = ?", roomID).Delete(&model.RoomInvite{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("room_id = ?", roomID).Delete(&model.JoinRequest{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("room_id = ?", roomID).Delete(&model.RoomBan{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("room_id = ?", roomID).Delete(&model.QueueItem{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("room_id = ?", roomID).Delete(&model.RoomVisit{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("room_id = ?", roomID).Delete(&model.PlaybackEvent{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&model.Room{}, roomID).Error
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestDeleteUser(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+
+	shared := newTestRoom(t, db, alice.ID)
+	if err := AddParticipant(db, shared.ID, bob.ID, model.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	solo := newTestRoom(t, db, alice.ID)
+
+	now := time.Now()
+	for _, v := range []interface{}{
+		&model.Session{UserID: alice.ID, JTI: "j1", ExpiresAt: now.Add(time.Hour)},
+		&model.APIToken{UserID: alice.ID, Name: "t", TokenHash: "h"},
+		&model.UserIdentity{UserID: alice.ID, Issuer: "https://idp", Subject: "1"},
+		&model.RecoveryCode{UserID: alice.ID, CodeHash: "c"},
+		&model.PlaybackEvent{RoomID: shared.ID, UserID: alice.ID, Type: model.PlaybackPlay, At: now},
+		&model.PlaybackEvent{RoomID: solo.ID, UserID: alice.ID, Type: model.PlaybackPlay, At: now},
+		&model.RoomVisit{RoomID: solo.ID, UserID: alice.ID, StartedAt: now, LastSeenAt: now},
+	} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := DeleteUser(db, alice.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	var count int64
+	db.Unscoped().Model(&model.User{}).Where("id = ?", alice.ID).Count(&count)
+	if count != 0 {
+		t.Error("the user is still stored")
+	}
+	for name, m := range map[string]interface{}{
+		"sessions":       &model.Session{},
+		"API tokens":     &model.APIToken{},
+		"identities":     &model.UserIdentity{},
+		"recovery codes": &model.RecoveryCode{},
+		"participation":  &model.RoomParticipant{},
+		"visits":         &model.RoomVisit{},
+	} {
+		db.Model(m).Where("user_id = ?", alice.ID).Count(&count)
+		if count != 0 {
+			t.Errorf("%d %s of the deleted user were kept", count, name)
+		}
+	}
+
+	var room model.Room
+	if err := db.First(&room, shared.ID).Error; err != nil {
+		t.Fatalf("shared room: %v", err)
+	}
+	if room.HostID != bob.ID {
+		t.Errorf("shared room host = %d, want %d", room.HostID, bob.ID)
+	}
+	db.Model(&model.PlaybackEvent{}).Where("room_id = ? AND user_id = 0", shared.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("shared room kept %d anonymous playback events, want 1", count)
+	}
+
+	db.Unscoped().Model(&model.Room{}).Where("id = ?", solo.ID).Count(&count)
+	if count != 0 {
+		t.Error("a room nobody else joined outlived its host")
+	}
+	db.Model(&model.PlaybackEvent{}).Where("room_id = ?", solo.ID).Count(&count)
+	if count != 0 {
+		t.Error("a deleted room's playback events were kept")
+	}
+}
+
+func TestDeleteUserNotFound(t *testing.T) {
+	db := testDB(t)
+	if err := DeleteUser(db, 42); err == nil {
+		t.Fatal("DeleteUser succeeded for a user that does not exist")
+	}
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// ErrAPITokenInvalid is returned for unknown or expired API tokens.
+var ErrAPITokenInvalid = errors.New("api token is invalid or expired")
+
+// lastUsedResolution limits how often last_used_at is written for a busy
+// token.
+const lastUsedResolution = time.Minute
+
+// CreateAPIToken stores t and returns the plain token, which is not
+// recoverable afterwards.
+func CreateAPIToken(db *gorm.DB, t *model.APIToken) (string, error) {
+	secret, _, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+	token := model.APITokenPrefix + secret
+	t.TokenHash = HashToken(token)
+	t.Prefix = token[:len(model.APITokenPrefix)+6]
+	t.CreatedAt = time.Now()
+	if err := db.Create(t).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ListAPITokens returns the tokens of userID, newest first.
+func ListAPITokens(db *gorm.DB, userID uint) ([]model.APIToken, error) {
+	var tokens []model.APIToken
+	err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// DeleteAPIToken revokes token id of userID.
+func DeleteAPIToken(db *gorm.DB, userID, id uint) error {
+	res := db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.APIToken{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIToken looks up token and records that it was used.
+func AuthenticateAPIToken(db *gorm.DB, token string) (model.APIToken, error) {
+	var t model.APIToken
+	if !strings.HasPrefix(token, model.APITokenPrefix) {
+		return t, ErrAPITokenInvalid
+	}
+	err := db.Where("token_hash = ?", HashToken(token)).First(&t).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return t, ErrAPITokenInvalid
+		}
+		return t, err
+	}
+
+	now := time.Now()
+	if t.ExpiresAt != nil && now.After(*t.ExpiresAt) {
+		return t, ErrAPITokenInvalid
+	}
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > lastUsedResolution {
+		if err := db.Model(&t).Update("last_used_at", now).Error; err != nil {
+			return t, err
+		}
+		t.LastUsedAt = &now
+	}
+	return t, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrBanned is returned when a banned user tries to join a room.
+var ErrBanned = errors.New("user is banned from this room")
+
+// ActiveBan returns userID's ban from roomID if one is in force.
+func ActiveBan(db *gorm.DB, roomID, userID uint) (model.RoomBan, bool, error) {
+	var ban model.RoomBan
+	err := db.Where("room_id = ? AND user_id = ?", roomID, userID).First(&ban).Error
+	if err == gorm.ErrRecordNotFound {
+		return ban, false, nil
+	}
+	if err != nil {
+		return ban, false, err
+	}
+	return ban, ban.Active(time.Now()), nil
+}
+
+// RemoveParticipant takes userID out of roomID, returning
+// gorm.ErrRecordNotFound if they weren't in it.
+func RemoveParticipant(db *gorm.DB, roomID, userID uint) error {
+	res := db.Where("room_id = ? AND user_id = ?", roomID, userID).Delete(&model.RoomParticipant{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// BanUser stores ban, replacing any earlier ban of the same user from the
+// room, removes the user from the room and denies their pending join
+// requests.
+func BanUser(db *gorm.DB, ban *model.RoomBan) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("room_id = ? AND user_id = ?", ban.RoomID, ban.UserID).
+			Delete(&model.RoomBan{}).Error; err != nil {
+			return err
+		}
+		ban.CreatedAt = time.Now()
+		if err := tx.Omit(clause.Associations).Create(ban).Error; err != nil {
+			return err
+		}
+		if err := RemoveParticipant(tx, ban.RoomID, ban.UserID); err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		return tx.Model(&model.JoinRequest{}).
+			Where("room_id = ? AND user_id = ? AND status = ?", ban.RoomID, ban.UserID, model.JoinPending).
+			Updates(map[string]interface{}{
+				"status":     model.JoinDenied,
+				"decided_at": ban.CreatedAt,
+				"decided_by": ban.CreatedBy,
+			}).Error
+	})
+}
+
+// Unban lifts userID's ban from roomID.
+func Unban(db *gorm.DB, roomID, userID uint) error {
+	res := db.Where("room_id = ? AND user_id = ?", roomID, userID).Delete(&model.RoomBan{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListBans returns the bans of roomID in force, newest first, with users
+// preloaded.
+func ListBans(db *gorm.DB, roomID uint) ([]model.RoomBan, error) {
+	var bans []model.RoomBan
+	err := db.Preload("User").
+		Where("room_id = ? AND (expires_at IS NULL OR expires_at > ?)", roomID, time.Now()).
+		Order("created_at DESC").Find(&bans).Error
+	return bans, err
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestBanUser(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+	room := newTestRoom(t, db, host.ID)
+	if err := JoinRoom(db, &room, alice.ID, model.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	req, _, _ := RequestToJoin(db, &room, bob.ID, time.Minute, 0)
+
+	for _, userID := range []uint{alice.ID, bob.ID} {
+		if err := BanUser(db, &model.RoomBan{RoomID: room.ID, UserID: userID, Reason: "spoilers", CreatedBy: host.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ok, _ := IsParticipant(db, room.ID, alice.ID); ok {
+		t.Error("the banned participant is still in the room")
+	}
+	db.First(&req, req.ID)
+	if req.Status != model.JoinDenied || req.DecidedBy != host.ID {
+		t.Errorf("pending request = %+v, want denied by the host", req)
+	}
+	if err := JoinRoom(db, &room, alice.ID, model.RoleViewer); err != ErrBanned {
+		t.Errorf("banned user joining: err = %v, want ErrBanned", err)
+	}
+
+	ban, banned, err := ActiveBan(db, room.ID, alice.ID)
+	if err != nil || !banned || ban.Reason != "spoilers" {
+		t.Errorf("ActiveBan = %+v, %v, %v", ban, banned, err)
+	}
+
+	// Banning again replaces the ban rather than failing on the unique
+	// index; an expired ban lets the user back in.
+	expired := time.Now().Add(-time.Second)
+	if err := BanUser(db, &model.RoomBan{RoomID: room.ID, UserID: alice.ID, ExpiresAt: &expired, CreatedBy: host.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, banned, _ := ActiveBan(db, room.ID, alice.ID); banned {
+		t.Error("an expired ban is in force")
+	}
+	if err := JoinRoom(db, &room, alice.ID, model.RoleViewer); err != nil {
+		t.Errorf("joining after the ban expired: %v", err)
+	}
+
+	bans, err := ListBans(db, room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 1 || bans[0].UserID != bob.ID || bans[0].User.Username != "bob" {
+		t.Errorf("bans = %+v, want only bob's with the user loaded", bans)
+	}
+
+	if err := Unban(db, room.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unban(db, room.ID, bob.ID); err == nil {
+		t.Error("unbanning twice succeeded")
+	}
+	if _, banned, _ := ActiveBan(db, room.ID, bob.ID); banned {
+		t.Error("bob is still banned")
+	}
+}
+
+func TestRemoveParticipant(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	alice := newTestUser(t, db, "alice")
+	room := newTestRoom(t, db, host.ID)
+	JoinRoom(db, &room, alice.ID, model.RoleViewer)
+
+	if err := RemoveParticipant(db, room.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveParticipant(db, room.ID, alice.ID); err == nil {
+		t.Error("removing someone not in the room succeeded")
+	}
+	// Being kicked is not a ban.
+	if err := JoinRoom(db, &room, alice.ID, model.RoleViewer); err != nil {
+		t.Errorf("rejoining after a kick: %v", err)
+	}
+}
@@ -1,14 +1,18 @@
 package db
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"sync"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"github.com/spacelord16/Videoparty/internal/config"
 	"github.com/spacelord16/Videoparty/internal/model"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/spacelord16/Videoparty/internal/password"
 )
 
 var DB *gorm.DB
@@ -34,33 +38,157 @@ func InitDB() error {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
 		dbHost, dbUser, dbPassword, dbName, dbPort)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %v", err)
 	}
 
-	// Auto migrate the schema
-	err = db.AutoMigrate(&model.User{}, &model.Room{}, &model.RoomParticipant{})
-	if err != nil {
+	if err := Migrate(db); err != nil {
 		return fmt.Errorf("failed to migrate database: %v", err)
 	}
 
+	if err := promoteAdmins(db); err != nil {
+		return fmt.Errorf("failed to promote admins: %v", err)
+	}
+
 	DB = db
 	return nil
 }
 
-func CreateUser(db *gorm.DB, user model.User) error {
+// promoteAdmins makes the users listed in ADMIN_USER_IDS administrators, so
+// there is always a way to bootstrap one. Users are matched by ID rather
+// than username, since the username of a deleted account can be registered
+// again by anyone.
+func promoteAdmins(db *gorm.DB) error {
+	if os.Getenv("ADMIN_USERNAMES") != "" {
+		log.Println("ADMIN_USERNAMES is no longer supported, set ADMIN_USER_IDS instead")
+	}
+	var ids []uint64
+	for _, v := range config.List("ADMIN_USER_IDS") {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("ADMIN_USER_IDS: %q is not a user ID", v)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.Model(&model.User{}).Where("id IN ?", ids).Update("is_admin", true).Error
+}
+
+// Migrate brings the schema of db up to date and migrates the data that
+// changed shape along the way.
+func Migrate(db *gorm.DB) error {
+	if err := dropRoomCodeConstraint(db); err != nil {
+		return err
+	}
+	if err := dedupeParticipants(db); err != nil {
+		return err
+	}
+
+	// Auto migrate the schema
+	err := db.AutoMigrate(
+		&model.User{},
+		&model.Room{},
+		&model.RoomParticipant{},
+		&model.RoomInvite{},
+		&model.UserToken{},
+		&model.LoginThrottle{},
+		&model.UserIdentity{},
+		&model.APIToken{},
+		&model.Session{},
+		&model.RecoveryCode{},
+		&model.RoomCodeReservation{},
+		&model.JoinRequest{},
+		&model.RoomBan{},
+		&model.QueueItem{},
+		&model.RoomTemplate{},
+		&model.RoomTemplateModerator{},
+		&model.RoomTemplateItem{},
+		&model.RoomVisit{},
+		&model.PlaybackEvent{},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Usernames and emails are unique regardless of case. Accounts created
+	// through an identity provider may have no email.
+	for _, stmt := range []string{
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (LOWER(username))",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email)) WHERE email <> ''",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := migrateRoomCodes(db); err != nil {
+		return err
+	}
+	if err := encryptTOTPSecrets(db); err != nil {
+		return err
+	}
+
+	if err := backfillRoomDirectory(db); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var (
+	ErrUsernameTaken = errors.New("username is already taken")
+	ErrEmailTaken    = errors.New("email is already registered")
+)
+
+// CheckUserAvailable returns ErrUsernameTaken or ErrEmailTaken if another
+// user than exceptID already uses username or email, ignoring case. Empty
+// values are not checked.
+func CheckUserAvailable(db *gorm.DB, exceptID uint, username, email string) error {
+	var count int64
+	if username != "" {
+		err := db.Model(&model.User{}).Where("LOWER(username) = LOWER(?) AND id <> ?", username, exceptID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUsernameTaken
+		}
+	}
+	if email != "" {
+		err := db.Model(&model.User{}).Where("LOWER(email) = LOWER(?) AND id <> ?", email, exceptID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrEmailTaken
+		}
+	}
+	return nil
+}
+
+// CreateUser hashes the user's password and stores them. Duplicate names
+// are reported as ErrUsernameTaken or ErrEmailTaken, or gorm.ErrDuplicatedKey
+// if a concurrent registration won the race.
+func CreateUser(db *gorm.DB, user *model.User) error {
 	log.Printf("CreateUser called with username=%s", user.Username)
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err := CheckUserAvailable(db, 0, user.Username, user.Email); err != nil {
+		log.Printf("Cannot create user: %v", err)
+		return err
+	}
+
+	hashedPassword, err := password.Hash(user.Password)
 	if err != nil {
 		log.Printf("Error hashing password: %s", err)
 		return err
 	}
 	log.Println("Password hashed successfully.")
 
-	user.Password = string(hashedPassword)
-	err = db.Create(&user).Error
+	user.Password = hashedPassword
+	err = db.Create(user).Error
 	if err != nil {
 		log.Printf("Error inserting new user into DB: %v", err)
 		return err
@@ -70,26 +198,83 @@ func CreateUser(db *gorm.DB, user model.User) error {
 	return nil
 }
 
-func AuthenticateUser(db *gorm.DB, username, password string) (model.User, error) {
+// ErrInvalidCredentials is returned by AuthenticateUser for both unknown
+// users and wrong passwords, so callers cannot tell them apart.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// verifyDummyHash spends the same time as checking a real password, so a
+// missing user can't be detected from response times.
+func verifyDummyHash(plaintext string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = password.Hash("videoparty-dummy-password")
+	})
+	password.Verify(dummyHash, plaintext)
+}
+
+// findByLogin selects the user whose username or email address is login,
+// ignoring case.
+func findByLogin(db *gorm.DB, login string) *gorm.DB {
+	return db.Model(&model.User{}).
+		Where("LOWER(username) = LOWER(?) OR (email <> '' AND LOWER(email) = LOWER(?))", login, login)
+}
+
+// AuthenticateUser checks login, which may be a username or an email
+// address, and plaintext. When the stored hash was made with outdated
+// parameters it is transparently replaced.
+func AuthenticateUser(db *gorm.DB, login, plaintext string) (model.User, error) {
 	var user model.User
 
-	err := db.Where("username = ?", username).First(&user).Error
+	err := findByLogin(db, login).First(&user).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			log.Println("User not found.")
-			return model.User{}, fmt.Errorf("user not found")
+			verifyDummyHash(plaintext)
+			log.Println("Authentication failed.")
+			return model.User{}, ErrInvalidCredentials
 		} else {
 			log.Printf("Error retrieving user: %s", err)
 			return model.User{}, err
 		}
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-	if err != nil {
-		log.Println("Authentication failed: invalid password.")
-		return model.User{}, fmt.Errorf("invalid password")
+	if user.Password == "" {
+		// Accounts created through an identity provider have no password.
+		verifyDummyHash(plaintext)
+		log.Println("Authentication failed.")
+		return model.User{}, ErrInvalidCredentials
+	}
+
+	ok, needsRehash, err := password.Verify(user.Password, plaintext)
+	if err != nil || !ok {
+		if err != nil {
+			log.Printf("Error verifying password: %s", err)
+		}
+		log.Println("Authentication failed.")
+		return model.User{}, ErrInvalidCredentials
+	}
+
+	if needsRehash {
+		if err := UpdatePassword(db, user.ID, plaintext); err != nil {
+			log.Printf("Error upgrading password hash: %s", err)
+		} else {
+			log.Println("Password hash upgraded.")
+		}
 	}
 
 	log.Println("User authenticated successfully.")
 	return user, nil
 }
+
+func UpdatePassword(db *gorm.DB, userID uint, plaintext string) error {
+	hashedPassword, err := password.Hash(plaintext)
+	if err != nil {
+		log.Printf("Error hashing password: %s", err)
+		return err
+	}
+
+	return db.Model(&model.User{}).Where("id = ?", userID).Update("password", hashedPassword).Error
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spacelord16/Videoparty/internal/dbtest"
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// testDB returns a migrated database of the test's own, skipping the test
+// when no database is configured.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return dbtest.Open(t, Migrate)
+}
+
+// newTestUser stores a user called name with a verified email address.
+func newTestUser(t *testing.T, db *gorm.DB, name string) model.User {
+	t.Helper()
+	user := model.User{Username: name, Email: fmt.Sprintf("%s@example.com", name), EmailVerified: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return user
+}
+
+// newTestRoom stores an open public room hosted by hostID, with the host as
+// its first participant.
+func newTestRoom(t *testing.T, db *gorm.DB, hostID uint) model.Room {
+	t.Helper()
+	room := model.Room{Name: "Movie night", HostID: hostID, Visibility: model.VisibilityPublic, JoinPolicy: model.JoinOpen}
+	if err := CreateRoom(db, &room); err != nil {
+		t.Fatalf("create room: %v", err)
+	}
+	if err := TouchParticipant(db, &room, hostID); err != nil {
+		t.Fatalf("add host: %v", err)
+	}
+	return room
+}
+
+func TestPromoteAdmins(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+
+	t.Setenv("ADMIN_USERNAMES", "bob")
+	t.Setenv("ADMIN_USER_IDS", fmt.Sprintf(" %d, ", alice.ID))
+	if err := promoteAdmins(db); err != nil {
+		t.Fatalf("promoteAdmins: %v", err)
+	}
+	for _, u := range []struct {
+		user model.User
+		want bool
+	}{{alice, true}, {bob, false}} {
+		var stored model.User
+		db.First(&stored, u.user.ID)
+		if stored.IsAdmin != u.want {
+			t.Errorf("%s is admin = %v, want %v", stored.Username, stored.IsAdmin, u.want)
+		}
+	}
+
+	t.Setenv("ADMIN_USER_IDS", "alice")
+	if err := promoteAdmins(db); err == nil {
+		t.Error("a username in ADMIN_USER_IDS was accepted")
+	}
+}
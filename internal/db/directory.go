@@ -0,0 +1,216 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// Directory scopes select which rooms ListRooms considers.
+const (
+	ScopePublic = "public" // public rooms anyone may join
+	ScopeHosted = "hosted" // rooms the caller hosts
+	ScopeJoined = "joined" // rooms the caller has joined
+)
+
+// Directory sort orders. Both are descending, newest or busiest first.
+const (
+	SortActivity     = "activity"
+	SortParticipants = "participants"
+)
+
+// ErrCursorInvalid is returned by DecodeRoomCursor for a cursor that wasn't
+// produced by ListRooms.
+var ErrCursorInvalid = errors.New("invalid cursor")
+
+// participantCountSQL counts a room's participants inside a query on rooms.
+const participantCountSQL = "(SELECT COUNT(*) FROM room_participants rp WHERE rp.room_id = rooms.id)"
+
+// RoomQuery describes one page of the room directory.
+type RoomQuery struct {
+	Scope    string
+	UserID   uint
+	Search   string
+	Platform string
+	Playing  *bool
+	Sort     string
+	Limit    int
+	After    *RoomCursor
+}
+
+// RoomCursor is the position after the last room of a page. Only the field
+// matching the sort order is set besides ID.
+type RoomCursor struct {
+	Activity     time.Time `json:"a"`
+	Participants int64     `json:"p,omitempty"`
+	ID           uint      `json:"id"`
+}
+
+// Encode returns the opaque form of c handed to clients.
+func (c RoomCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeRoomCursor parses a cursor returned by RoomCursor.Encode.
+func DecodeRoomCursor(s string) (*RoomCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrCursorInvalid
+	}
+	var c RoomCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == 0 {
+		return nil, ErrCursorInvalid
+	}
+	return &c, nil
+}
+
+// RoomListing is a room in the directory along with its participant count.
+type RoomListing struct {
+	Room             model.Room
+	ParticipantCount int64
+}
+
+// ListRooms returns a page of rooms matching q, with their hosts preloaded,
+// and the cursor of the next page, or nil if this was the last one.
+func ListRooms(db *gorm.DB, q RoomQuery) ([]RoomListing, *RoomCursor, error) {
+	tx := db.Model(&model.Room{}).Preload("Host").Where("rooms.archived_at IS NULL")
+
+	switch q.Scope {
+	case ScopeHosted:
+		tx = tx.Where("rooms.host_id = ?", q.UserID)
+	case ScopeJoined:
+		tx = tx.Where("EXISTS (SELECT 1 FROM room_participants rp WHERE rp.room_id = rooms.id AND rp.user_id = ?)", q.UserID)
+	default:
+		tx = tx.Where("rooms.visibility = ? AND rooms.join_policy = ? AND rooms.closed_at IS NULL", model.VisibilityPublic, model.JoinOpen)
+	}
+
+	if q.Search != "" {
+		tx = tx.Where("rooms.name ILIKE ?", "%"+escapeLike(q.Search)+"%")
+	}
+	if q.Platform != "" {
+		tx = tx.Where("rooms.platform = ?", q.Platform)
+	}
+	if q.Playing != nil {
+		tx = tx.Where("rooms.is_playing = ?", *q.Playing)
+	}
+
+	if q.Sort == SortParticipants {
+		if q.After != nil {
+			tx = tx.Where("("+participantCountSQL+", rooms.id) < (?, ?)", q.After.Participants, q.After.ID)
+		}
+		tx = tx.Order(participantCountSQL + " DESC, rooms.id DESC")
+	} else {
+		if q.After != nil {
+			tx = tx.Where("(rooms.last_activity_at, rooms.id) < (?, ?)", q.After.Activity, q.After.ID)
+		}
+		tx = tx.Order("rooms.last_activity_at DESC, rooms.id DESC")
+	}
+
+	// Fetch one extra row to learn whether another page follows.
+	var rooms []model.Room
+	if err := tx.Limit(q.Limit + 1).Find(&rooms).Error; err != nil {
+		return nil, nil, err
+	}
+	more := len(rooms) > q.Limit
+	if more {
+		rooms = rooms[:q.Limit]
+	}
+
+	counts, err := participantCounts(db, rooms)
+	if err != nil {
+		return nil, nil, err
+	}
+	listings := make([]RoomListing, len(rooms))
+	for i, room := range rooms {
+		listings[i] = RoomListing{Room: room, ParticipantCount: counts[room.ID]}
+	}
+
+	if !more {
+		return listings, nil, nil
+	}
+	last := listings[len(listings)-1]
+	next := &RoomCursor{ID: last.Room.ID}
+	if q.Sort == SortParticipants {
+		next.Participants = last.ParticipantCount
+	} else {
+		next.Activity = last.Room.LastActivityAt
+	}
+	return listings, next, nil
+}
+
+// participantCounts returns the number of participants of each room.
+func participantCounts(db *gorm.DB, rooms []model.Room) (map[uint]int64, error) {
+	counts := make(map[uint]int64, len(rooms))
+	if len(rooms) == 0 {
+		return counts, nil
+	}
+	ids := make([]uint, len(rooms))
+	for i, room := range rooms {
+		ids[i] = room.ID
+	}
+	var rows []struct {
+		RoomID uint
+		Count  int64
+	}
+	err := db.Model(&model.RoomParticipant{}).
+		Select("room_id, COUNT(*) AS count").
+		Where("room_id IN ?", ids).
+		Group("room_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.RoomID] = row.Count
+	}
+	return counts, nil
+}
+
+// TouchRoom records activity in room for the directory's activity order.
+// Like session and token usage, it's written at most once a minute.
+func TouchRoom(db *gorm.DB, room *model.Room) error {
+	now := time.Now()
+	if now.Sub(room.LastActivityAt) <= lastUsedResolution {
+		return nil
+	}
+	room.LastActivityAt = now
+	return db.Model(&model.Room{}).Where("id = ?", room.ID).
+		UpdateColumn("last_activity_at", now).Error
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern so s matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// backfillRoomDirectory fills in the directory columns of rooms created
+// before they existed.
+func backfillRoomDirectory(db *gorm.DB) error {
+	err := db.Model(&model.Room{}).
+		Where("last_activity_at IS NULL").
+		UpdateColumn("last_activity_at", gorm.Expr("updated_at")).Error
+	if err != nil {
+		return err
+	}
+	var rooms []model.Room
+	err = db.Select("id", "video_url").
+		Where("(platform IS NULL OR platform = '') AND video_url <> ''").
+		Find(&rooms).Error
+	if err != nil {
+		return err
+	}
+	for _, room := range rooms {
+		err := db.Model(&model.Room{}).Where("id = ?", room.ID).
+			UpdateColumn("platform", model.DetectPlatform(room.VideoURL)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
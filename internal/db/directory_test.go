@@ -0,0 +1,172 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestRoomCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 20, 0, 0, 123000, time.UTC)
+	for _, c := range []RoomCursor{
+		{Activity: at, ID: 42},
+		{Participants: 7, ID: 3},
+	} {
+		got, err := DecodeRoomCursor(c.Encode())
+		if err != nil {
+			t.Fatalf("DecodeRoomCursor(%+v): %v", c, err)
+		}
+		if !got.Activity.Equal(c.Activity) || got.Participants != c.Participants || got.ID != c.ID {
+			t.Errorf("round trip = %+v, want %+v", *got, c)
+		}
+	}
+}
+
+func TestDecodeRoomCursorRejects(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"not base64!",
+		RoomCursor{}.Encode(), // no ID
+		"bm90IGpzb24",         // "not json"
+	} {
+		if _, err := DecodeRoomCursor(s); err != ErrCursorInvalid {
+			t.Errorf("DecodeRoomCursor(%q) = %v, want ErrCursorInvalid", s, err)
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"movie":      "movie",
+		"100%":       `100\%`,
+		"a_b":        `a\_b`,
+		`back\slash`: `back\\slash`,
+		`\%_`:        `\\\%\_`,
+	}
+	for in, want := range tests {
+		if got := escapeLike(in); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// directoryRoom stores a room with the directory columns set.
+func directoryRoom(t *testing.T, db *gorm.DB, hostID uint, name string, activity time.Time, update map[string]interface{}) model.Room {
+	t.Helper()
+	room := newTestRoom(t, db, hostID)
+	columns := map[string]interface{}{"name": name, "last_activity_at": activity}
+	for k, v := range update {
+		columns[k] = v
+	}
+	if err := db.Model(&room).UpdateColumns(columns).Error; err != nil {
+		t.Fatal(err)
+	}
+	return room
+}
+
+func roomNames(listings []RoomListing) []string {
+	names := make([]string, len(listings))
+	for i, l := range listings {
+		names[i] = l.Room.Name
+	}
+	return names
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListRooms(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+	now := time.Now()
+
+	directoryRoom(t, db, alice.ID, "Old film", now.Add(-3*time.Hour), map[string]interface{}{"platform": "youtube"})
+	busy := directoryRoom(t, db, alice.ID, "Busy 100% fun", now.Add(-2*time.Hour), map[string]interface{}{"is_playing": true})
+	directoryRoom(t, db, bob.ID, "Fresh", now.Add(-time.Hour), nil)
+	directoryRoom(t, db, bob.ID, "Secret", now, map[string]interface{}{"visibility": model.VisibilityPrivate})
+	directoryRoom(t, db, bob.ID, "Ended", now, map[string]interface{}{"closed_at": now})
+	directoryRoom(t, db, alice.ID, "Lobby", now, map[string]interface{}{"join_policy": model.JoinApproval})
+	directoryRoom(t, db, alice.ID, "Invited", now, map[string]interface{}{"join_policy": model.JoinInvite})
+	if err := AddParticipant(db, busy.ID, bob.ID, model.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+
+	playing := true
+	tests := []struct {
+		name string
+		q    RoomQuery
+		want []string
+	}{
+		{"public by activity", RoomQuery{}, []string{"Fresh", "Busy 100% fun", "Old film"}},
+		{"by participants", RoomQuery{Sort: SortParticipants}, []string{"Busy 100% fun", "Fresh", "Old film"}},
+		{"hosted", RoomQuery{Scope: ScopeHosted, UserID: bob.ID}, []string{"Ended", "Secret", "Fresh"}},
+		{"joined", RoomQuery{Scope: ScopeJoined, UserID: bob.ID}, []string{"Ended", "Secret", "Fresh", "Busy 100% fun"}},
+		{"search", RoomQuery{Search: "FILM"}, []string{"Old film"}},
+		{"search is literal", RoomQuery{Search: "100%"}, []string{"Busy 100% fun"}},
+		{"wildcard matches nothing", RoomQuery{Search: "_"}, []string{}},
+		{"platform", RoomQuery{Platform: "youtube"}, []string{"Old film"}},
+		{"playing", RoomQuery{Playing: &playing}, []string{"Busy 100% fun"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.q.Limit = 10
+			listings, next, err := ListRooms(db, tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := roomNames(listings); !equalNames(got, tt.want) {
+				t.Errorf("rooms = %q, want %q", got, tt.want)
+			}
+			if next != nil {
+				t.Error("a single page returned a cursor")
+			}
+		})
+	}
+}
+
+func TestListRoomsPages(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	at := time.Now().Truncate(time.Second)
+
+	// Equal activity times must neither repeat nor skip rooms across pages.
+	var want []string
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		directoryRoom(t, db, alice.ID, name, at, nil)
+		want = append([]string{name}, want...)
+	}
+
+	for _, sort := range []string{SortActivity, SortParticipants} {
+		var got []string
+		q := RoomQuery{Sort: sort, Limit: 2}
+		for page := 0; page < 5; page++ {
+			listings, next, err := ListRooms(db, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, roomNames(listings)...)
+			if next == nil {
+				break
+			}
+			q.After, err = DecodeRoomCursor(next.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !equalNames(got, want) {
+			t.Errorf("%s: paged rooms = %q, want %q", sort, got, want)
+		}
+	}
+}
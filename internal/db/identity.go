@@ -0,0 +1,192 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// ExternalAccount describes a user as asserted by an identity provider.
+type ExternalAccount struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+}
+
+// FindOrLinkUser returns the user linked to acct. An unknown account is
+// linked to the existing user with the same email if the provider verified
+// it, and otherwise gets a new user. If the existing user never verified
+// the address, whoever registered it may not own it, so the account is
+// reclaimed for the provider's user first; see reclaimUnverifiedUser.
+func FindOrLinkUser(db *gorm.DB, acct ExternalAccount) (model.User, error) {
+	var user model.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var identity model.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", acct.Issuer, acct.Subject).First(&identity).Error
+		if err == nil {
+			return tx.First(&user, identity.UserID).Error
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		linked := false
+		if acct.Email != "" && acct.EmailVerified {
+			err := tx.Where("LOWER(email) = LOWER(?)", acct.Email).First(&user).Error
+			if err != nil && err != gorm.ErrRecordNotFound {
+				return err
+			}
+			if err == nil {
+				if !user.EmailVerified {
+					if err := reclaimUnverifiedUser(tx, &user); err != nil {
+						return err
+					}
+					log.Printf("Reclaimed unverified user %d for %s identity", user.ID, acct.Issuer)
+				}
+				linked = true
+				log.Printf("Linking %s identity to existing user %d", acct.Issuer, user.ID)
+			}
+		}
+
+		if !linked {
+			username, err := availableUsername(tx, acct)
+			if err != nil {
+				return err
+			}
+			// No password is set, so the account can only log in through
+			// the provider until the user sets one.
+			user = model.User{Username: username}
+			if acct.EmailVerified {
+				user.Email = acct.Email
+				user.EmailVerified = true
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&model.UserIdentity{
+			UserID:    user.ID,
+			Issuer:    acct.Issuer,
+			Subject:   acct.Subject,
+			Email:     acct.Email,
+			CreatedAt: time.Now(),
+		}).Error
+	})
+	return user, err
+}
+
+// reclaimUnverifiedUser hands user, whose email address was never verified,
+// to the provider's user who has proven they own it. Someone else may have
+// registered the address in advance, so every way they could still get in
+// is removed: the password, two-factor login, sessions, API tokens and
+// emailed links.
+func reclaimUnverifiedUser(tx *gorm.DB, user *model.User) error {
+	err := tx.Model(&model.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"password":       "",
+		"email_verified": true,
+		"totp_enabled":   false,
+		"totp_secret":    "",
+		"totp_last_step": 0,
+	}).Error
+	if err != nil {
+		return err
+	}
+	user.Password = ""
+	user.EmailVerified = true
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := RevokeOtherSessions(tx, user.ID, 0); err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&model.APIToken{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&model.UserToken{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Update("used_at", time.Now()).Error
+}
+
+// CreatePasswordlessUser creates a user for a verified email address, for
+// logins that prove ownership of the address instead of using a password.
+func CreatePasswordlessUser(db *gorm.DB, email string) (model.User, error) {
+	var user model.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		username, err := availableUsername(tx, ExternalAccount{Email: email})
+		if err != nil {
+			return err
+		}
+		user = model.User{
+			Username:      username,
+			Email:         email,
+			EmailVerified: true,
+		}
+		return tx.Create(&user).Error
+	})
+	return user, err
+}
+
+// availableUsername picks an unused username based on what the provider
+// told us about the account.
+func availableUsername(db *gorm.DB, acct ExternalAccount) (string, error) {
+	base := usernameBase(acct)
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			suffix := strconv.Itoa(i)
+			candidate = truncateUsername(base, model.UsernameMaxLength-len(suffix)) + suffix
+		}
+		var count int64
+		if err := db.Model(&model.User{}).Where("LOWER(username) = LOWER(?)", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no available username for %q", base)
+}
+
+// usernameBase derives a valid username from the preferred username or the
+// local part of the email address, dropping characters usernames can't
+// have and truncating or padding it to the allowed length.
+func usernameBase(acct ExternalAccount) string {
+	base := acct.Username
+	if base == "" {
+		base, _, _ = strings.Cut(acct.Email, "@")
+	}
+	base = strings.Map(func(r rune) rune {
+		if model.UsernameRune(r) {
+			return r
+		}
+		return -1
+	}, base)
+	switch {
+	case base == "":
+		base = "user"
+	case len(base) < model.UsernameMinLength:
+		base = "user_" + base
+	}
+	return truncateUsername(base, model.UsernameMaxLength)
+}
+
+// truncateUsername shortens name to at most n characters. Usernames are
+// ASCII, so bytes and characters are the same.
+func truncateUsername(name string, n int) string {
+	if len(name) > n {
+		return name[:n]
+	}
+	return name
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/dbtest"
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestUsernameBase(t *testing.T) {
+	tests := []struct {
+		acct ExternalAccount
+		want string
+	}{
+		{ExternalAccount{Username: "alice"}, "alice"},
+		{ExternalAccount{Email: "bob.smith+tv@example.com"}, "bob.smithtv"},
+		{ExternalAccount{Username: "al", Email: "alice@example.com"}, "user_al"},
+		{ExternalAccount{Username: "Ω"}, "user"},
+		{ExternalAccount{}, "user"},
+		{ExternalAccount{Username: strings.Repeat("a", 40)}, strings.Repeat("a", model.UsernameMaxLength)},
+	}
+	for _, tt := range tests {
+		got := usernameBase(tt.acct)
+		if got != tt.want {
+			t.Errorf("usernameBase(%+v) = %q, want %q", tt.acct, got, tt.want)
+		}
+		if !model.ValidUsername(got) {
+			t.Errorf("usernameBase(%+v) = %q, which is not a valid username", tt.acct, got)
+		}
+	}
+}
+
+func TestAvailableUsernameStaysValid(t *testing.T) {
+	db := dbtest.Open(t, Migrate)
+
+	long := strings.Repeat("a", 40)
+	for i := 0; i < 3; i++ {
+		user, err := FindOrLinkUser(db, ExternalAccount{Issuer: "https://idp", Subject: string(rune('a' + i)), Username: long})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !model.ValidUsername(user.Username) {
+			t.Errorf("user %d got invalid username %q", i, user.Username)
+		}
+	}
+}
+
+func TestFindOrLinkUser(t *testing.T) {
+	const issuer = "https://idp.example.com"
+
+	t.Run("links verified email", func(t *testing.T) {
+		db := dbtest.Open(t, Migrate)
+		local := model.User{Username: "alice", Email: "alice@example.com", Password: "hash", EmailVerified: true}
+		if err := db.Create(&local).Error; err != nil {
+			t.Fatal(err)
+		}
+		session := model.Session{UserID: local.ID, JTI: "j1", ExpiresAt: time.Now().Add(time.Hour)}
+		if err := db.Create(&session).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := FindOrLinkUser(db, ExternalAccount{Issuer: issuer, Subject: "1", Email: "Alice@example.com", EmailVerified: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.ID != local.ID {
+			t.Fatalf("linked to user %d, want %d", user.ID, local.ID)
+		}
+		db.First(&user, local.ID)
+		if user.Password != "hash" {
+			t.Error("linking a verified account cleared its password")
+		}
+		db.First(&session, session.ID)
+		if session.RevokedAt != nil {
+			t.Error("linking a verified account revoked its sessions")
+		}
+
+		again, err := FindOrLinkUser(db, ExternalAccount{Issuer: issuer, Subject: "1"})
+		if err != nil || again.ID != local.ID {
+			t.Fatalf("second login = %d, %v; want %d", again.ID, err, local.ID)
+		}
+	})
+
+	t.Run("reclaims unverified email", func(t *testing.T) {
+		db := dbtest.Open(t, Migrate)
+		local := model.User{Username: "squatter", Email: "alice@example.com", Password: "hash", TOTPEnabled: true, TOTPSecret: "secret"}
+		if err := db.Create(&local).Error; err != nil {
+			t.Fatal(err)
+		}
+		session := model.Session{UserID: local.ID, JTI: "j1", ExpiresAt: time.Now().Add(time.Hour)}
+		token := model.APIToken{UserID: local.ID, Name: "t", TokenHash: "h"}
+		for _, v := range []interface{}{&session, &token, &model.RecoveryCode{UserID: local.ID, CodeHash: "c"}} {
+			if err := db.Create(v).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		user, err := FindOrLinkUser(db, ExternalAccount{Issuer: issuer, Subject: "1", Email: "alice@example.com", EmailVerified: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.ID != local.ID {
+			t.Fatalf("linked to user %d, want %d", user.ID, local.ID)
+		}
+
+		var stored model.User
+		db.First(&stored, local.ID)
+		if stored.Password != "" || stored.TOTPEnabled || stored.TOTPSecret != "" || !stored.EmailVerified {
+			t.Errorf("reclaimed user = %+v, want no password or 2FA and a verified email", stored)
+		}
+		db.First(&session, session.ID)
+		if session.RevokedAt == nil {
+			t.Error("the previous owner's session is still active")
+		}
+		var count int64
+		db.Model(&model.APIToken{}).Where("user_id = ?", local.ID).Count(&count)
+		if count != 0 {
+			t.Error("the previous owner's API tokens were kept")
+		}
+		db.Model(&model.RecoveryCode{}).Where("user_id = ?", local.ID).Count(&count)
+		if count != 0 {
+			t.Error("the previous owner's recovery codes were kept")
+		}
+	})
+
+	t.Run("does not link unverified provider email", func(t *testing.T) {
+		db := dbtest.Open(t, Migrate)
+		local := model.User{Username: "alice", Email: "alice@example.com", Password: "hash", EmailVerified: true}
+		if err := db.Create(&local).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := FindOrLinkUser(db, ExternalAccount{Issuer: issuer, Subject: "1", Email: "alice@example.com", Username: "alice"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.ID == local.ID {
+			t.Fatal("linked an identity whose email the provider did not verify")
+		}
+		if user.Email != "" || user.Username == "alice" {
+			t.Errorf("new user = %+v, want no email and a fresh username", user)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// ErrInviteInvalid is returned when an invite token is unknown, expired,
+// revoked or used up.
+var ErrInviteInvalid = errors.New("invite is invalid or expired")
+
+// CreateInvite stores invite and returns the plain token for it, which is
+// not recoverable afterwards.
+func CreateInvite(db *gorm.DB, invite *model.RoomInvite) (string, error) {
+	token, hash, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+	invite.TokenHash = hash
+	invite.CreatedAt = time.Now()
+	if err := db.Create(invite).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ListInvites returns the invites of roomID that can still be redeemed.
+func ListInvites(db *gorm.DB, roomID uint) ([]model.RoomInvite, error) {
+	var invites []model.RoomInvite
+	err := db.Where("room_id = ? AND revoked_at IS NULL AND expires_at > ? AND (max_uses = 0 OR uses < max_uses)", roomID, time.Now()).
+		Order("created_at DESC").
+		Find(&invites).Error
+	return invites, err
+}
+
+// RevokeInvite revokes invite id of roomID.
+func RevokeInvite(db *gorm.DB, roomID, id uint) error {
+	res := db.Model(&model.RoomInvite{}).
+		Where("id = ? AND room_id = ? AND revoked_at IS NULL", id, roomID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RedeemInvite consumes one use of the invite matching token. The use count
+// is incremented conditionally so concurrent redemptions cannot exceed
+// MaxUses.
+func RedeemInvite(db *gorm.DB, token string) (model.RoomInvite, error) {
+	var invite model.RoomInvite
+	if err := db.Where("token_hash = ?", HashToken(token)).First(&invite).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return invite, ErrInviteInvalid
+		}
+		return invite, err
+	}
+	if !invite.Usable(time.Now()) {
+		return invite, ErrInviteInvalid
+	}
+
+	res := db.Model(&model.RoomInvite{}).
+		Where("id = ? AND revoked_at IS NULL AND (max_uses = 0 OR uses < max_uses)", invite.ID).
+		Update("uses", gorm.Expr("uses + 1"))
+	if res.Error != nil {
+		return invite, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return invite, ErrInviteInvalid
+	}
+	invite.Uses++
+	return invite, nil
+}
+
+// JoinWithInvite redeems token and adds userID to its room with the
+// invite's role. If the room is full the invite use is given back.
+func JoinWithInvite(db *gorm.DB, token string, userID uint) (model.Room, error) {
+	var room model.Room
+	err := db.Transaction(func(tx *gorm.DB) error {
+		invite, err := RedeemInvite(tx, token)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&room, invite.RoomID).Error; err != nil {
+			return err
+		}
+		if room.Closed() {
+			return ErrInviteInvalid
+		}
+		return JoinRoom(tx, &room, userID, invite.Role)
+	})
+	return room, err
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestJoinWithInvite(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	room := newTestRoom(t, db, host.ID)
+
+	invite := model.RoomInvite{RoomID: room.ID, CreatedBy: host.ID, Role: model.RoleModerator, MaxUses: 1, ExpiresAt: time.Now().Add(time.Hour)}
+	token, err := CreateInvite(db, &invite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invite.TokenHash == token {
+		t.Fatal("the plain token was stored")
+	}
+
+	alice := newTestUser(t, db, "alice")
+	joined, err := JoinWithInvite(db, token, alice.ID)
+	if err != nil {
+		t.Fatalf("JoinWithInvite: %v", err)
+	}
+	if joined.ID != room.ID {
+		t.Fatalf("joined room %d, want %d", joined.ID, room.ID)
+	}
+	var p model.RoomParticipant
+	if err := db.Where("room_id = ? AND user_id = ?", room.ID, alice.ID).First(&p).Error; err != nil || p.Role != model.RoleModerator {
+		t.Fatalf("participant = %+v, %v; want a moderator", p, err)
+	}
+
+	bob := newTestUser(t, db, "bob")
+	if _, err := JoinWithInvite(db, token, bob.ID); err != ErrInviteInvalid {
+		t.Fatalf("second use of a single-use invite = %v, want ErrInviteInvalid", err)
+	}
+	if _, err := JoinWithInvite(db, "unknown", bob.ID); err != ErrInviteInvalid {
+		t.Fatalf("unknown token = %v, want ErrInviteInvalid", err)
+	}
+}
+
+func TestJoinWithInviteGivesUseBackWhenFull(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	room := newTestRoom(t, db, host.ID)
+	db.Model(&room).Update("max_participants", 1)
+
+	invite := model.RoomInvite{RoomID: room.ID, CreatedBy: host.ID, Role: model.RoleViewer, MaxUses: 1, ExpiresAt: time.Now().Add(time.Hour)}
+	token, err := CreateInvite(db, &invite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := newTestUser(t, db, "alice")
+	if _, err := JoinWithInvite(db, token, alice.ID); err != ErrRoomFull {
+		t.Fatalf("JoinWithInvite into a full room = %v, want ErrRoomFull", err)
+	}
+	db.First(&invite, invite.ID)
+	if invite.Uses != 0 {
+		t.Errorf("uses = %d after a failed join, want 0", invite.Uses)
+	}
+}
+
+func TestRevokeInvite(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	room := newTestRoom(t, db, host.ID)
+
+	invite := model.RoomInvite{RoomID: room.ID, CreatedBy: host.ID, Role: model.RoleViewer, ExpiresAt: time.Now().Add(time.Hour)}
+	token, err := CreateInvite(db, &invite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RevokeInvite(db, room.ID+1, invite.ID); err == nil {
+		t.Fatal("revoked another room's invite")
+	}
+	if err := RevokeInvite(db, room.ID, invite.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RedeemInvite(db, token); err != ErrInviteInvalid {
+		t.Fatalf("redeeming a revoked invite = %v, want ErrInviteInvalid", err)
+	}
+	invites, err := ListInvites(db, room.ID)
+	if err != nil || len(invites) != 0 {
+		t.Fatalf("ListInvites = %d, %v; want none", len(invites), err)
+	}
+}
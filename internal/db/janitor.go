@@ -0,0 +1,123 @@
+package db
+
+import (
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// archiveBatchSize bounds how many rooms one ArchiveClosedRooms call
+// archives, keeping its transaction short.
+const archiveBatchSize = 500
+
+// CloseRoom ends the party in room: playback stops and outstanding invites
+// are revoked. Closing an already closed room is a no-op.
+func CloseRoom(db *gorm.DB, room *model.Room) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.Room{}).
+			Where("id = ? AND closed_at IS NULL", room.ID).
+			UpdateColumns(map[string]interface{}{"closed_at": now, "is_playing": false})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		room.ClosedAt = &now
+		room.IsPlaying = false
+		return tx.Model(&model.RoomInvite{}).
+			Where("room_id = ? AND revoked_at IS NULL", room.ID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// CloseIdleRooms closes open rooms without activity since idleSince and
+// returns their IDs. Rooms waiting for a scheduled start are left open.
+func CloseIdleRooms(db *gorm.DB, idleSince time.Time) ([]uint, error) {
+	var ids []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Room{}).
+			Where("closed_at IS NULL AND last_activity_at < ?", idleSince).
+			Where("next_start_at IS NULL OR next_start_at < ?", time.Now()).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		now := time.Now()
+		if err := tx.Model(&model.RoomInvite{}).
+			Where("room_id IN ? AND revoked_at IS NULL", ids).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Room{}).
+			Where("id IN ?", ids).
+			UpdateColumns(map[string]interface{}{"closed_at": now, "is_playing": false}).Error
+	})
+	return ids, err
+}
+
+// PruneParticipants removes viewers not seen since seenBefore and returns
+// how many it removed. Hosts and moderators are kept, since their rows carry
+// their role.
+func PruneParticipants(db *gorm.DB, seenBefore time.Time) (int64, error) {
+	res := db.Exec(`DELETE FROM room_participants rp USING rooms r
+		WHERE r.id = rp.room_id AND rp.user_id <> r.host_id AND rp.role = ?
+		AND COALESCE(rp.last_seen_at, rp.joined_at) < ?`,
+		model.RoleViewer, seenBefore)
+	return res.RowsAffected, res.Error
+}
+
+// ArchiveClosedRooms archives rooms closed before closedBefore and returns
+// how many it archived. The room row and its stats are kept as history, but
+// its participants, invites, join requests, bans and queue are deleted and
+// its code no longer resolves.
+func ArchiveClosedRooms(db *gorm.DB, closedBefore time.Time) (int64, error) {
+	var archived int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Model(&model.Room{}).
+			Where("closed_at < ? AND archived_at IS NULL", closedBefore).
+			Order("closed_at").Limit(archiveBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Where("room_id IN ?", ids).Delete(&model.RoomParticipant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id IN ?", ids).Delete(&model.RoomInvite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id IN ?", ids).Delete(&model.JoinRequest{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id IN ?", ids).Delete(&model.RoomBan{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id IN ?", ids).Delete(&model.QueueItem{}).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&model.Room{}).Where("id IN ?", ids).
+			UpdateColumn("archived_at", time.Now())
+		archived = res.RowsAffected
+		return res.Error
+	})
+	return archived, err
+}
+
+// TryAdvisoryLock takes the transaction-scoped Postgres advisory lock key
+// on tx, reporting false if another session holds it. The lock is released
+// when tx commits or rolls back.
+func TryAdvisoryLock(tx *gorm.DB, key int64) (bool, error) {
+	var locked bool
+	err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", key).Scan(&locked).Error
+	return locked, err
+}
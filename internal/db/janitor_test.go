@@ -0,0 +1,164 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestCloseRoom(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	room := newTestRoom(t, db, alice.ID)
+	db.Model(&room).UpdateColumn("is_playing", true)
+	invite := model.RoomInvite{RoomID: room.ID, CreatedBy: alice.ID, TokenHash: "h", ExpiresAt: time.Now().Add(time.Hour)}
+	db.Create(&invite)
+
+	if err := CloseRoom(db, &room); err != nil {
+		t.Fatal(err)
+	}
+	if room.ClosedAt == nil || room.IsPlaying {
+		t.Errorf("room = closed %v, playing %v", room.ClosedAt, room.IsPlaying)
+	}
+	closedAt := *room.ClosedAt
+	db.First(&invite, invite.ID)
+	if invite.RevokedAt == nil {
+		t.Error("closing the room left its invite usable")
+	}
+
+	// Closing again keeps the original time.
+	if err := CloseRoom(db, &room); err != nil {
+		t.Fatal(err)
+	}
+	var stored model.Room
+	db.First(&stored, room.ID)
+	if stored.ClosedAt == nil || stored.ClosedAt.Sub(closedAt).Abs() > time.Millisecond {
+		t.Errorf("closed_at = %v, want %v", stored.ClosedAt, closedAt)
+	}
+}
+
+func TestCloseIdleRooms(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	now := time.Now()
+
+	idle := newTestRoom(t, db, alice.ID)
+	active := newTestRoom(t, db, alice.ID)
+	scheduled := newTestRoom(t, db, alice.ID)
+	overdue := newTestRoom(t, db, alice.ID)
+	db.Model(&idle).UpdateColumn("last_activity_at", now.Add(-2*time.Hour))
+	db.Model(&active).UpdateColumn("last_activity_at", now)
+	db.Model(&scheduled).UpdateColumns(map[string]interface{}{"last_activity_at": now.Add(-2 * time.Hour), "next_start_at": now.Add(time.Hour)})
+	db.Model(&overdue).UpdateColumns(map[string]interface{}{"last_activity_at": now.Add(-2 * time.Hour), "next_start_at": now.Add(-time.Hour)})
+
+	ids, err := CloseIdleRooms(db, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := make(map[uint]bool)
+	for _, id := range ids {
+		closed[id] = true
+	}
+	if len(ids) != 2 || !closed[idle.ID] || !closed[overdue.ID] {
+		t.Errorf("closed %v, want rooms %d and %d", ids, idle.ID, overdue.ID)
+	}
+
+	var open int64
+	db.Model(&model.Room{}).Where("closed_at IS NULL").Count(&open)
+	if open != 2 {
+		t.Errorf("%d rooms left open, want 2", open)
+	}
+}
+
+func TestPruneParticipants(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+	carol := newTestUser(t, db, "carol")
+	dave := newTestUser(t, db, "dave")
+	room := newTestRoom(t, db, alice.ID)
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, p := range []model.RoomParticipant{
+		{RoomID: room.ID, UserID: bob.ID, Role: model.RoleViewer, JoinedAt: old, LastSeenAt: old},
+		{RoomID: room.ID, UserID: carol.ID, Role: model.RoleModerator, JoinedAt: old, LastSeenAt: old},
+		{RoomID: room.ID, UserID: dave.ID, Role: model.RoleViewer, JoinedAt: old, LastSeenAt: time.Now()},
+	} {
+		if err := db.Create(&p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The host's row is stale too, but hosts are never pruned.
+	db.Model(&model.RoomParticipant{}).Where("user_id = ?", alice.ID).UpdateColumn("last_seen_at", old)
+
+	n, err := PruneParticipants(db, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("pruned %d, want 1", n)
+	}
+	for _, tt := range []struct {
+		user model.User
+		want bool
+	}{{alice, true}, {bob, false}, {carol, true}, {dave, true}} {
+		if ok, _ := IsParticipant(db, room.ID, tt.user.ID); ok != tt.want {
+			t.Errorf("%s is participant = %v, want %v", tt.user.Username, ok, tt.want)
+		}
+	}
+}
+
+func TestArchiveClosedRoomsKeepsStats(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	room := newTestRoom(t, db, alice.ID)
+	recent := newTestRoom(t, db, alice.ID)
+
+	now := time.Now()
+	for _, v := range []interface{}{
+		&model.RoomVisit{RoomID: room.ID, UserID: alice.ID, StartedAt: now.Add(-time.Hour), LastSeenAt: now},
+		&model.PlaybackEvent{RoomID: room.ID, UserID: alice.ID, Type: model.PlaybackPlay, At: now},
+		&model.RoomInvite{RoomID: room.ID, CreatedBy: alice.ID, TokenHash: "h", ExpiresAt: now},
+	} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Model(&room).UpdateColumn("closed_at", now.Add(-48*time.Hour))
+	db.Model(&recent).UpdateColumn("closed_at", now)
+
+	n, err := ArchiveClosedRooms(db, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("archived %d rooms, want 1", n)
+	}
+	if _, err := FindRoom(db, room.Code); err == nil {
+		t.Error("an archived room's code still resolves")
+	}
+	if _, err := FindRoom(db, recent.Code); err != nil {
+		t.Errorf("a recently closed room was archived: %v", err)
+	}
+
+	var count int64
+	for name, m := range map[string]interface{}{"participants": &model.RoomParticipant{}, "invites": &model.RoomInvite{}} {
+		db.Model(m).Where("room_id = ?", room.ID).Count(&count)
+		if count != 0 {
+			t.Errorf("archiving kept %d %s", count, name)
+		}
+	}
+
+	var archived model.Room
+	if err := db.First(&archived, room.ID).Error; err != nil {
+		t.Fatalf("archived room row: %v", err)
+	}
+	stats, err := ComputeRoomStats(db, &archived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalWatchTime != time.Hour || stats.Plays != 1 {
+		t.Errorf("stats after archiving = watch time %v, %d plays; want 1h and 1", stats.TotalWatchTime, stats.Plays)
+	}
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrJoinRequestNotFound is returned for a join request that doesn't exist
+// or is no longer pending.
+var ErrJoinRequestNotFound = errors.New("join request not found")
+
+// ErrDeniedRecently is returned by RequestToJoin for a user whose last
+// request was denied within the cooldown.
+var ErrDeniedRecently = errors.New("join request denied recently")
+
+// RequestToJoin puts userID in the lobby of room until ttl from now. Asking
+// again while pending extends the request rather than queueing a new one;
+// created reports which happened. A user denied less than cooldown ago gets
+// ErrDeniedRecently along with the denied request.
+func RequestToJoin(db *gorm.DB, room *model.Room, userID uint, ttl, cooldown time.Duration) (req model.JoinRequest, created bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Lock the room so concurrent requests can't both create one.
+		var locked model.Room
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&locked, room.ID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		err := tx.Where("room_id = ? AND user_id = ? AND status = ? AND expires_at > ?",
+			room.ID, userID, model.JoinPending, now).First(&req).Error
+		if err == nil {
+			req.ExpiresAt = now.Add(ttl)
+			return tx.Model(&req).Update("expires_at", req.ExpiresAt).Error
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		err = tx.Where("room_id = ? AND user_id = ? AND status = ? AND decided_at > ?",
+			room.ID, userID, model.JoinDenied, now.Add(-cooldown)).
+			Order("decided_at DESC").First(&req).Error
+		if err == nil {
+			return ErrDeniedRecently
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		req = model.JoinRequest{
+			RoomID:    room.ID,
+			UserID:    userID,
+			Status:    model.JoinPending,
+			ExpiresAt: now.Add(ttl),
+			CreatedAt: now,
+		}
+		created = true
+		return tx.Create(&req).Error
+	})
+	return req, created, err
+}
+
+// LatestJoinRequest returns userID's most recent join request for roomID.
+func LatestJoinRequest(db *gorm.DB, roomID, userID uint) (model.JoinRequest, error) {
+	var req model.JoinRequest
+	err := db.Where("room_id = ? AND user_id = ?", roomID, userID).
+		Order("created_at DESC").First(&req).Error
+	return req, err
+}
+
+// HasPendingJoinRequest reports whether userID is waiting in roomID's lobby.
+func HasPendingJoinRequest(db *gorm.DB, roomID, userID uint) (bool, error) {
+	var count int64
+	err := db.Model(&model.JoinRequest{}).
+		Where("room_id = ? AND user_id = ? AND status = ? AND expires_at > ?",
+			roomID, userID, model.JoinPending, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// PendingJoinRequests returns roomID's lobby, longest waiting first, with
+// users preloaded.
+func PendingJoinRequests(db *gorm.DB, roomID uint) ([]model.JoinRequest, error) {
+	var reqs []model.JoinRequest
+	err := db.Preload("User").
+		Where("room_id = ? AND status = ? AND expires_at > ?", roomID, model.JoinPending, time.Now()).
+		Order("created_at").Find(&reqs).Error
+	return reqs, err
+}
+
+// pendingForDecision locks the pending requests of roomID among ids, or all
+// of them if ids is nil.
+func pendingForDecision(tx *gorm.DB, roomID uint, ids []uint) ([]model.JoinRequest, error) {
+	q := tx.Preload("User").Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("room_id = ? AND status = ? AND expires_at > ?", roomID, model.JoinPending, time.Now())
+	if ids != nil {
+		q = q.Where("id IN ?", ids)
+	}
+	var reqs []model.JoinRequest
+	err := q.Order("created_at").Find(&reqs).Error
+	return reqs, err
+}
+
+// decide records the host's decision on req.
+func decide(tx *gorm.DB, req *model.JoinRequest, status string, deciderID uint) error {
+	now := time.Now()
+	req.Status = status
+	req.DecidedAt = &now
+	req.DecidedBy = deciderID
+	return tx.Model(req).Updates(map[string]interface{}{
+		"status":     status,
+		"decided_at": now,
+		"decided_by": deciderID,
+	}).Error
+}
+
+// AdmitJoinRequests lets the pending requests among ids, or the whole lobby
+// if ids is nil, into room in the order they were made. Requests of banned
+// users are denied instead. Admission stops when the room is full; full
+// reports whether that happened, and the requests left over stay pending.
+func AdmitJoinRequests(db *gorm.DB, room *model.Room, ids []uint, deciderID uint) (admitted, denied []model.JoinRequest, full bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		reqs, err := pendingForDecision(tx, room.ID, ids)
+		if err != nil {
+			return err
+		}
+		for i := range reqs {
+			req := &reqs[i]
+			if err := JoinRoom(tx, room, req.UserID, model.RoleViewer); err != nil {
+				if err == ErrRoomFull {
+					full = true
+					return nil
+				}
+				if err == ErrBanned {
+					if err := decide(tx, req, model.JoinDenied, deciderID); err != nil {
+						return err
+					}
+					denied = append(denied, *req)
+					continue
+				}
+				return err
+			}
+			if err := decide(tx, req, model.JoinAdmitted, deciderID); err != nil {
+				return err
+			}
+			admitted = append(admitted, *req)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return admitted, denied, full, nil
+}
+
+// DenyJoinRequests turns away the pending requests among ids, or the whole
+// lobby if ids is nil.
+func DenyJoinRequests(db *gorm.DB, roomID uint, ids []uint, deciderID uint) ([]model.JoinRequest, error) {
+	var denied []model.JoinRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		reqs, err := pendingForDecision(tx, roomID, ids)
+		if err != nil {
+			return err
+		}
+		for i := range reqs {
+			if err := decide(tx, &reqs[i], model.JoinDenied, deciderID); err != nil {
+				return err
+			}
+		}
+		denied = reqs
+		return nil
+	})
+	return denied, err
+}
+
+// ExpireJoinRequests marks pending requests past their expiry as expired
+// and returns them.
+func ExpireJoinRequests(db *gorm.DB) ([]model.JoinRequest, error) {
+	var expired []model.JoinRequest
+	err := db.Model(&expired).
+		Clauses(clause.Returning{}).
+		Where("status = ? AND expires_at <= ?", model.JoinPending, time.Now()).
+		Update("status", model.JoinExpired).Error
+	return expired, err
+}
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestRequestToJoin(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	alice := newTestUser(t, db, "alice")
+	room := newTestRoom(t, db, host.ID)
+
+	first, created, err := RequestToJoin(db, &room, alice.ID, time.Minute, 0)
+	if err != nil || !created {
+		t.Fatalf("RequestToJoin = created %v, %v", created, err)
+	}
+	if first.Status != model.JoinPending {
+		t.Errorf("status = %q, want pending", first.Status)
+	}
+
+	again, created, err := RequestToJoin(db, &room, alice.ID, time.Hour, 0)
+	if err != nil || created {
+		t.Fatalf("asking again = created %v, %v; want the request extended", created, err)
+	}
+	if again.ID != first.ID || !again.ExpiresAt.After(first.ExpiresAt) {
+		t.Errorf("asking again = request %d until %v, want %d extended past %v", again.ID, again.ExpiresAt, first.ID, first.ExpiresAt)
+	}
+
+	if ok, _ := HasPendingJoinRequest(db, room.ID, alice.ID); !ok {
+		t.Error("HasPendingJoinRequest = false")
+	}
+	latest, err := LatestJoinRequest(db, room.ID, alice.ID)
+	if err != nil || latest.ID != first.ID {
+		t.Errorf("LatestJoinRequest = %d, %v", latest.ID, err)
+	}
+}
+
+func TestAdmitJoinRequests(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+	carol := newTestUser(t, db, "carol")
+	mallory := newTestUser(t, db, "mallory")
+	room := newTestRoom(t, db, host.ID)
+	db.Model(&room).UpdateColumn("max_participants", 3)
+	room.MaxParticipants = 3
+
+	var reqs []model.JoinRequest
+	for _, u := range []model.User{mallory, alice, bob, carol} {
+		req, _, err := RequestToJoin(db, &room, u.ID, time.Minute, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs = append(reqs, req)
+	}
+	// A ban that raced the lobby, leaving mallory's request pending.
+	if err := db.Create(&model.RoomBan{RoomID: room.ID, UserID: mallory.ID, CreatedBy: host.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	lobby, _ := PendingJoinRequests(db, room.ID)
+	if len(lobby) != 4 || lobby[0].User.Username != "mallory" {
+		t.Fatalf("lobby = %+v, want four requests in order with users loaded", lobby)
+	}
+
+	admitted, denied, full, err := AdmitJoinRequests(db, &room, nil, host.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !full || len(admitted) != 2 || admitted[0].UserID != alice.ID || admitted[1].UserID != bob.ID {
+		t.Fatalf("admitted %+v, full %v; want alice and bob before the room filled", admitted, full)
+	}
+	if len(denied) != 1 || denied[0].UserID != mallory.ID || denied[0].Status != model.JoinDenied {
+		t.Errorf("denied %+v, want mallory's request", denied)
+	}
+	if admitted[0].DecidedBy != host.ID || admitted[0].DecidedAt == nil {
+		t.Errorf("decision not recorded: %+v", admitted[0])
+	}
+
+	for _, tt := range []struct {
+		req  model.JoinRequest
+		want string
+	}{
+		{reqs[0], model.JoinDenied},
+		{reqs[1], model.JoinAdmitted},
+		{reqs[2], model.JoinAdmitted},
+		{reqs[3], model.JoinPending},
+	} {
+		var stored model.JoinRequest
+		db.First(&stored, tt.req.ID)
+		if stored.Status != tt.want {
+			t.Errorf("request of user %d is %q, want %q", tt.req.UserID, stored.Status, tt.want)
+		}
+	}
+	if ok, _ := IsParticipant(db, room.ID, mallory.ID); ok {
+		t.Error("a banned user was admitted")
+	}
+
+	// Admitting a decided request again does nothing.
+	admitted, _, _, err = AdmitJoinRequests(db, &room, []uint{reqs[1].ID}, host.ID)
+	if err != nil || len(admitted) != 0 {
+		t.Errorf("admitting a decided request = %+v, %v", admitted, err)
+	}
+}
+
+func TestDenyJoinRequests(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+	room := newTestRoom(t, db, host.ID)
+	other := newTestRoom(t, db, host.ID)
+
+	ra, _, _ := RequestToJoin(db, &room, alice.ID, time.Minute, 0)
+	RequestToJoin(db, &room, bob.ID, time.Minute, 0)
+	rb, _, _ := RequestToJoin(db, &other, bob.ID, time.Minute, 0)
+
+	denied, err := DenyJoinRequests(db, room.ID, []uint{ra.ID, rb.ID}, host.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(denied) != 1 || denied[0].ID != ra.ID || denied[0].Status != model.JoinDenied {
+		t.Errorf("denied %+v, want only alice's request to this room", denied)
+	}
+
+	denied, _ = DenyJoinRequests(db, room.ID, nil, host.ID)
+	if len(denied) != 1 || denied[0].UserID != bob.ID {
+		t.Errorf("denying the lobby = %+v, want bob's remaining request", denied)
+	}
+	if ok, _ := HasPendingJoinRequest(db, other.ID, bob.ID); !ok {
+		t.Error("denying one room's lobby touched another's")
+	}
+}
+
+func TestRequestToJoinAfterDenial(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	alice := newTestUser(t, db, "alice")
+	room := newTestRoom(t, db, host.ID)
+
+	first, _, _ := RequestToJoin(db, &room, alice.ID, time.Minute, time.Hour)
+	if _, err := DenyJoinRequests(db, room.ID, nil, host.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	req, created, err := RequestToJoin(db, &room, alice.ID, time.Minute, time.Hour)
+	if err != ErrDeniedRecently || created || req.ID != first.ID || req.DecidedAt == nil {
+		t.Fatalf("asking again = %+v, created %v, %v; want the denied request and ErrDeniedRecently", req, created, err)
+	}
+	if ok, _ := HasPendingJoinRequest(db, room.ID, alice.ID); ok {
+		t.Error("asking during the cooldown queued a request")
+	}
+
+	db.Model(&model.JoinRequest{}).Where("id = ?", first.ID).UpdateColumn("decided_at", time.Now().Add(-2*time.Hour))
+	if _, created, err := RequestToJoin(db, &room, alice.ID, time.Minute, time.Hour); err != nil || !created {
+		t.Errorf("asking after the cooldown = created %v, %v", created, err)
+	}
+}
+
+func TestExpireJoinRequests(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+	room := newTestRoom(t, db, host.ID)
+
+	stale, _, _ := RequestToJoin(db, &room, alice.ID, -time.Second, 0)
+	RequestToJoin(db, &room, bob.ID, time.Minute, 0)
+
+	if ok, _ := HasPendingJoinRequest(db, room.ID, alice.ID); ok {
+		t.Error("an expired request counts as pending")
+	}
+	expired, err := ExpireJoinRequests(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].ID != stale.ID || expired[0].RoomID != room.ID || expired[0].UserID != alice.ID {
+		t.Errorf("expired %+v, want alice's request with its room and user", expired)
+	}
+	if expired, _ := ExpireJoinRequests(db); len(expired) != 0 {
+		t.Errorf("expired %d requests twice", len(expired))
+	}
+
+	// An expired request doesn't block asking again.
+	if _, created, _ := RequestToJoin(db, &room, alice.ID, time.Minute, 0); !created {
+		t.Error("asking again after expiry reused the expired request")
+	}
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQueueFull is returned by AddToQueue when the room's queue is at its
+// limit.
+var ErrQueueFull = errors.New("queue is full")
+
+// ListQueue returns roomID's queue in play order.
+func ListQueue(db *gorm.DB, roomID uint) ([]model.QueueItem, error) {
+	items := []model.QueueItem{}
+	err := db.Where("room_id = ?", roomID).Order("position, id").Find(&items).Error
+	return items, err
+}
+
+// AddToQueue appends item to the end of its room's queue, failing with
+// ErrQueueFull if the queue already holds limit items. The room row is
+// locked so concurrent additions get distinct positions.
+func AddToQueue(db *gorm.DB, item *model.QueueItem, limit int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var locked model.Room
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&locked, item.RoomID).Error; err != nil {
+			return err
+		}
+
+		var last struct {
+			Count int64
+			Max   int
+		}
+		if err := tx.Model(&model.QueueItem{}).
+			Select("COUNT(*) AS count, COALESCE(MAX(position), 0) AS max").
+			Where("room_id = ?", item.RoomID).
+			Scan(&last).Error; err != nil {
+			return err
+		}
+		if last.Count >= int64(limit) {
+			return ErrQueueFull
+		}
+
+		item.Position = last.Max + 1
+		item.CreatedAt = time.Now()
+		return tx.Create(item).Error
+	})
+}
+
+// RemoveFromQueue deletes item id from roomID's queue, returning
+// gorm.ErrRecordNotFound if it isn't there.
+func RemoveFromQueue(db *gorm.DB, roomID, id uint) error {
+	res := db.Where("room_id = ? AND id = ?", roomID, id).Delete(&model.QueueItem{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestAddToQueue(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	room := newTestRoom(t, db, host.ID)
+
+	add := func(title string) (model.QueueItem, error) {
+		item := model.QueueItem{RoomID: room.ID, VideoURL: "https://example.com/" + title, Title: title, AddedBy: host.ID}
+		err := AddToQueue(db, &item, 3)
+		return item, err
+	}
+	first, _ := add("one")
+	second, _ := add("two")
+	if first.Position != 1 || second.Position != 2 {
+		t.Errorf("positions = %d, %d, want 1, 2", first.Position, second.Position)
+	}
+
+	if err := RemoveFromQueue(db, room.ID, first.ID); err != nil {
+		t.Fatalf("RemoveFromQueue: %v", err)
+	}
+	if err := RemoveFromQueue(db, room.ID, first.ID); err != gorm.ErrRecordNotFound {
+		t.Errorf("removing twice = %v, want not found", err)
+	}
+	third, _ := add("three")
+	if third.Position != 3 {
+		t.Errorf("an item added after a removal is at %d, want 3", third.Position)
+	}
+	add("four")
+	if _, err := add("five"); err != ErrQueueFull {
+		t.Errorf("adding to a full queue = %v, want ErrQueueFull", err)
+	}
+
+	items, err := ListQueue(db, room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	if fmt.Sprint(titles) != "[two three four]" {
+		t.Errorf("queue = %v", titles)
+	}
+
+	other := newTestRoom(t, db, host.ID)
+	if err := RemoveFromQueue(db, other.ID, second.ID); err != gorm.ErrRecordNotFound {
+		t.Errorf("removing another room's item = %v, want not found", err)
+	}
+}
+
+func TestAddToQueueConcurrently(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	room := newTestRoom(t, db, host.ID)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			item := model.QueueItem{RoomID: room.ID, VideoURL: fmt.Sprintf("https://example.com/%d", i)}
+			errs <- AddToQueue(db, &item, 5)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	full := 0
+	for err := range errs {
+		if err == ErrQueueFull {
+			full++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	items, _ := ListQueue(db, room.ID)
+	if len(items) != 5 || full != 3 {
+		t.Fatalf("queued %d with %d refused, want 5 and 3", len(items), full)
+	}
+	for i, item := range items {
+		if item.Position != i+1 {
+			t.Errorf("positions are not distinct and consecutive: %d at %d", item.Position, i)
+		}
+	}
+}
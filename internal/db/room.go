@@ -0,0 +1,260 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRoomFull is returned by JoinRoom when the room has no free place.
+var ErrRoomFull = errors.New("room is full")
+
+// ErrNotParticipant is returned by TouchParticipant when the user has not
+// joined the room.
+var ErrNotParticipant = errors.New("not a participant of the room")
+
+// ErrHostChanged is returned by SetHost when another request changed the host
+// after the room was loaded.
+var ErrHostChanged = errors.New("room host changed concurrently")
+
+// TouchParticipant records that userID is still present in room, extending
+// their visit, and returns ErrNotParticipant if they haven't joined it. The
+// host always gets a participant row so its absence can be detected, even
+// for rooms created before hosts were tracked as participants.
+func TouchParticipant(db *gorm.DB, room *model.Room, userID uint) error {
+	now := time.Now()
+	res := db.Model(&model.RoomParticipant{}).
+		Where("room_id = ? AND user_id = ?", room.ID, userID).
+		Update("last_seen_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return recordVisit(db, room.ID, userID, now, false)
+	}
+	if userID != room.HostID {
+		return ErrNotParticipant
+	}
+	err := db.Clauses(participantConflict("last_seen_at")).Create(&model.RoomParticipant{
+		RoomID:     room.ID,
+		UserID:     userID,
+		JoinedAt:   now,
+		LastSeenAt: now,
+		Role:       model.RoleViewer,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return recordVisit(db, room.ID, userID, now, true)
+}
+
+// participantConflict resolves inserting a participant row that another
+// request already inserted, updating columns of the existing row, or
+// keeping it as is if none are given.
+func participantConflict(columns ...string) clause.OnConflict {
+	conflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}, {Name: "user_id"}},
+		DoNothing: len(columns) == 0,
+	}
+	if len(columns) > 0 {
+		conflict.DoUpdates = clause.AssignmentColumns(columns)
+	}
+	return conflict
+}
+
+// IsParticipant reports whether userID has joined room.
+func IsParticipant(db *gorm.DB, roomID, userID uint) (bool, error) {
+	var count int64
+	err := db.Model(&model.RoomParticipant{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// AddParticipant adds userID to room with role. Existing participants keep
+// their row, but are promoted if role is moderator.
+func AddParticipant(db *gorm.DB, roomID, userID uint, role string) error {
+	if role == "" {
+		role = model.RoleViewer
+	}
+	conflict := participantConflict()
+	if role == model.RoleModerator {
+		conflict = participantConflict("role")
+	}
+	now := time.Now()
+	return db.Clauses(conflict).Create(&model.RoomParticipant{
+		RoomID:     roomID,
+		UserID:     userID,
+		JoinedAt:   now,
+		LastSeenAt: now,
+		Role:       role,
+	}).Error
+}
+
+// CanControl reports whether userID may control playback in room: the host
+// and moderators can.
+func CanControl(db *gorm.DB, room *model.Room, userID uint) (bool, error) {
+	if room.HostID == userID {
+		return true, nil
+	}
+	var count int64
+	err := db.Model(&model.RoomParticipant{}).
+		Where("room_id = ? AND user_id = ? AND role = ?", room.ID, userID, model.RoleModerator).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SetHost hands control of room to newHostID. The update only applies if the
+// room is still hosted by the host the caller loaded, so concurrent transfers
+// cannot overwrite each other.
+func SetHost(db *gorm.DB, room *model.Room, newHostID uint) error {
+	res := db.Model(&model.Room{}).
+		Where("id = ? AND host_id = ?", room.ID, room.HostID).
+		Updates(map[string]interface{}{"host_id": newHostID, "updated_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrHostChanged
+	}
+	room.HostID = newHostID
+	return nil
+}
+
+// HostGracePeriod is how long a participant, the host included, may go
+// unseen before they no longer count as present: control passes from an
+// absent host, and absent participants don't take up room capacity.
+func HostGracePeriod() time.Duration {
+	return config.Duration("HOST_GRACE_PERIOD", 2*time.Minute)
+}
+
+// MigrateHost promotes the longest-present participant when the host has not
+// been seen for longer than grace. It reports whether the host changed.
+func MigrateHost(db *gorm.DB, room *model.Room, grace time.Duration) (bool, error) {
+	cutoff := time.Now().Add(-grace)
+
+	var host model.RoomParticipant
+	err := db.Where("room_id = ? AND user_id = ?", room.ID, room.HostID).First(&host).Error
+	if err == nil && host.LastSeenAt.After(cutoff) {
+		return false, nil
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return false, err
+	}
+
+	var candidate model.RoomParticipant
+	err = db.Where("room_id = ? AND user_id <> ? AND last_seen_at > ?", room.ID, room.HostID, cutoff).
+		Order("joined_at ASC").
+		First(&candidate).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := SetHost(db, room, candidate.UserID); err != nil {
+		if err == ErrHostChanged {
+			return false, db.First(room, room.ID).Error
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// FindRoom loads the room with code, ignoring case. Archived rooms are no
+// longer found.
+func FindRoom(db *gorm.DB, code string) (model.Room, error) {
+	var room model.Room
+	err := db.Where("code = ? AND archived_at IS NULL", NormalizeRoomCode(code)).First(&room).Error
+	return room, err
+}
+
+// DeleteRoom closes room and soft-deletes it.
+func DeleteRoom(db *gorm.DB, room *model.Room) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := CloseRoom(tx, room); err != nil {
+			return err
+		}
+		return tx.Delete(room).Error
+	})
+}
+
+// RoomCapacity returns how many participants room may have, or 0 for no
+// limit. Rooms without their own limit use ROOM_MAX_PARTICIPANTS.
+func RoomCapacity(room *model.Room) int {
+	if room.MaxParticipants > 0 {
+		return room.MaxParticipants
+	}
+	return config.Int("ROOM_MAX_PARTICIPANTS", 100)
+}
+
+// JoinRoom adds userID to room like AddParticipant and starts their visit,
+// failing with ErrBanned if they are banned from it and ErrRoomFull if that
+// would exceed the room's capacity, counting only participants seen within
+// HostGracePeriod. The host and returning participants are never turned away
+// for capacity. The room row is locked while counting, so
+// concurrent joins can't overshoot the limit.
+func JoinRoom(db *gorm.DB, room *model.Room, userID uint, role string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var locked model.Room
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&locked, room.ID).Error; err != nil {
+			return err
+		}
+
+		_, banned, err := ActiveBan(tx, room.ID, userID)
+		if err != nil {
+			return err
+		}
+		if banned {
+			return ErrBanned
+		}
+
+		joined, err := IsParticipant(tx, room.ID, userID)
+		if err != nil {
+			return err
+		}
+		if limit := RoomCapacity(room); !joined && userID != room.HostID && limit > 0 {
+			var count int64
+			if err := tx.Model(&model.RoomParticipant{}).
+				Where("room_id = ? AND last_seen_at > ?", room.ID, time.Now().Add(-HostGracePeriod())).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count >= int64(limit) {
+				return ErrRoomFull
+			}
+		}
+		if err := AddParticipant(tx, room.ID, userID, role); err != nil {
+			return err
+		}
+		return recordVisit(tx, room.ID, userID, time.Now(), !joined)
+	})
+}
+
+// dedupeParticipants merges the duplicate participant rows concurrent
+// requests could insert before (room_id, user_id) was unique, keeping the
+// first row and any moderator role. It runs before AutoMigrate adds the
+// unique index.
+func dedupeParticipants(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&model.RoomParticipant{}) {
+		return nil
+	}
+	for _, stmt := range []string{
+		`UPDATE room_participants p SET role = 'moderator' WHERE role <> 'moderator' AND EXISTS (
+			SELECT 1 FROM room_participants q
+			WHERE q.room_id = p.room_id AND q.user_id = p.user_id AND q.role = 'moderator')`,
+		`DELETE FROM room_participants p USING room_participants q
+			WHERE p.room_id = q.room_id AND p.user_id = q.user_id AND p.id > q.id`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
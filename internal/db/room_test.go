@@ -0,0 +1,218 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestMigrateHost(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	first := newTestUser(t, db, "first")
+	second := newTestUser(t, db, "second")
+	gone := newTestUser(t, db, "gone")
+	room := newTestRoom(t, db, host.ID)
+
+	now := time.Now()
+	for _, p := range []struct {
+		userID           uint
+		joined, lastSeen time.Time
+	}{
+		{gone.ID, now.Add(-time.Hour), now.Add(-time.Hour)},
+		{first.ID, now.Add(-30 * time.Minute), now},
+		{second.ID, now.Add(-10 * time.Minute), now},
+	} {
+		err := db.Create(&model.RoomParticipant{RoomID: room.ID, UserID: p.userID, JoinedAt: p.joined, LastSeenAt: p.lastSeen, Role: model.RoleViewer}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changed, err := MigrateHost(db, &room, time.Minute)
+	if err != nil || changed {
+		t.Fatalf("MigrateHost with the host present = %v, %v; want no change", changed, err)
+	}
+
+	db.Model(&model.RoomParticipant{}).Where("room_id = ? AND user_id = ?", room.ID, host.ID).
+		Update("last_seen_at", now.Add(-5*time.Minute))
+	changed, err = MigrateHost(db, &room, time.Minute)
+	if err != nil || !changed {
+		t.Fatalf("MigrateHost with the host away = %v, %v; want a change", changed, err)
+	}
+	if room.HostID != first.ID {
+		t.Errorf("new host = %d, want the longest present participant %d", room.HostID, first.ID)
+	}
+}
+
+func TestMigrateHostWithoutCandidates(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	room := newTestRoom(t, db, host.ID)
+	db.Model(&model.RoomParticipant{}).Where("room_id = ?", room.ID).
+		Update("last_seen_at", time.Now().Add(-time.Hour))
+
+	changed, err := MigrateHost(db, &room, time.Minute)
+	if err != nil || changed || room.HostID != host.ID {
+		t.Fatalf("MigrateHost = %v, %v, host %d; want the host kept", changed, err, room.HostID)
+	}
+}
+
+func TestSetHostDetectsConcurrentTransfer(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	a := newTestUser(t, db, "alice")
+	b := newTestUser(t, db, "bob")
+	room := newTestRoom(t, db, host.ID)
+
+	stale := room
+	if err := SetHost(db, &room, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetHost(db, &stale, b.ID); err != ErrHostChanged {
+		t.Fatalf("SetHost from a stale room = %v, want ErrHostChanged", err)
+	}
+
+	var stored model.Room
+	db.First(&stored, room.ID)
+	if stored.HostID != a.ID {
+		t.Errorf("host = %d, want %d", stored.HostID, a.ID)
+	}
+}
+
+func TestTouchParticipant(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	stranger := newTestUser(t, db, "stranger")
+	room := newTestRoom(t, db, host.ID)
+
+	if err := TouchParticipant(db, &room, stranger.ID); err != ErrNotParticipant {
+		t.Errorf("touching a stranger = %v, want ErrNotParticipant", err)
+	}
+	if joined, _ := IsParticipant(db, room.ID, stranger.ID); joined {
+		t.Error("touching a stranger made them a participant")
+	}
+
+	// Concurrent requests of a host without a row create just one.
+	db.Where("room_id = ?", room.ID).Delete(&model.RoomParticipant{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := TouchParticipant(db, &room, host.ID); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	var count int64
+	db.Model(&model.RoomParticipant{}).Where("room_id = ? AND user_id = ?", room.ID, host.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("host has %d participant rows, want 1", count)
+	}
+}
+
+func TestAddParticipant(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	alice := newTestUser(t, db, "alice")
+	room := newTestRoom(t, db, host.ID)
+
+	for _, role := range []string{model.RoleViewer, model.RoleViewer, model.RoleModerator, model.RoleViewer} {
+		if err := AddParticipant(db, room.ID, alice.ID, role); err != nil {
+			t.Fatalf("AddParticipant(%s): %v", role, err)
+		}
+	}
+	var rows []model.RoomParticipant
+	db.Where("room_id = ? AND user_id = ?", room.ID, alice.ID).Find(&rows)
+	if len(rows) != 1 || rows[0].Role != model.RoleModerator {
+		t.Errorf("rows = %+v, want one moderator", rows)
+	}
+}
+
+func TestRoomCapacity(t *testing.T) {
+	t.Setenv("ROOM_MAX_PARTICIPANTS", "")
+	if got := RoomCapacity(&model.Room{}); got != 100 {
+		t.Errorf("default capacity = %d, want 100", got)
+	}
+	t.Setenv("ROOM_MAX_PARTICIPANTS", "0")
+	if got := RoomCapacity(&model.Room{}); got != 0 {
+		t.Errorf("unlimited capacity = %d, want 0", got)
+	}
+	if got := RoomCapacity(&model.Room{MaxParticipants: 5}); got != 5 {
+		t.Errorf("room's own capacity = %d, want 5", got)
+	}
+}
+
+func TestJoinRoomCapacity(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+	room := newTestRoom(t, db, host.ID)
+	db.Model(&room).UpdateColumn("max_participants", 2)
+	room.MaxParticipants = 2
+
+	if err := JoinRoom(db, &room, alice.ID, model.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if err := JoinRoom(db, &room, bob.ID, model.RoleViewer); err != ErrRoomFull {
+		t.Fatalf("joining a full room: err = %v, want ErrRoomFull", err)
+	}
+	if err := JoinRoom(db, &room, alice.ID, model.RoleViewer); err != nil {
+		t.Errorf("a returning participant was turned away: %v", err)
+	}
+	if err := JoinRoom(db, &room, host.ID, model.RoleViewer); err != nil {
+		t.Errorf("the host was turned away: %v", err)
+	}
+
+	// Participants who have gone away don't take up room.
+	db.Model(&model.RoomParticipant{}).Where("room_id = ? AND user_id = ?", room.ID, alice.ID).
+		UpdateColumn("last_seen_at", time.Now().Add(-HostGracePeriod()-time.Minute))
+	if err := JoinRoom(db, &room, bob.ID, model.RoleViewer); err != nil {
+		t.Errorf("joining a room whose other participant left: %v", err)
+	}
+}
+
+func TestJoinRoomConcurrently(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	room := newTestRoom(t, db, host.ID)
+	db.Model(&room).UpdateColumn("max_participants", 3)
+	room.MaxParticipants = 3
+
+	const joiners = 8
+	users := make([]model.User, joiners)
+	for i := range users {
+		users[i] = newTestUser(t, db, fmt.Sprintf("user%d", i))
+	}
+
+	errs := make(chan error, joiners)
+	for _, u := range users {
+		go func(userID uint) {
+			errs <- JoinRoom(db, &room, userID, model.RoleViewer)
+		}(u.ID)
+	}
+	joined := 0
+	for range users {
+		switch err := <-errs; err {
+		case nil:
+			joined++
+		case ErrRoomFull:
+		default:
+			t.Fatal(err)
+		}
+	}
+	if joined != 2 {
+		t.Errorf("%d users joined, want 2 next to the host", joined)
+	}
+	var count int64
+	db.Model(&model.RoomParticipant{}).Where("room_id = ?", room.ID).Count(&count)
+	if count != 3 {
+		t.Errorf("room has %d participants, want 3", count)
+	}
+}
@@ -0,0 +1,223 @@
+package db
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// Room code errors.
+var (
+	ErrCodeTaken           = errors.New("room code is taken")
+	ErrCodeExhausted       = errors.New("no free room code found")
+	ErrReservationLimit    = errors.New("room code reservation limit reached")
+	ErrReservationNotFound = errors.New("room code is not reserved by this user")
+)
+
+// defaultCodeAlphabet leaves out characters that are easily confused when
+// read aloud or copied by hand: 0/O, 1/I/L.
+const defaultCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// codeAttempts bounds how many generated codes CreateRoom tries before
+// giving up; with the defaults a collision is already unlikely.
+const codeAttempts = 10
+
+// codeAlphabet returns ROOM_CODE_ALPHABET upper-cased with duplicates
+// removed, since codes are matched case-insensitively.
+func codeAlphabet() string {
+	var b strings.Builder
+	seen := make(map[rune]bool)
+	for _, r := range strings.ToUpper(config.String("ROOM_CODE_ALPHABET", defaultCodeAlphabet)) {
+		if seen[r] || r <= ' ' {
+			continue
+		}
+		seen[r] = true
+		b.WriteRune(r)
+	}
+	if len(seen) < 2 {
+		return defaultCodeAlphabet
+	}
+	return b.String()
+}
+
+// codeLength returns ROOM_CODE_LENGTH, kept within 4 to 32 characters.
+func codeLength() int {
+	n := config.Int("ROOM_CODE_LENGTH", 6)
+	if n < 4 || n > 32 {
+		return 6
+	}
+	return n
+}
+
+// NormalizeRoomCode returns the stored form of a code typed by a user.
+func NormalizeRoomCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// NewRoomCode returns a random room code drawn from a CSPRNG.
+func NewRoomCode() (string, error) {
+	alphabet := []rune(codeAlphabet())
+	max := big.NewInt(int64(len(alphabet)))
+	code := make([]rune, codeLength())
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// CreateRoom stores room. If room.Code is set it is used as is, failing with
+// ErrCodeTaken if a live room already has it; otherwise a random code that
+// is neither in use nor reserved is assigned.
+func CreateRoom(db *gorm.DB, room *model.Room) error {
+	if room.Code != "" {
+		room.Code = NormalizeRoomCode(room.Code)
+		err := insertRoom(db, room)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrCodeTaken
+		}
+		return err
+	}
+
+	for i := 0; i < codeAttempts; i++ {
+		code, err := NewRoomCode()
+		if err != nil {
+			return err
+		}
+		var reserved int64
+		if err := db.Model(&model.RoomCodeReservation{}).Where("code = ?", code).Count(&reserved).Error; err != nil {
+			return err
+		}
+		if reserved > 0 {
+			continue
+		}
+		room.Code = code
+		err = insertRoom(db, room)
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return err
+		}
+	}
+	room.Code = ""
+	return ErrCodeExhausted
+}
+
+// insertRoom inserts room in its own transaction, which is a savepoint when
+// db is already one, so a code collision doesn't abort the caller's
+// transaction.
+func insertRoom(db *gorm.DB, room *model.Room) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(room).Error
+	})
+}
+
+// ReserveRoomCode reserves code for userID, who may hold at most limit
+// reservations. Reserving a code the user already holds is a no-op.
+func ReserveRoomCode(db *gorm.DB, userID uint, code string, limit int) (model.RoomCodeReservation, error) {
+	r := model.RoomCodeReservation{Code: NormalizeRoomCode(code), UserID: userID}
+
+	var existing model.RoomCodeReservation
+	err := db.Where("code = ?", r.Code).First(&existing).Error
+	if err == nil {
+		if existing.UserID == userID {
+			return existing, nil
+		}
+		return r, ErrCodeTaken
+	}
+	if err != gorm.ErrRecordNotFound {
+		return r, err
+	}
+
+	var count int64
+	if err := db.Model(&model.RoomCodeReservation{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return r, err
+	}
+	if count >= int64(limit) {
+		return r, ErrReservationLimit
+	}
+
+	// A live room hosted by someone else keeps its code.
+	var inUse int64
+	if err := db.Model(&model.Room{}).
+		Where("code = ? AND archived_at IS NULL AND host_id <> ?", r.Code, userID).
+		Count(&inUse).Error; err != nil {
+		return r, err
+	}
+	if inUse > 0 {
+		return r, ErrCodeTaken
+	}
+
+	r.CreatedAt = time.Now()
+	if err := db.Create(&r).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return r, ErrCodeTaken
+		}
+		return r, err
+	}
+	return r, nil
+}
+
+// ListRoomCodeReservations returns the codes userID has reserved.
+func ListRoomCodeReservations(db *gorm.DB, userID uint) ([]model.RoomCodeReservation, error) {
+	var reservations []model.RoomCodeReservation
+	err := db.Where("user_id = ?", userID).Order("code").Find(&reservations).Error
+	return reservations, err
+}
+
+// HasRoomCodeReservation reports whether userID has reserved code.
+func HasRoomCodeReservation(db *gorm.DB, userID uint, code string) (bool, error) {
+	var count int64
+	err := db.Model(&model.RoomCodeReservation{}).
+		Where("code = ? AND user_id = ?", NormalizeRoomCode(code), userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ReleaseRoomCode drops userID's reservation of code. Rooms already using
+// it keep it.
+func ReleaseRoomCode(db *gorm.DB, userID uint, code string) error {
+	res := db.Where("code = ? AND user_id = ?", NormalizeRoomCode(code), userID).
+		Delete(&model.RoomCodeReservation{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrReservationNotFound
+	}
+	return nil
+}
+
+// dropRoomCodeConstraint removes the unique constraint rooms.code had before
+// codes of archived and deleted rooms could be reused. It runs before
+// AutoMigrate, which would otherwise try to drop it under gorm's name only.
+func dropRoomCodeConstraint(db *gorm.DB) error {
+	for _, name := range []string{"uni_rooms_code", "rooms_code_key"} {
+		if err := db.Exec("ALTER TABLE IF EXISTS rooms DROP CONSTRAINT IF EXISTS " + name).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateRoomCodes upper-cases codes so they can be matched
+// case-insensitively, and keeps them unique among rooms that are neither
+// archived nor deleted.
+func migrateRoomCodes(db *gorm.DB) error {
+	for _, stmt := range []string{
+		"UPDATE rooms SET code = UPPER(code) WHERE code <> UPPER(code)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_rooms_code_active ON rooms (code) WHERE archived_at IS NULL AND deleted_at IS NULL",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestCodeAlphabet(t *testing.T) {
+	tests := map[string]string{
+		"":          defaultCodeAlphabet,
+		"abcABC123": "ABC123",
+		"a b\tc":    "ABC",
+		"aaaa":      defaultCodeAlphabet,
+		"xyzXYZ!?":  "XYZ!?",
+		"ÄÖü":       "ÄÖÜ",
+	}
+	for env, want := range tests {
+		t.Setenv("ROOM_CODE_ALPHABET", env)
+		if got := codeAlphabet(); got != want {
+			t.Errorf("ROOM_CODE_ALPHABET=%q: alphabet = %q, want %q", env, got, want)
+		}
+	}
+}
+
+func TestCodeLength(t *testing.T) {
+	tests := map[string]int{"": 6, "4": 4, "32": 32, "3": 6, "33": 6, "six": 6}
+	for env, want := range tests {
+		t.Setenv("ROOM_CODE_LENGTH", env)
+		if got := codeLength(); got != want {
+			t.Errorf("ROOM_CODE_LENGTH=%q: length = %d, want %d", env, got, want)
+		}
+	}
+}
+
+func TestNewRoomCode(t *testing.T) {
+	t.Setenv("ROOM_CODE_LENGTH", "8")
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		code, err := NewRoomCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 8 {
+			t.Fatalf("code %q has length %d, want 8", code, len(code))
+		}
+		if strings.Trim(code, defaultCodeAlphabet) != "" {
+			t.Fatalf("code %q is outside the alphabet", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) < 199 {
+		t.Errorf("only %d distinct codes out of 200", len(seen))
+	}
+
+	t.Setenv("ROOM_CODE_ALPHABET", "xy")
+	code, _ := NewRoomCode()
+	if strings.Trim(code, "XY") != "" {
+		t.Errorf("code %q is not from ROOM_CODE_ALPHABET", code)
+	}
+}
+
+func TestNormalizeRoomCode(t *testing.T) {
+	if got := NormalizeRoomCode("  abc12x\n"); got != "ABC12X" {
+		t.Errorf("NormalizeRoomCode = %q", got)
+	}
+}
+
+func TestCreateRoomCodes(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+
+	room := model.Room{Name: "Vanity", HostID: alice.ID, Code: " party "}
+	if err := CreateRoom(db, &room); err != nil {
+		t.Fatal(err)
+	}
+	if room.Code != "PARTY" {
+		t.Errorf("code = %q, want PARTY", room.Code)
+	}
+	if found, err := FindRoom(db, "party"); err != nil || found.ID != room.ID {
+		t.Errorf("FindRoom(party) = %d, %v", found.ID, err)
+	}
+
+	taken := model.Room{Name: "Copy", HostID: bob.ID, Code: "Party"}
+	if err := CreateRoom(db, &taken); err != ErrCodeTaken {
+		t.Fatalf("duplicate code: err = %v, want ErrCodeTaken", err)
+	}
+
+	// Once the room is archived its code is free again.
+	db.Model(&room).UpdateColumns(map[string]interface{}{"closed_at": time.Now(), "archived_at": time.Now()})
+	reused := model.Room{Name: "Again", HostID: bob.ID, Code: "party"}
+	if err := CreateRoom(db, &reused); err != nil {
+		t.Fatalf("reusing an archived room's code: %v", err)
+	}
+	if found, _ := FindRoom(db, "PARTY"); found.ID != reused.ID {
+		t.Errorf("PARTY resolves to room %d, want %d", found.ID, reused.ID)
+	}
+
+	// And likewise once it is deleted.
+	if err := DeleteRoom(db, &reused); err != nil {
+		t.Fatal(err)
+	}
+	again := model.Room{Name: "Third", HostID: alice.ID, Code: "party"}
+	if err := CreateRoom(db, &again); err != nil {
+		t.Fatalf("reusing a deleted room's code: %v", err)
+	}
+}
+
+func TestCreateRoomSkipsReservedCodes(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+
+	// With a two letter alphabet and the shortest length there are only 16
+	// codes; reserve all but one.
+	t.Setenv("ROOM_CODE_ALPHABET", "AB")
+	t.Setenv("ROOM_CODE_LENGTH", "4")
+	for i := 0; i < 15; i++ {
+		code := strings.NewReplacer("0", "A", "1", "B").Replace(fmt.Sprintf("%04b", i))
+		if _, err := ReserveRoomCode(db, alice.ID, code, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every attempt may land on a reserved code, which is fine as long as
+	// none is handed out.
+	for i := 0; i < 5; i++ {
+		room := model.Room{Name: "Random", HostID: bob.ID}
+		err := CreateRoom(db, &room)
+		if err == ErrCodeExhausted {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if room.Code != "BBBB" {
+			t.Fatalf("got reserved code %q", room.Code)
+		}
+		break
+	}
+}
+
+func TestReserveRoomCode(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+
+	if _, err := ReserveRoomCode(db, alice.ID, "movies", 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReserveRoomCode(db, alice.ID, "MOVIES", 2); err != nil {
+		t.Errorf("reserving a held code again: %v", err)
+	}
+	if _, err := ReserveRoomCode(db, bob.ID, "Movies", 2); err != ErrCodeTaken {
+		t.Errorf("bob reserving alice's code: err = %v, want ErrCodeTaken", err)
+	}
+	if _, err := ReserveRoomCode(db, alice.ID, "series", 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReserveRoomCode(db, alice.ID, "films", 2); err != ErrReservationLimit {
+		t.Errorf("third reservation: err = %v, want ErrReservationLimit", err)
+	}
+
+	// A live room of someone else keeps its code; the user's own doesn't
+	// stand in their way.
+	bobs := model.Room{Name: "Bob's", HostID: bob.ID, Code: "BOBS"}
+	alices := model.Room{Name: "Alice's", HostID: alice.ID, Code: "ALICES"}
+	CreateRoom(db, &bobs)
+	CreateRoom(db, &alices)
+	if _, err := ReserveRoomCode(db, alice.ID, "bobs", 5); err != ErrCodeTaken {
+		t.Errorf("reserving a live room's code: err = %v, want ErrCodeTaken", err)
+	}
+	if _, err := ReserveRoomCode(db, alice.ID, "alices", 5); err != nil {
+		t.Errorf("reserving the code of one's own room: %v", err)
+	}
+
+	if ok, _ := HasRoomCodeReservation(db, alice.ID, "movies"); !ok {
+		t.Error("HasRoomCodeReservation = false")
+	}
+	if err := ReleaseRoomCode(db, bob.ID, "movies"); err != ErrReservationNotFound {
+		t.Errorf("bob releasing alice's code: err = %v", err)
+	}
+	if err := ReleaseRoomCode(db, alice.ID, "movies"); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := ListRoomCodeReservations(db, alice.ID)
+	if len(list) != 2 || list[0].Code != "ALICES" || list[1].Code != "SERIES" {
+		t.Errorf("reservations = %+v", list)
+	}
+}
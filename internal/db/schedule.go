@@ -0,0 +1,77 @@
+package db
+
+import (
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// SetSchedule schedules room to start at start, repeating per recurrence in
+// start's timezone.
+func SetSchedule(db *gorm.DB, room *model.Room, start time.Time, recurrence string) error {
+	room.Schedule(start, recurrence, time.Now())
+	return db.Model(&model.Room{}).Where("id = ?", room.ID).UpdateColumns(map[string]interface{}{
+		"scheduled_at":  room.ScheduledAt,
+		"timezone":      room.Timezone,
+		"recurrence":    room.Recurrence,
+		"next_start_at": room.NextStartAt,
+	}).Error
+}
+
+// ClearSchedule removes room's schedule.
+func ClearSchedule(db *gorm.DB, room *model.Room) error {
+	room.ScheduledAt = nil
+	room.Timezone = ""
+	room.Recurrence = model.RecurNone
+	room.NextStartAt = nil
+	return db.Model(&model.Room{}).Where("id = ?", room.ID).UpdateColumns(map[string]interface{}{
+		"scheduled_at":  nil,
+		"timezone":      "",
+		"recurrence":    model.RecurNone,
+		"next_start_at": nil,
+	}).Error
+}
+
+// StartDueRooms starts playback from the beginning in open rooms whose
+// scheduled start has come, moves weekly rooms on to their next showing,
+// and returns the rooms it started. Each start is a conditional update, so
+// instances running this concurrently start a room only once.
+func StartDueRooms(db *gorm.DB, now time.Time) ([]model.Room, error) {
+	var due []model.Room
+	if err := db.Where("next_start_at <= ? AND closed_at IS NULL", now).
+		Find(&due).Error; err != nil {
+		return nil, err
+	}
+
+	started := make([]model.Room, 0, len(due))
+	for _, room := range due {
+		before := room
+		next := room.OccurrenceAfter(now)
+		res := db.Model(&model.Room{}).
+			Where("id = ? AND next_start_at = ?", room.ID, room.NextStartAt).
+			UpdateColumns(map[string]interface{}{
+				"is_playing":       true,
+				"current_time":     0,
+				"next_start_at":    next,
+				"updated_at":       now,
+				"last_activity_at": now,
+			})
+		if res.Error != nil {
+			return started, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		room.IsPlaying = true
+		room.CurrentTime = 0
+		room.NextStartAt = next
+		room.UpdatedAt = now
+		room.LastActivityAt = now
+		started = append(started, room)
+		if err := RecordPlayback(db, &before, &room, 0); err != nil {
+			return started, err
+		}
+	}
+	return started, nil
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestSetAndClearSchedule(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	room := newTestRoom(t, db, host.ID)
+
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := SetSchedule(db, &room, start, model.RecurWeekly); err != nil {
+		t.Fatalf("SetSchedule: %v", err)
+	}
+	var stored model.Room
+	db.First(&stored, room.ID)
+	if stored.ScheduledAt == nil || !stored.ScheduledAt.Equal(start) || stored.Recurrence != model.RecurWeekly ||
+		stored.NextStartAt == nil || !stored.NextStartAt.Equal(start) {
+		t.Errorf("stored schedule = %v %q next %v", stored.ScheduledAt, stored.Recurrence, stored.NextStartAt)
+	}
+
+	if err := ClearSchedule(db, &room); err != nil {
+		t.Fatalf("ClearSchedule: %v", err)
+	}
+	db.First(&stored, room.ID)
+	if stored.ScheduledAt != nil || stored.NextStartAt != nil || stored.Recurrence != model.RecurNone || stored.Timezone != "" {
+		t.Errorf("schedule left after clearing: %+v", stored)
+	}
+}
+
+func TestStartDueRooms(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	now := time.Now().Truncate(time.Second)
+	start := now.Add(-time.Minute)
+
+	schedule := func(recurrence string) model.Room {
+		room := newTestRoom(t, db, host.ID)
+		room.ScheduledAt = &start
+		room.Recurrence = recurrence
+		room.Timezone = "UTC"
+		db.Model(&room).UpdateColumns(map[string]interface{}{
+			"scheduled_at":  start,
+			"recurrence":    recurrence,
+			"timezone":      "UTC",
+			"next_start_at": start,
+			"current_time":  42,
+		})
+		return room
+	}
+	once := schedule(model.RecurNone)
+	weekly := schedule(model.RecurWeekly)
+	closed := schedule(model.RecurNone)
+	if err := CloseRoom(db, &closed); err != nil {
+		t.Fatal(err)
+	}
+	later := newTestRoom(t, db, host.ID)
+	if err := SetSchedule(db, &later, now.Add(time.Hour), model.RecurNone); err != nil {
+		t.Fatal(err)
+	}
+
+	started, err := StartDueRooms(db, now)
+	if err != nil {
+		t.Fatalf("StartDueRooms: %v", err)
+	}
+	ids := map[uint]bool{}
+	for _, room := range started {
+		ids[room.ID] = true
+	}
+	if len(started) != 2 || !ids[once.ID] || !ids[weekly.ID] {
+		t.Fatalf("started %v, want rooms %d and %d", ids, once.ID, weekly.ID)
+	}
+
+	var stored model.Room
+	db.First(&stored, once.ID)
+	if !stored.IsPlaying || stored.CurrentTime != 0 || stored.NextStartAt != nil {
+		t.Errorf("one-off room = playing %v at %v, next %v", stored.IsPlaying, stored.CurrentTime, stored.NextStartAt)
+	}
+	db.First(&stored, weekly.ID)
+	if want := start.AddDate(0, 0, 7); !stored.IsPlaying || stored.NextStartAt == nil || !stored.NextStartAt.Equal(want) {
+		t.Errorf("weekly room = playing %v, next %v, want %v", stored.IsPlaying, stored.NextStartAt, want)
+	}
+	db.First(&stored, later.ID)
+	if stored.IsPlaying {
+		t.Error("a room scheduled for later was started")
+	}
+
+	var plays int64
+	db.Model(&model.PlaybackEvent{}).Where("room_id = ? AND type = ? AND user_id = 0", once.ID, model.PlaybackPlay).Count(&plays)
+	if plays != 1 {
+		t.Errorf("%d scheduler play events recorded, want 1", plays)
+	}
+
+	// Another instance checking at the same moment starts nothing.
+	again, err := StartDueRooms(db, now)
+	if err != nil || len(again) != 0 {
+		t.Errorf("second run started %d rooms, %v", len(again), err)
+	}
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// ErrSessionInvalid is returned for unknown, expired or revoked sessions.
+var ErrSessionInvalid = errors.New("session is invalid or revoked")
+
+// CreateSession records a new login of userID and fills in its JTI.
+func CreateSession(db *gorm.DB, s *model.Session, ttl time.Duration) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	now := time.Now()
+	s.JTI = base64.RawURLEncoding.EncodeToString(b)
+	s.CreatedAt = now
+	s.LastSeenAt = now
+	s.ExpiresAt = now.Add(ttl)
+	return db.Create(s).Error
+}
+
+// TouchSession returns the active session with jti, recording that it was
+// just used.
+func TouchSession(db *gorm.DB, jti string) (model.Session, error) {
+	var s model.Session
+	if err := db.Where("jti = ?", jti).First(&s).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return s, ErrSessionInvalid
+		}
+		return s, err
+	}
+
+	now := time.Now()
+	if !s.Active(now) {
+		return s, ErrSessionInvalid
+	}
+	if now.Sub(s.LastSeenAt) > lastUsedResolution {
+		if err := db.Model(&s).Update("last_seen_at", now).Error; err != nil {
+			return s, err
+		}
+		s.LastSeenAt = now
+	}
+	return s, nil
+}
+
+// ListSessions returns the active sessions of userID, most recently used
+// first.
+func ListSessions(db *gorm.DB, userID uint) ([]model.Session, error) {
+	var sessions []model.Session
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession revokes session id of userID.
+func RevokeSession(db *gorm.DB, userID, id uint) error {
+	res := db.Model(&model.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions revokes every session of userID except keepID, which
+// may be zero to revoke them all.
+func RevokeOtherSessions(db *gorm.DB, userID, keepID uint) error {
+	return db.Model(&model.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestSessionLifecycle(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+
+	var sessions [3]model.Session
+	for i := range sessions {
+		sessions[i] = model.Session{UserID: alice.ID, UserAgent: "test"}
+		if err := CreateSession(db, &sessions[i], time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sessions[0].JTI == "" || sessions[0].JTI == sessions[1].JTI {
+		t.Fatalf("JTIs = %q, %q", sessions[0].JTI, sessions[1].JTI)
+	}
+
+	got, err := TouchSession(db, sessions[0].JTI)
+	if err != nil || got.ID != sessions[0].ID {
+		t.Fatalf("TouchSession = %d, %v", got.ID, err)
+	}
+	if _, err := TouchSession(db, "unknown"); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("unknown JTI: err = %v", err)
+	}
+
+	if err := RevokeSession(db, bob.ID, sessions[1].ID); err == nil {
+		t.Error("bob revoked alice's session")
+	}
+	if err := RevokeSession(db, alice.ID, sessions[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RevokeSession(db, alice.ID, sessions[1].ID); err == nil {
+		t.Error("revoking a session twice succeeded")
+	}
+	if _, err := TouchSession(db, sessions[1].JTI); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("revoked session: err = %v", err)
+	}
+
+	if err := RevokeOtherSessions(db, alice.ID, sessions[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	active, err := ListSessions(db, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].ID != sessions[0].ID {
+		t.Errorf("active sessions = %+v, want only the kept one", active)
+	}
+}
+
+func TestTouchSessionExpired(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+
+	s := model.Session{UserID: alice.ID}
+	if err := CreateSession(db, &s, -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TouchSession(db, s.JTI); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("expired session: err = %v", err)
+	}
+	if active, _ := ListSessions(db, alice.ID); len(active) != 0 {
+		t.Errorf("expired session listed: %+v", active)
+	}
+}
@@ -0,0 +1,279 @@
+package db
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// Timeline entry types besides the playback event types.
+const (
+	TimelineJoin  = "join"
+	TimelineLeave = "leave"
+)
+
+// seekTolerance is how far, in seconds, a playback update may land from
+// where playback would have got to before it counts as a seek.
+const seekTolerance = 3
+
+// presenceTimeout is how long a participant may go unseen before their
+// visit ends. Coming back later starts a new visit.
+func presenceTimeout() time.Duration {
+	return config.Duration("PRESENCE_TIMEOUT", 2*time.Minute)
+}
+
+// recordVisit extends userID's latest visit to roomID up to now, or starts a
+// new visit if they were away longer than presenceTimeout or joined just
+// now.
+func recordVisit(db *gorm.DB, roomID, userID uint, now time.Time, joined bool) error {
+	if !joined {
+		res := db.Model(&model.RoomVisit{}).
+			Where(`id = (SELECT id FROM room_visits WHERE room_id = ? AND user_id = ?
+				ORDER BY started_at DESC, id DESC LIMIT 1) AND last_seen_at >= ?`,
+				roomID, userID, now.Add(-presenceTimeout())).
+			UpdateColumn("last_seen_at", now)
+		if res.Error != nil || res.RowsAffected > 0 {
+			return res.Error
+		}
+	}
+	return db.Create(&model.RoomVisit{
+		RoomID:     roomID,
+		UserID:     userID,
+		StartedAt:  now,
+		LastSeenAt: now,
+	}).Error
+}
+
+// RecordPlayback logs the playback changes userID made to a room going from
+// before to after; see playbackEvents.
+func RecordPlayback(db *gorm.DB, before, after *model.Room, userID uint) error {
+	for _, ev := range playbackEvents(before, after, userID) {
+		if err := db.Create(&ev).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// playbackEvents returns the changes from before to after: starting,
+// pausing, seeking and switching videos. A seek is an update that lands more
+// than seekTolerance away from where playback would have got to. Switching
+// videos pauses the old one where it had got to rather than seeking.
+func playbackEvents(before, after *model.Room, userID uint) []model.PlaybackEvent {
+	expected := before.CurrentTime
+	if before.IsPlaying {
+		expected += after.UpdatedAt.Sub(before.UpdatedAt).Seconds()
+	}
+
+	event := func(t string, position float64, videoURL string) model.PlaybackEvent {
+		return model.PlaybackEvent{
+			RoomID:   after.ID,
+			UserID:   userID,
+			Type:     t,
+			Position: position,
+			VideoURL: videoURL,
+			At:       after.UpdatedAt,
+		}
+	}
+
+	var events []model.PlaybackEvent
+	if after.VideoURL != before.VideoURL {
+		if before.IsPlaying {
+			events = append(events, event(model.PlaybackPause, expected, before.VideoURL))
+		}
+		events = append(events, event(model.PlaybackVideo, after.CurrentTime, after.VideoURL))
+		if after.IsPlaying {
+			events = append(events, event(model.PlaybackPlay, after.CurrentTime, after.VideoURL))
+		}
+		return events
+	}
+
+	if math.Abs(after.CurrentTime-expected) > seekTolerance {
+		events = append(events, event(model.PlaybackSeek, after.CurrentTime, after.VideoURL))
+	}
+	if after.IsPlaying && !before.IsPlaying {
+		events = append(events, event(model.PlaybackPlay, after.CurrentTime, after.VideoURL))
+	}
+	if !after.IsPlaying && before.IsPlaying {
+		events = append(events, event(model.PlaybackPause, after.CurrentTime, after.VideoURL))
+	}
+	return events
+}
+
+// RoomStats sums up the participation and playback of a room.
+type RoomStats struct {
+	PeakParticipants    int
+	PeakAt              *time.Time
+	AverageParticipants float64 // from the first visit to the end of the last
+	TotalWatchTime      time.Duration
+	Viewers             []ViewerStats
+	Timeline            []TimelineEntry
+	Plays               int
+	Pauses              int
+	Seeks               int
+	Videos              []VideoPlay
+	Playback            []model.PlaybackEvent
+}
+
+// ViewerStats is the time one user spent in a room.
+type ViewerStats struct {
+	UserID    uint
+	WatchTime time.Duration
+	Visits    int
+}
+
+// TimelineEntry is a user joining or leaving a room.
+type TimelineEntry struct {
+	At     time.Time
+	Type   string
+	UserID uint
+}
+
+// VideoPlay is a video played in a room, from when it was first started.
+type VideoPlay struct {
+	VideoURL  string
+	StartedAt time.Time
+}
+
+// interval is a span of presence.
+type interval struct {
+	start, end time.Time
+}
+
+// ComputeRoomStats loads the visits and playback events of room and sums
+// them up. Visits still under way count up to when the user was last seen.
+func ComputeRoomStats(db *gorm.DB, room *model.Room) (RoomStats, error) {
+	var visits []model.RoomVisit
+	if err := db.Where("room_id = ?", room.ID).Order("started_at, id").Find(&visits).Error; err != nil {
+		return RoomStats{}, err
+	}
+	var playback []model.PlaybackEvent
+	if err := db.Where("room_id = ?", room.ID).Order("at, id").Find(&playback).Error; err != nil {
+		return RoomStats{}, err
+	}
+
+	now := time.Now()
+	if room.ClosedAt != nil {
+		now = *room.ClosedAt
+	}
+	return summarizeRoomStats(room, visits, playback, now), nil
+}
+
+// summarizeRoomStats sums up room's visits, in start order, and playback
+// events, in time order, as of now. Visits last seen more than
+// presenceTimeout before now, or in a closed room, have ended.
+func summarizeRoomStats(room *model.Room, visits []model.RoomVisit, playback []model.PlaybackEvent, now time.Time) RoomStats {
+	stats := RoomStats{Playback: playback}
+	stale := now.Add(-presenceTimeout())
+
+	byUser := make(map[uint][]model.RoomVisit)
+	var order []uint
+	for _, v := range visits {
+		if _, ok := byUser[v.UserID]; !ok {
+			order = append(order, v.UserID)
+		}
+		byUser[v.UserID] = append(byUser[v.UserID], v)
+	}
+
+	var presence []interval
+	for _, userID := range order {
+		userVisits := byUser[userID]
+		viewer := ViewerStats{UserID: userID}
+
+		// Concurrent requests may open overlapping visits; merge them so
+		// nobody's time or visits are counted twice.
+		var merged []interval
+		for i, v := range userVisits {
+			stats.Timeline = append(stats.Timeline, TimelineEntry{At: v.StartedAt, Type: TimelineJoin, UserID: userID})
+			if i < len(userVisits)-1 || room.ClosedAt != nil || v.LastSeenAt.Before(stale) {
+				stats.Timeline = append(stats.Timeline, TimelineEntry{At: v.LastSeenAt, Type: TimelineLeave, UserID: userID})
+			}
+
+			if n := len(merged); n > 0 && !v.StartedAt.After(merged[n-1].end) {
+				if v.LastSeenAt.After(merged[n-1].end) {
+					merged[n-1].end = v.LastSeenAt
+				}
+				continue
+			}
+			merged = append(merged, interval{v.StartedAt, v.LastSeenAt})
+		}
+		for _, iv := range merged {
+			viewer.WatchTime += iv.end.Sub(iv.start)
+		}
+		viewer.Visits = len(merged)
+		stats.TotalWatchTime += viewer.WatchTime
+		stats.Viewers = append(stats.Viewers, viewer)
+		presence = append(presence, merged...)
+	}
+	sort.SliceStable(stats.Timeline, func(i, j int) bool { return stats.Timeline[i].At.Before(stats.Timeline[j].At) })
+	sort.SliceStable(stats.Viewers, func(i, j int) bool { return stats.Viewers[i].WatchTime > stats.Viewers[j].WatchTime })
+
+	stats.PeakParticipants, stats.PeakAt, stats.AverageParticipants = concurrency(presence)
+
+	lastVideo := ""
+	for _, ev := range stats.Playback {
+		switch ev.Type {
+		case model.PlaybackPlay:
+			stats.Plays++
+			if ev.VideoURL != lastVideo {
+				stats.Videos = append(stats.Videos, VideoPlay{VideoURL: ev.VideoURL, StartedAt: ev.At})
+				lastVideo = ev.VideoURL
+			}
+		case model.PlaybackPause:
+			stats.Pauses++
+		case model.PlaybackSeek:
+			stats.Seeks++
+		}
+	}
+	return stats
+}
+
+// concurrency returns the peak number of overlapping intervals, when it was
+// first reached, and the average number over the span from the first start
+// to the last end.
+func concurrency(intervals []interval) (peak int, peakAt *time.Time, average float64) {
+	if len(intervals) == 0 {
+		return 0, nil, 0
+	}
+
+	type point struct {
+		at    time.Time
+		delta int
+	}
+	points := make([]point, 0, 2*len(intervals))
+	for _, iv := range intervals {
+		points = append(points, point{iv.start, 1}, point{iv.end, -1})
+	}
+	// Starts go first at the same instant, so a visit of a single request
+	// still counts towards the peak.
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].at.Equal(points[j].at) {
+			return points[i].delta > points[j].delta
+		}
+		return points[i].at.Before(points[j].at)
+	})
+
+	var area float64
+	count := 0
+	for i, p := range points {
+		if i > 0 {
+			area += float64(count) * p.at.Sub(points[i-1].at).Seconds()
+		}
+		count += p.delta
+		if count > peak {
+			peak = count
+			at := p.at
+			peakAt = &at
+		}
+	}
+
+	span := points[len(points)-1].at.Sub(points[0].at).Seconds()
+	if span <= 0 {
+		return peak, peakAt, float64(peak)
+	}
+	return peak, peakAt, area / span
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+var statsEpoch = time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC)
+
+// minute returns the time m minutes into the test's party.
+func minute(m float64) time.Time {
+	return statsEpoch.Add(time.Duration(m * float64(time.Minute)))
+}
+
+func TestPlaybackEvents(t *testing.T) {
+	const a, b = "https://example.com/a", "https://example.com/b"
+	room := func(video string, playing bool, position float64, at time.Time) *model.Room {
+		return &model.Room{ID: 1, VideoURL: video, IsPlaying: playing, CurrentTime: position, UpdatedAt: at}
+	}
+
+	tests := []struct {
+		name          string
+		before, after *model.Room
+		want          string
+	}{
+		{"play", room(a, false, 10, minute(0)), room(a, true, 10, minute(1)), "[play@10 a]"},
+		{"pause", room(a, true, 10, minute(0)), room(a, false, 70, minute(1)), "[pause@70 a]"},
+		{"sync within tolerance", room(a, true, 10, minute(0)), room(a, true, 72, minute(1)), "[]"},
+		{"seek while playing", room(a, true, 10, minute(0)), room(a, true, 300, minute(1)), "[seek@300 a]"},
+		{"seek while paused", room(a, false, 10, minute(0)), room(a, false, 300, minute(1)), "[seek@300 a]"},
+		{"seek and play", room(a, false, 42, minute(0)), room(a, true, 0, minute(1)), "[seek@0 a play@0 a]"},
+		{"no change", room(a, false, 10, minute(0)), room(a, false, 10, minute(1)), "[]"},
+		// Switching videos pauses the old one where it had got to, without
+		// counting the jump to the new one's start as a seek.
+		{"switch while playing", room(a, true, 10, minute(0)), room(b, true, 0, minute(1)), "[pause@70 a video@0 b play@0 b]"},
+		{"switch while paused", room(a, false, 10, minute(0)), room(b, false, 0, minute(1)), "[video@0 b]"},
+		{"remove the video", room(a, true, 10, minute(0)), room("", false, 0, minute(1)), "[pause@70 a video@0 ]"},
+	}
+	for _, tt := range tests {
+		events := playbackEvents(tt.before, tt.after, 7)
+		var got []string
+		for _, ev := range events {
+			if ev.RoomID != 1 || ev.UserID != 7 || !ev.At.Equal(tt.after.UpdatedAt) {
+				t.Errorf("%s: event %+v is not from the update", tt.name, ev)
+			}
+			video := ""
+			if ev.VideoURL != "" {
+				video = ev.VideoURL[len(ev.VideoURL)-1:]
+			}
+			got = append(got, fmt.Sprintf("%s@%g %s", ev.Type, ev.Position, video))
+		}
+		if s := fmt.Sprint(got); s != tt.want {
+			t.Errorf("%s: events = %s, want %s", tt.name, s, tt.want)
+		}
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	if peak, at, avg := concurrency(nil); peak != 0 || at != nil || avg != 0 {
+		t.Errorf("no presence = %d, %v, %v", peak, at, avg)
+	}
+
+	tests := []struct {
+		name      string
+		intervals []interval
+		peak      int
+		peakAt    time.Time
+		average   float64
+	}{
+		{"single request", []interval{{minute(1), minute(1)}}, 1, minute(1), 1},
+		{"overlapping", []interval{{minute(0), minute(10)}, {minute(5), minute(15)}}, 2, minute(5), 20.0 / 15},
+		// Starts come first at the same instant, so hand-overs overlap.
+		{"back to back", []interval{{minute(0), minute(10)}, {minute(10), minute(20)}}, 2, minute(10), 1},
+		{"with a gap", []interval{{minute(0), minute(10)}, {minute(20), minute(30)}}, 1, minute(0), 20.0 / 30},
+		{"nested", []interval{{minute(0), minute(30)}, {minute(5), minute(10)}, {minute(6), minute(7)}}, 3, minute(6), (30.0 + 5 + 1) / 30},
+	}
+	for _, tt := range tests {
+		peak, at, avg := concurrency(tt.intervals)
+		if peak != tt.peak || at == nil || !at.Equal(tt.peakAt) || math.Abs(avg-tt.average) > 1e-9 {
+			t.Errorf("%s: concurrency = %d at %v, average %v; want %d at %v, average %v",
+				tt.name, peak, at, avg, tt.peak, tt.peakAt, tt.average)
+		}
+	}
+}
+
+func TestSummarizeRoomStats(t *testing.T) {
+	const a, b = "https://example.com/a", "https://example.com/b"
+	visits := []model.RoomVisit{
+		{UserID: 1, StartedAt: minute(0), LastSeenAt: minute(10)},
+		{UserID: 2, StartedAt: minute(2), LastSeenAt: minute(20)},
+		// A concurrent request opened an overlapping visit.
+		{UserID: 1, StartedAt: minute(5), LastSeenAt: minute(12)},
+		// Still under way at minute 40.
+		{UserID: 1, StartedAt: minute(30), LastSeenAt: minute(39.5)},
+	}
+	playback := []model.PlaybackEvent{
+		{Type: model.PlaybackPlay, VideoURL: a, At: minute(1)},
+		{Type: model.PlaybackPause, VideoURL: a, At: minute(3)},
+		{Type: model.PlaybackSeek, VideoURL: a, At: minute(4)},
+		{Type: model.PlaybackPlay, VideoURL: a, At: minute(5)},
+		{Type: model.PlaybackPause, VideoURL: a, At: minute(8)},
+		{Type: model.PlaybackVideo, VideoURL: b, At: minute(8)},
+		{Type: model.PlaybackPlay, VideoURL: b, At: minute(8)},
+		{Type: model.PlaybackPlay, VideoURL: a, At: minute(31)},
+	}
+	room := &model.Room{ID: 1}
+	stats := summarizeRoomStats(room, visits, playback, minute(40))
+
+	if stats.PeakParticipants != 2 || stats.PeakAt == nil || !stats.PeakAt.Equal(minute(2)) {
+		t.Errorf("peak = %d at %v, want 2 at %v", stats.PeakParticipants, stats.PeakAt, minute(2))
+	}
+	// 39.5 participant-minutes from minute 0 to 39.5.
+	if math.Abs(stats.AverageParticipants-1) > 1e-9 {
+		t.Errorf("average = %v, want 1", stats.AverageParticipants)
+	}
+	if stats.TotalWatchTime != 39*time.Minute+30*time.Second {
+		t.Errorf("total watch time = %v", stats.TotalWatchTime)
+	}
+	want := []ViewerStats{
+		{UserID: 1, WatchTime: 21*time.Minute + 30*time.Second, Visits: 2},
+		{UserID: 2, WatchTime: 18 * time.Minute, Visits: 1},
+	}
+	if fmt.Sprint(stats.Viewers) != fmt.Sprint(want) {
+		t.Errorf("viewers = %v, want %v", stats.Viewers, want)
+	}
+
+	timeline := func(s RoomStats) string {
+		var out []string
+		for _, e := range s.Timeline {
+			out = append(out, fmt.Sprintf("%g %s %d", e.At.Sub(statsEpoch).Minutes(), e.Type, e.UserID))
+		}
+		return fmt.Sprint(out)
+	}
+	// The open visit has no leave yet; user 2 was last seen too long ago.
+	if got, want := timeline(stats), "[0 join 1 2 join 2 5 join 1 10 leave 1 12 leave 1 20 leave 2 30 join 1]"; got != want {
+		t.Errorf("timeline = %s, want %s", got, want)
+	}
+
+	if stats.Plays != 4 || stats.Pauses != 2 || stats.Seeks != 1 || len(stats.Playback) != len(playback) {
+		t.Errorf("plays %d, pauses %d, seeks %d, playback %d", stats.Plays, stats.Pauses, stats.Seeks, len(stats.Playback))
+	}
+	var videos []string
+	for _, v := range stats.Videos {
+		videos = append(videos, fmt.Sprintf("%s@%g", v.VideoURL[len(v.VideoURL)-1:], v.StartedAt.Sub(statsEpoch).Minutes()))
+	}
+	if fmt.Sprint(videos) != "[a@1 b@8 a@31]" {
+		t.Errorf("videos = %v", videos)
+	}
+
+	// Every visit of a closed room has ended.
+	closedAt := minute(40)
+	room.ClosedAt = &closedAt
+	if got, want := timeline(summarizeRoomStats(room, visits, playback, closedAt)), "[0 join 1 2 join 2 5 join 1 10 leave 1 12 leave 1 20 leave 2 30 join 1 39.5 leave 1]"; got != want {
+		t.Errorf("closed room timeline = %s, want %s", got, want)
+	}
+
+	empty := summarizeRoomStats(&model.Room{ID: 2}, nil, nil, minute(40))
+	if empty.PeakParticipants != 0 || empty.PeakAt != nil || empty.TotalWatchTime != 0 || len(empty.Viewers) != 0 {
+		t.Errorf("empty room stats = %+v", empty)
+	}
+}
+
+func TestRecordVisit(t *testing.T) {
+	db := testDB(t)
+	t.Setenv("PRESENCE_TIMEOUT", "2m")
+	user := newTestUser(t, db, "alice")
+	host := newTestUser(t, db, "host")
+	room := newTestRoom(t, db, host.ID)
+
+	steps := []struct {
+		at     time.Time
+		joined bool
+	}{
+		{minute(0), true},
+		{minute(1), false},   // seen again: extends the visit
+		{minute(2.5), false}, // within the timeout of the last sighting
+		{minute(10), false},  // away too long: a new visit
+		{minute(10.5), true}, // joining again always starts one
+	}
+	for _, s := range steps {
+		if err := recordVisit(db, room.ID, user.ID, s.at, s.joined); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var visits []model.RoomVisit
+	db.Where("room_id = ? AND user_id = ?", room.ID, user.ID).Order("started_at").Find(&visits)
+	var got []string
+	for _, v := range visits {
+		got = append(got, fmt.Sprintf("%g-%g", v.StartedAt.Sub(statsEpoch).Minutes(), v.LastSeenAt.Sub(statsEpoch).Minutes()))
+	}
+	if fmt.Sprint(got) != "[0-2.5 10-10 10.5-10.5]" {
+		t.Errorf("visits = %v", got)
+	}
+}
+
+func TestComputeRoomStats(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	guest := newTestUser(t, db, "guest")
+	room := newTestRoom(t, db, host.ID)
+	if err := JoinRoom(db, &room, guest.ID, model.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+
+	before := room
+	after := room
+	after.VideoURL = "https://example.com/a"
+	after.IsPlaying = true
+	after.UpdatedAt = before.UpdatedAt.Add(time.Second)
+	if err := RecordPlayback(db, &before, &after, host.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := ComputeRoomStats(db, &room)
+	if err != nil {
+		t.Fatalf("ComputeRoomStats: %v", err)
+	}
+	if stats.PeakParticipants != 2 || len(stats.Viewers) != 2 {
+		t.Errorf("peak %d, viewers %+v", stats.PeakParticipants, stats.Viewers)
+	}
+	if stats.Plays != 1 || len(stats.Videos) != 1 || stats.Videos[0].VideoURL != after.VideoURL {
+		t.Errorf("plays %d, videos %+v", stats.Plays, stats.Videos)
+	}
+	if len(stats.Playback) != 2 || stats.Playback[0].Type != model.PlaybackVideo {
+		t.Errorf("playback = %+v", stats.Playback)
+	}
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTemplateExists is returned by SaveRoomTemplate when the user already
+	// has a template with the same name.
+	ErrTemplateExists = errors.New("room template already exists")
+	// ErrTemplateLimit is returned by SaveRoomTemplate when the user has as
+	// many templates as allowed.
+	ErrTemplateLimit = errors.New("room template limit reached")
+)
+
+// RoomSetup is what a room passes on to rooms created from it besides its
+// settings: who moderates it and what it has queued.
+type RoomSetup struct {
+	Moderators []uint
+	Queue      []model.QueueItem
+}
+
+// RoomSetupOf returns the setup of room.
+func RoomSetupOf(db *gorm.DB, room *model.Room) (RoomSetup, error) {
+	var setup RoomSetup
+	err := db.Model(&model.RoomParticipant{}).
+		Where("room_id = ? AND role = ? AND user_id <> ?", room.ID, model.RoleModerator, room.HostID).
+		Order("joined_at").
+		Pluck("user_id", &setup.Moderators).Error
+	if err != nil {
+		return setup, err
+	}
+	setup.Queue, err = ListQueue(db, room.ID)
+	return setup, err
+}
+
+// CreateRoomWithSetup stores room like CreateRoom and gives it setup, adding
+// its host and moderators as participants and copying the queue, all in one
+// transaction.
+func CreateRoomWithSetup(db *gorm.DB, room *model.Room, setup RoomSetup) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := CreateRoom(tx, room); err != nil {
+			return err
+		}
+		if err := TouchParticipant(tx, room, room.HostID); err != nil {
+			return err
+		}
+		for _, userID := range setup.Moderators {
+			if userID == room.HostID {
+				continue
+			}
+			if err := AddParticipant(tx, room.ID, userID, model.RoleModerator); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		for i, item := range setup.Queue {
+			copied := model.QueueItem{
+				RoomID:    room.ID,
+				Position:  i + 1,
+				VideoURL:  item.VideoURL,
+				Title:     item.Title,
+				Platform:  item.Platform,
+				AddedBy:   room.HostID,
+				CreatedAt: now,
+			}
+			if err := tx.Create(&copied).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveRoomTemplate stores tpl with its moderators and queue, allowing each
+// user at most limit templates. The user row is locked while counting, so
+// concurrent saves can't overshoot the limit.
+func SaveRoomTemplate(db *gorm.DB, tpl *model.RoomTemplate, limit int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&user, tpl.UserID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&model.RoomTemplate{}).Where("user_id = ?", tpl.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(limit) {
+			return ErrTemplateLimit
+		}
+		err := tx.Create(tpl).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrTemplateExists
+		}
+		return err
+	})
+}
+
+// ListRoomTemplates returns userID's templates by name, with their
+// moderators and queues preloaded.
+func ListRoomTemplates(db *gorm.DB, userID uint) ([]model.RoomTemplate, error) {
+	var templates []model.RoomTemplate
+	err := preloadTemplate(db).Where("user_id = ?", userID).Order("name").Find(&templates).Error
+	return templates, err
+}
+
+// FindRoomTemplate loads userID's template id with its moderators and queue.
+func FindRoomTemplate(db *gorm.DB, userID, id uint) (model.RoomTemplate, error) {
+	var tpl model.RoomTemplate
+	err := preloadTemplate(db).Where("user_id = ?", userID).First(&tpl, id).Error
+	return tpl, err
+}
+
+func preloadTemplate(db *gorm.DB) *gorm.DB {
+	return db.Preload("Moderators", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Preload("Queue", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	})
+}
+
+// DeleteRoomTemplate removes userID's template id, returning
+// gorm.ErrRecordNotFound if they have no such template.
+func DeleteRoomTemplate(db *gorm.DB, userID, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ?", userID).Delete(&model.RoomTemplate{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return deleteTemplateParts(tx, []uint{id})
+	})
+}
+
+// deleteTemplateParts removes the moderators and queues of the templates
+// ids.
+func deleteTemplateParts(tx *gorm.DB, ids []uint) error {
+	if err := tx.Where("template_id IN ?", ids).Delete(&model.RoomTemplateModerator{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("template_id IN ?", ids).Delete(&model.RoomTemplateItem{}).Error
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestRoomSetupRoundTrip(t *testing.T) {
+	db := testDB(t)
+	host := newTestUser(t, db, "host")
+	mod := newTestUser(t, db, "mod")
+	viewer := newTestUser(t, db, "viewer")
+	source := newTestRoom(t, db, host.ID)
+	AddParticipant(db, source.ID, mod.ID, model.RoleModerator)
+	AddParticipant(db, source.ID, viewer.ID, model.RoleViewer)
+	for _, title := range []string{"one", "two"} {
+		item := model.QueueItem{RoomID: source.ID, VideoURL: "https://vimeo.com/" + title, Title: title, Platform: model.PlatformVimeo, AddedBy: mod.ID}
+		if err := AddToQueue(db, &item, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setup, err := RoomSetupOf(db, &source)
+	if err != nil {
+		t.Fatalf("RoomSetupOf: %v", err)
+	}
+	if fmt.Sprint(setup.Moderators) != fmt.Sprint([]uint{mod.ID}) || len(setup.Queue) != 2 {
+		t.Fatalf("setup = %+v", setup)
+	}
+
+	// The new host is also listed as a moderator, which is skipped: hosting
+	// comes from HostID, not the participant's role.
+	room := model.Room{Name: "Copy", HostID: mod.ID, Visibility: model.VisibilityPublic, JoinPolicy: model.JoinOpen}
+	if err := CreateRoomWithSetup(db, &room, RoomSetup{Moderators: []uint{mod.ID, host.ID}, Queue: setup.Queue}); err != nil {
+		t.Fatalf("CreateRoomWithSetup: %v", err)
+	}
+	var roles []model.RoomParticipant
+	db.Where("room_id = ?", room.ID).Order("user_id").Find(&roles)
+	got := map[uint]string{}
+	for _, p := range roles {
+		got[p.UserID] = p.Role
+	}
+	if len(got) != 2 || got[mod.ID] != model.RoleViewer || got[host.ID] != model.RoleModerator {
+		t.Errorf("participants = %v", got)
+	}
+	items, _ := ListQueue(db, room.ID)
+	if len(items) != 2 || items[0].Title != "one" || items[1].Position != 2 || items[0].AddedBy != mod.ID || items[0].Platform != model.PlatformVimeo {
+		t.Errorf("copied queue = %+v", items)
+	}
+}
+
+func TestSaveRoomTemplate(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+
+	tpl := model.RoomTemplate{
+		UserID:     alice.ID,
+		Name:       "Friday",
+		RoomName:   "Friday film club",
+		Moderators: []model.RoomTemplateModerator{{UserID: bob.ID}},
+		Queue:      []model.RoomTemplateItem{{Position: 2, Title: "second"}, {Position: 1, Title: "first"}},
+	}
+	if err := SaveRoomTemplate(db, &tpl, 2); err != nil {
+		t.Fatalf("SaveRoomTemplate: %v", err)
+	}
+	if err := SaveRoomTemplate(db, &model.RoomTemplate{UserID: alice.ID, Name: "Friday"}, 2); err != ErrTemplateExists {
+		t.Errorf("saving a duplicate name = %v, want ErrTemplateExists", err)
+	}
+	if err := SaveRoomTemplate(db, &model.RoomTemplate{UserID: bob.ID, Name: "Friday"}, 2); err != nil {
+		t.Errorf("another user reusing the name: %v", err)
+	}
+	if err := SaveRoomTemplate(db, &model.RoomTemplate{UserID: alice.ID, Name: "Sunday"}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveRoomTemplate(db, &model.RoomTemplate{UserID: alice.ID, Name: "Monday"}, 2); err != ErrTemplateLimit {
+		t.Errorf("saving past the limit = %v, want ErrTemplateLimit", err)
+	}
+
+	found, err := FindRoomTemplate(db, alice.ID, tpl.ID)
+	if err != nil {
+		t.Fatalf("FindRoomTemplate: %v", err)
+	}
+	if len(found.Moderators) != 1 || found.Moderators[0].UserID != bob.ID ||
+		len(found.Queue) != 2 || found.Queue[0].Title != "first" {
+		t.Errorf("template = %+v", found)
+	}
+	if _, err := FindRoomTemplate(db, bob.ID, tpl.ID); err != gorm.ErrRecordNotFound {
+		t.Errorf("finding another user's template = %v, want not found", err)
+	}
+
+	list, _ := ListRoomTemplates(db, alice.ID)
+	if len(list) != 2 || list[0].Name != "Friday" || list[1].Name != "Sunday" {
+		t.Errorf("templates = %+v", list)
+	}
+
+	if err := DeleteRoomTemplate(db, bob.ID, tpl.ID); err != gorm.ErrRecordNotFound {
+		t.Errorf("deleting another user's template = %v, want not found", err)
+	}
+	if err := DeleteRoomTemplate(db, alice.ID, tpl.ID); err != nil {
+		t.Fatalf("DeleteRoomTemplate: %v", err)
+	}
+	var parts int64
+	db.Model(&model.RoomTemplateItem{}).Where("template_id = ?", tpl.ID).Count(&parts)
+	if parts != 0 {
+		t.Errorf("%d queued videos of the deleted template were kept", parts)
+	}
+	db.Model(&model.RoomTemplateModerator{}).Where("template_id = ?", tpl.ID).Count(&parts)
+	if parts != 0 {
+		t.Errorf("%d moderators of the deleted template were kept", parts)
+	}
+}
+
+func TestSaveRoomTemplateConcurrently(t *testing.T) {
+	db := testDB(t)
+	user := newTestUser(t, db, "alice")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			SaveRoomTemplate(db, &model.RoomTemplate{UserID: user.ID, Name: fmt.Sprintf("t%d", i)}, 3)
+		}(i)
+	}
+	wg.Wait()
+
+	var count int64
+	db.Model(&model.RoomTemplate{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 3 {
+		t.Errorf("%d templates saved, want the limit of 3", count)
+	}
+}
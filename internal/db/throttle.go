@@ -0,0 +1,192 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loginPolicy controls when failed logins start locking a subject out. Once
+// a subject reaches its threshold, each further failure locks it for twice
+// as long as the previous one, up to maxLockout.
+type loginPolicy struct {
+	accountThreshold int
+	ipThreshold      int
+	baseLockout      time.Duration
+	maxLockout       time.Duration
+	window           time.Duration
+}
+
+func currentLoginPolicy() loginPolicy {
+	return loginPolicy{
+		accountThreshold: config.Int("LOGIN_ACCOUNT_THRESHOLD", 5),
+		ipThreshold:      config.Int("LOGIN_IP_THRESHOLD", 20),
+		baseLockout:      config.Duration("LOGIN_BASE_LOCKOUT", time.Second),
+		maxLockout:       config.Duration("LOGIN_MAX_LOCKOUT", 15*time.Minute),
+		window:           config.Duration("LOGIN_FAILURE_WINDOW", time.Hour),
+	}
+}
+
+func (p loginPolicy) lockout(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	d := p.baseLockout
+	for i := threshold; i < failures && d < p.maxLockout; i++ {
+		d *= 2
+	}
+	if d > p.maxLockout {
+		d = p.maxLockout
+	}
+	return d
+}
+
+// UserSubject and IPSubject build the throttle subjects for an account and
+// a client address.
+func UserSubject(userID uint) string { return fmt.Sprintf("user:%d", userID) }
+func IPSubject(ip string) string     { return "ip:" + ip }
+
+// AccountSubject returns the throttle subject for login, which may be a
+// username or an email address. Both resolve to the same account, so they
+// share one failure count. Unknown logins are counted too, under the login
+// itself, so they cost the same as real ones.
+func AccountSubject(db *gorm.DB, login string) (string, error) {
+	var user model.User
+	err := findByLogin(db, login).Select("id").First(&user).Error
+	if err == gorm.ErrRecordNotFound {
+		return "login:" + strings.ToLower(login), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return UserSubject(user.ID), nil
+}
+
+// LoginLockedFor returns how long logins for login from ip remain locked
+// out, or zero if they may proceed.
+func LoginLockedFor(db *gorm.DB, login, ip string) (time.Duration, error) {
+	account, err := AccountSubject(db, login)
+	if err != nil {
+		return 0, err
+	}
+	var throttles []model.LoginThrottle
+	err = db.Where("subject IN ? AND locked_until > ?", []string{account, IPSubject(ip)}, time.Now()).
+		Find(&throttles).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	for _, t := range throttles {
+		if d := time.Until(t.LockedUntil); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// RecordLoginFailure counts a failed login against both the account and the
+// client address, extending their lockouts as needed.
+func RecordLoginFailure(db *gorm.DB, login, ip string) error {
+	p := currentLoginPolicy()
+	account, err := AccountSubject(db, login)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := recordFailure(tx, p, account, p.accountThreshold); err != nil {
+			return err
+		}
+		return recordFailure(tx, p, IPSubject(ip), p.ipThreshold)
+	})
+}
+
+func recordFailure(tx *gorm.DB, p loginPolicy, subject string, threshold int) error {
+	// Make sure the row exists, then lock it so concurrent failures are all
+	// counted.
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.LoginThrottle{Subject: subject}).Error; err != nil {
+		return err
+	}
+	var t model.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("subject = ?", subject).First(&t).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if now.Sub(t.LastFailureAt) > p.window {
+		t.Failures = 0
+	}
+	t.Failures++
+	t.LastFailureAt = now
+	if d := p.lockout(t.Failures, threshold); d > 0 {
+		t.LockedUntil = now.Add(d)
+	}
+	return tx.Save(&t).Error
+}
+
+// currentMailPolicy controls how many requests for mail a client address may make
+// before it is locked out, with the lockout doubling like a login lockout.
+func currentMailPolicy() loginPolicy {
+	return loginPolicy{
+		ipThreshold: config.Int("MAIL_IP_THRESHOLD", 5),
+		baseLockout: config.Duration("MAIL_IP_LOCKOUT", time.Minute),
+		maxLockout:  config.Duration("MAIL_IP_MAX_LOCKOUT", time.Hour),
+		window:      config.Duration("MAIL_IP_WINDOW", time.Hour),
+	}
+}
+
+// MailSubject builds the throttle subject for mail requested from a client
+// address.
+func MailSubject(ip string) string { return "mail:" + ip }
+
+// ThrottleMail counts a request from ip that sends mail, such as a password
+// reset, and returns how long the address remains locked out, or zero if
+// the request may proceed. Requests made while locked out are not counted.
+func ThrottleMail(db *gorm.DB, ip string) (time.Duration, error) {
+	p := currentMailPolicy()
+	subject := MailSubject(ip)
+	var wait time.Duration
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var t model.LoginThrottle
+		err := tx.Where("subject = ? AND locked_until > ?", subject, time.Now()).First(&t).Error
+		if err == nil {
+			wait = time.Until(t.LockedUntil)
+			return nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+		return recordFailure(tx, p, subject, p.ipThreshold)
+	})
+	return wait, err
+}
+
+// RecordLoginSuccess clears the failure history of userID. The client
+// address keeps its history so one good password doesn't reset a spray.
+func RecordLoginSuccess(db *gorm.DB, userID uint) error {
+	return ClearLoginThrottle(db, UserSubject(userID))
+}
+
+// PruneLoginThrottles removes the throttles whose lockout is over and whose
+// last failure is too old to count any more, and returns how many it
+// removed. Without it, failed logins for names that match no account would
+// leave rows behind for good.
+func PruneLoginThrottles(db *gorm.DB, now time.Time) (int64, error) {
+	window := currentLoginPolicy().window
+	if w := currentMailPolicy().window; w > window {
+		window = w
+	}
+	res := db.Where("locked_until < ? AND last_failure_at < ?", now, now.Add(-window)).
+		Delete(&model.LoginThrottle{})
+	return res.RowsAffected, res.Error
+}
+
+// ClearLoginThrottle removes any failures and lockout recorded for subject.
+func ClearLoginThrottle(db *gorm.DB, subject string) error {
+	return db.Where("subject = ?", subject).Delete(&model.LoginThrottle{}).Error
+}
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestLockoutBackoff(t *testing.T) {
+	p := loginPolicy{baseLockout: time.Second, maxLockout: 10 * time.Second}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, time.Second},
+		{6, 2 * time.Second},
+		{7, 4 * time.Second},
+		{8, 8 * time.Second},
+		{9, 10 * time.Second},
+		{1000, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.lockout(tt.failures, 5); got != tt.want {
+			t.Errorf("lockout(%d, 5) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLockoutBaseAboveMax(t *testing.T) {
+	p := loginPolicy{baseLockout: time.Hour, maxLockout: time.Minute}
+	if got := p.lockout(1, 1); got != time.Minute {
+		t.Errorf("lockout = %v, want it capped at %v", got, time.Minute)
+	}
+}
+
+func TestSubjects(t *testing.T) {
+	if got := UserSubject(42); got != "user:42" {
+		t.Errorf("UserSubject = %q", got)
+	}
+	if got := IPSubject("10.0.0.1"); got != "ip:10.0.0.1" {
+		t.Errorf("IPSubject = %q", got)
+	}
+}
+
+func TestAccountSubjectSharesCounter(t *testing.T) {
+	db := testDB(t)
+	user := newTestUser(t, db, "alice")
+
+	for _, login := range []string{"alice", "ALICE", "alice@example.com", "Alice@Example.com"} {
+		subject, err := AccountSubject(db, login)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if subject != UserSubject(user.ID) {
+			t.Errorf("AccountSubject(%q) = %q, want %q", login, subject, UserSubject(user.ID))
+		}
+	}
+
+	subject, err := AccountSubject(db, "Nobody")
+	if err != nil || subject != "login:nobody" {
+		t.Errorf("AccountSubject of an unknown login = %q, %v", subject, err)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	t.Setenv("LOGIN_ACCOUNT_THRESHOLD", "3")
+	t.Setenv("LOGIN_IP_THRESHOLD", "100")
+	t.Setenv("LOGIN_BASE_LOCKOUT", "1m")
+	db := testDB(t)
+	user := newTestUser(t, db, "alice")
+
+	// Failures under the username and the email address add up.
+	for _, login := range []string{"alice", "alice@example.com"} {
+		if err := RecordLoginFailure(db, login, "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait, err := LoginLockedFor(db, "alice", "10.0.0.2"); err != nil || wait != 0 {
+		t.Fatalf("locked after 2 failures: %v, %v", wait, err)
+	}
+	if err := RecordLoginFailure(db, "ALICE", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	wait, err := LoginLockedFor(db, "alice@example.com", "10.0.0.2")
+	if err != nil || wait <= 0 || wait > time.Minute {
+		t.Fatalf("LoginLockedFor after 3 failures = %v, %v; want about a minute", wait, err)
+	}
+
+	if err := RecordLoginSuccess(db, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := LoginLockedFor(db, "alice", "10.0.0.2"); wait != 0 {
+		t.Errorf("still locked after a successful login: %v", wait)
+	}
+}
+
+func TestLoginLockoutPerAddress(t *testing.T) {
+	t.Setenv("LOGIN_ACCOUNT_THRESHOLD", "100")
+	t.Setenv("LOGIN_IP_THRESHOLD", "2")
+	t.Setenv("LOGIN_BASE_LOCKOUT", "1m")
+	db := testDB(t)
+
+	RecordLoginFailure(db, "alice", "10.0.0.1")
+	RecordLoginFailure(db, "bob", "10.0.0.1")
+	if wait, _ := LoginLockedFor(db, "carol", "10.0.0.1"); wait == 0 {
+		t.Error("address spraying accounts was not locked out")
+	}
+	if wait, _ := LoginLockedFor(db, "carol", "10.0.0.2"); wait != 0 {
+		t.Error("another address was locked out")
+	}
+}
+
+func TestThrottleMail(t *testing.T) {
+	t.Setenv("MAIL_IP_THRESHOLD", "3")
+	t.Setenv("MAIL_IP_LOCKOUT", "1m")
+	db := testDB(t)
+
+	for i := 1; i <= 3; i++ {
+		if wait, err := ThrottleMail(db, "10.0.0.1"); err != nil || wait != 0 {
+			t.Fatalf("request %d = %v, %v; want it let through", i, wait, err)
+		}
+	}
+	wait, err := ThrottleMail(db, "10.0.0.1")
+	if err != nil || wait <= 0 || wait > time.Minute {
+		t.Errorf("request past the threshold = %v, %v; want about a minute", wait, err)
+	}
+	if wait, _ := ThrottleMail(db, "10.0.0.2"); wait != 0 {
+		t.Error("another address was throttled")
+	}
+}
+
+func TestPruneLoginThrottles(t *testing.T) {
+	t.Setenv("LOGIN_FAILURE_WINDOW", "1h")
+	t.Setenv("MAIL_IP_WINDOW", "1h")
+	db := testDB(t)
+	now := time.Now()
+
+	for _, th := range []model.LoginThrottle{
+		{Subject: "login:stale", LastFailureAt: now.Add(-2 * time.Hour), LockedUntil: now.Add(-time.Hour)},
+		{Subject: "login:recent", LastFailureAt: now.Add(-time.Minute)},
+		{Subject: "user:1", LastFailureAt: now.Add(-2 * time.Hour), LockedUntil: now.Add(time.Minute)},
+	} {
+		if err := db.Create(&th).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := PruneLoginThrottles(db, now)
+	if err != nil || n != 1 {
+		t.Fatalf("PruneLoginThrottles = %d, %v; want 1", n, err)
+	}
+	var left []string
+	db.Model(&model.LoginThrottle{}).Order("subject").Pluck("subject", &left)
+	if len(left) != 2 || left[0] != "login:recent" || left[1] != "user:1" {
+		t.Errorf("kept %v, want the recent and the locked throttle", left)
+	}
+}
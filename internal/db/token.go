@@ -0,0 +1,134 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// NewToken returns a random URL-safe token and the hash to store for it.
+func NewToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex SHA-256 of token. Tokens carry enough entropy that
+// a fast hash is sufficient for storage.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ErrTokenInvalid is returned when a user token is unknown, expired or
+// already used.
+var ErrTokenInvalid = errors.New("token is invalid or expired")
+
+// CreateUserToken issues a token for purpose that expires after ttl.
+func CreateUserToken(db *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+	token, hash, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = db.Create(&model.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CreateEmailToken issues a token for purpose addressed to email, which
+// may not belong to any user yet.
+func CreateEmailToken(db *gorm.DB, email, purpose string, ttl time.Duration) (string, error) {
+	token, hash, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = db.Create(&model.UserToken{
+		Email:     strings.ToLower(email),
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RecentEmailToken reports whether a token for purpose was sent to email
+// within the last interval.
+func RecentEmailToken(db *gorm.DB, email, purpose string, interval time.Duration) (bool, error) {
+	var count int64
+	err := db.Model(&model.UserToken{}).
+		Where("email = ? AND purpose = ? AND created_at > ?", strings.ToLower(email), purpose, time.Now().Add(-interval)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// RecentUserToken reports whether a token for purpose was issued to userID
+// within the last interval.
+func RecentUserToken(db *gorm.DB, userID uint, purpose string, interval time.Duration) (bool, error) {
+	var count int64
+	err := db.Model(&model.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", userID, purpose, time.Now().Add(-interval)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ConsumeUserToken marks the token as used and returns it. The update is
+// conditional on the token being unused, so it can only succeed once.
+func ConsumeUserToken(db *gorm.DB, token, purpose string) (model.UserToken, error) {
+	var ut model.UserToken
+	err := db.Where("token_hash = ? AND purpose = ?", HashToken(token), purpose).First(&ut).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ut, ErrTokenInvalid
+		}
+		return ut, err
+	}
+
+	now := time.Now()
+	if ut.UsedAt != nil || now.After(ut.ExpiresAt) {
+		return ut, ErrTokenInvalid
+	}
+
+	res := db.Model(&model.UserToken{}).
+		Where("id = ? AND used_at IS NULL", ut.ID).
+		Update("used_at", now)
+	if res.Error != nil {
+		return ut, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ut, ErrTokenInvalid
+	}
+	ut.UsedAt = &now
+	return ut, nil
+}
+
+// InvalidateUserTokens marks all outstanding tokens of userID for purpose as
+// used.
+func InvalidateUserTokens(db *gorm.DB, userID uint, purpose string) error {
+	return db.Model(&model.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+)
+
+func TestNewToken(t *testing.T) {
+	token, hash, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 32 {
+		t.Fatalf("token %q is not 32 URL-safe bytes", token)
+	}
+	if hash != HashToken(token) || hash == token {
+		t.Fatalf("hash %q does not match the token", hash)
+	}
+
+	other, _, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == token {
+		t.Fatal("NewToken repeated a token")
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	// SHA-256 of "abc".
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashToken("abc"); got != want {
+		t.Errorf("HashToken = %s, want %s", got, want)
+	}
+}
+
+func TestConsumeUserToken(t *testing.T) {
+	db := testDB(t)
+	user := newTestUser(t, db, "alice")
+
+	token, err := CreateUserToken(db, user.ID, model.TokenPasswordReset, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ConsumeUserToken(db, token, model.TokenVerifyEmail); err != ErrTokenInvalid {
+		t.Fatalf("token used for another purpose: %v", err)
+	}
+	ut, err := ConsumeUserToken(db, token, model.TokenPasswordReset)
+	if err != nil || ut.UserID != user.ID {
+		t.Fatalf("ConsumeUserToken = %+v, %v", ut, err)
+	}
+	if _, err := ConsumeUserToken(db, token, model.TokenPasswordReset); err != ErrTokenInvalid {
+		t.Fatalf("token used twice: %v", err)
+	}
+
+	expired, err := CreateUserToken(db, user.ID, model.TokenPasswordReset, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ConsumeUserToken(db, expired, model.TokenPasswordReset); err != ErrTokenInvalid {
+		t.Fatalf("expired token accepted: %v", err)
+	}
+}
+
+func TestInvalidateUserTokens(t *testing.T) {
+	db := testDB(t)
+	user := newTestUser(t, db, "alice")
+
+	reset, _ := CreateUserToken(db, user.ID, model.TokenPasswordReset, time.Hour)
+	verify, _ := CreateUserToken(db, user.ID, model.TokenVerifyEmail, time.Hour)
+	if err := InvalidateUserTokens(db, user.ID, model.TokenPasswordReset); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ConsumeUserToken(db, reset, model.TokenPasswordReset); err != ErrTokenInvalid {
+		t.Errorf("invalidated token accepted: %v", err)
+	}
+	if _, err := ConsumeUserToken(db, verify, model.TokenVerifyEmail); err != nil {
+		t.Errorf("token for another purpose was invalidated: %v", err)
+	}
+}
+
+func TestRecentUserToken(t *testing.T) {
+	db := testDB(t)
+	user := newTestUser(t, db, "alice")
+
+	recent, err := RecentUserToken(db, user.ID, model.TokenPasswordReset, time.Minute)
+	if err != nil || recent {
+		t.Fatalf("RecentUserToken before any token = %v, %v", recent, err)
+	}
+	CreateUserToken(db, user.ID, model.TokenPasswordReset, time.Hour)
+	if recent, _ := RecentUserToken(db, user.ID, model.TokenPasswordReset, time.Minute); !recent {
+		t.Error("RecentUserToken missed a token issued just now")
+	}
+	if recent, _ := RecentUserToken(db, user.ID, model.TokenVerifyEmail, time.Minute); recent {
+		t.Error("RecentUserToken counted a token for another purpose")
+	}
+}
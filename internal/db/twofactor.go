@@ -0,0 +1,234 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+	"github.com/spacelord16/Videoparty/internal/model"
+	"github.com/spacelord16/Videoparty/internal/totp"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is how many recovery codes each enrolment gets.
+const recoveryCodeCount = 10
+
+// recoveryAlphabet leaves out characters that are easy to misread.
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// recoveryRand is where recovery codes get their randomness.
+var recoveryRand io.Reader = rand.Reader
+
+// totpSealPrefix marks a TOTP secret encrypted by sealTOTPSecret.
+const totpSealPrefix = "v1:"
+
+// totpCipher returns the AES-GCM cipher TOTP secrets are stored with. Its
+// key is derived from TOTP_ENCRYPTION_KEY, or JWT_SECRET if that is unset;
+// changing it invalidates every enrolled authenticator.
+func totpCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("videoparty totp:" + config.String("TOTP_ENCRYPTION_KEY", string(config.JWTSecret()))))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealTOTPSecret encrypts the secret of userID for storage. The user ID is
+// authenticated with it, so a secret copied to another row won't open.
+func sealTOTPSecret(userID uint, secret string) (string, error) {
+	aead, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(secret), []byte(strconv.FormatUint(uint64(userID), 10)))
+	return totpSealPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// openTOTPSecret decrypts a secret stored by sealTOTPSecret.
+func openTOTPSecret(userID uint, stored string) (string, error) {
+	if !strings.HasPrefix(stored, totpSealPrefix) {
+		return "", errors.New("totp secret is not encrypted")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(stored[len(totpSealPrefix):])
+	if err != nil {
+		return "", err
+	}
+	aead, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+	n := aead.NonceSize()
+	if len(sealed) < n {
+		return "", errors.New("totp secret is truncated")
+	}
+	secret, err := aead.Open(nil, sealed[:n], sealed[n:], []byte(strconv.FormatUint(uint64(userID), 10)))
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// encryptTOTPSecrets encrypts the TOTP secrets stored before they were
+// encrypted at rest.
+func encryptTOTPSecrets(db *gorm.DB) error {
+	var users []model.User
+	err := db.Select("id", "totp_secret").
+		Where("totp_secret <> '' AND totp_secret NOT LIKE ?", totpSealPrefix+"%").
+		Find(&users).Error
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		sealed, err := sealTOTPSecret(user.ID, user.TOTPSecret)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&model.User{}).Where("id = ?", user.ID).Update("totp_secret", sealed).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BeginTOTP stores a new pending secret for userID, replacing any earlier
+// unfinished enrolment. It has no effect on login until EnableTOTP.
+func BeginTOTP(db *gorm.DB, userID uint, secret string) error {
+	sealed, err := sealTOTPSecret(userID, secret)
+	if err != nil {
+		return err
+	}
+	return db.Model(&model.User{}).
+		Where("id = ? AND totp_enabled = ?", userID, false).
+		Updates(map[string]interface{}{"totp_secret": sealed, "totp_last_step": 0}).Error
+}
+
+// VerifyTOTP checks code for user and records its time step, so each code
+// is accepted at most once even under concurrent requests.
+func VerifyTOTP(db *gorm.DB, user *model.User, code string) (bool, error) {
+	if user.TOTPSecret == "" {
+		return false, nil
+	}
+	secret, err := openTOTPSecret(user.ID, user.TOTPSecret)
+	if err != nil {
+		return false, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false, nil
+	}
+	res := db.Model(&model.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	user.TOTPLastStep = step
+	return res.RowsAffected == 1, nil
+}
+
+// EnableTOTP turns on two-factor login for userID and returns a fresh set
+// of recovery codes.
+func EnableTOTP(db *gorm.DB, userID uint) ([]string, error) {
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// RegenerateRecoveryCodes invalidates the old recovery codes of userID and
+// returns new ones.
+func RegenerateRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		if err := tx.Create(&model.RecoveryCode{UserID: userID, CodeHash: HashToken(code)}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// newRecoveryCode returns a random code of ten characters from
+// recoveryAlphabet. Random bytes at or above the largest multiple of the
+// alphabet's length are skipped, so every character is equally likely.
+func newRecoveryCode() (string, error) {
+	limit := 256 - 256%len(recoveryAlphabet)
+	code := make([]byte, 0, 10)
+	buf := make([]byte, 16)
+	for len(code) < cap(code) {
+		if _, err := io.ReadFull(recoveryRand, buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(code) < cap(code) {
+				code = append(code, recoveryAlphabet[int(b)%len(recoveryAlphabet)])
+			}
+		}
+	}
+	return fmt.Sprintf("%s-%s", code[:5], code[5:]), nil
+}
+
+// UseRecoveryCode consumes code if it is an unused recovery code of userID.
+func UseRecoveryCode(db *gorm.DB, userID uint, code string) (bool, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	res := db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashToken(code)).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// DisableTOTP turns off two-factor login for userID and removes its secret
+// and recovery codes.
+func DisableTOTP(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error
+	})
+}
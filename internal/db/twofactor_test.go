@@ -0,0 +1,197 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"github.com/spacelord16/Videoparty/internal/totp"
+)
+
+func TestNewRecoveryCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 11 || code[5] != '-' {
+			t.Fatalf("code %q is not xxxxx-xxxxx", code)
+		}
+		for _, r := range strings.Replace(code, "-", "", 1) {
+			if !strings.ContainsRune(recoveryAlphabet, r) {
+				t.Fatalf("code %q has %q, outside the alphabet", code, r)
+			}
+		}
+		if seen[code] {
+			t.Fatalf("code %q repeated", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestNewRecoveryCodeSkipsBiasedBytes(t *testing.T) {
+	old := recoveryRand
+	t.Cleanup(func() { recoveryRand = old })
+
+	// 248 and up would favour the first eight characters of the alphabet.
+	b := []byte{255, 248, 0, 30, 31, 61, 247, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	recoveryRand = bytes.NewReader(b)
+	code, err := newRecoveryCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "a9a99-bcdef" {
+		t.Errorf("code = %q, want a9a99-bcdef", code)
+	}
+}
+
+func TestTOTPSecretSealing(t *testing.T) {
+	t.Setenv("TOTP_ENCRYPTION_KEY", "test key")
+	secret, _ := totp.GenerateSecret()
+	sealed, err := sealTOTPSecret(1, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, secret) {
+		t.Fatalf("sealed secret %q holds the plaintext", sealed)
+	}
+	if got, err := openTOTPSecret(1, sealed); err != nil || got != secret {
+		t.Fatalf("openTOTPSecret = %q, %v", got, err)
+	}
+	if _, err := openTOTPSecret(2, sealed); err == nil {
+		t.Error("another user's secret opened")
+	}
+	if _, err := openTOTPSecret(1, secret); err == nil {
+		t.Error("a plaintext secret was accepted")
+	}
+	t.Setenv("TOTP_ENCRYPTION_KEY", "other key")
+	if _, err := openTOTPSecret(1, sealed); err == nil {
+		t.Error("the secret opened with another key")
+	}
+}
+
+func TestEncryptTOTPSecrets(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	secret, _ := totp.GenerateSecret()
+	db.Model(&alice).Update("totp_secret", secret)
+
+	if err := encryptTOTPSecrets(db); err != nil {
+		t.Fatal(err)
+	}
+	var user model.User
+	db.First(&user, alice.ID)
+	if got, err := openTOTPSecret(alice.ID, user.TOTPSecret); err != nil || got != secret {
+		t.Fatalf("stored secret %q opens to %q, %v", user.TOTPSecret, got, err)
+	}
+
+	// Already encrypted secrets are left alone.
+	if err := encryptTOTPSecrets(db); err != nil {
+		t.Fatal(err)
+	}
+	var again model.User
+	db.First(&again, alice.ID)
+	if again.TOTPSecret != user.TOTPSecret {
+		t.Error("an encrypted secret was encrypted again")
+	}
+}
+
+func TestVerifyTOTPAcceptsEachStepOnce(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	secret, _ := totp.GenerateSecret()
+	if err := BeginTOTP(db, alice.ID, secret); err != nil {
+		t.Fatal(err)
+	}
+	code, _ := totp.Code(secret, totp.Step(time.Now()))
+
+	// Two requests that loaded the user before either code was used.
+	var first, second model.User
+	db.First(&first, alice.ID)
+	db.First(&second, alice.ID)
+
+	if ok, err := VerifyTOTP(db, &first, code); err != nil || !ok {
+		t.Fatalf("first use = %v, %v", ok, err)
+	}
+	if ok, _ := VerifyTOTP(db, &second, code); ok {
+		t.Error("a code was accepted twice by concurrent requests")
+	}
+	if ok, _ := VerifyTOTP(db, &first, code); ok {
+		t.Error("a code was accepted twice")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+	secret, _ := totp.GenerateSecret()
+	BeginTOTP(db, alice.ID, secret)
+
+	codes, err := EnableTOTP(db, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	if ok, _ := UseRecoveryCode(db, bob.ID, codes[0]); ok {
+		t.Error("bob used alice's recovery code")
+	}
+	if ok, err := UseRecoveryCode(db, alice.ID, " "+strings.ToUpper(codes[0])+" "); err != nil || !ok {
+		t.Fatalf("UseRecoveryCode = %v, %v", ok, err)
+	}
+	if ok, _ := UseRecoveryCode(db, alice.ID, codes[0]); ok {
+		t.Error("a recovery code was used twice")
+	}
+
+	fresh, err := RegenerateRecoveryCodes(db, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := UseRecoveryCode(db, alice.ID, codes[1]); ok {
+		t.Error("an old recovery code still works after regenerating")
+	}
+	if ok, _ := UseRecoveryCode(db, alice.ID, fresh[0]); !ok {
+		t.Error("a new recovery code was rejected")
+	}
+}
+
+func TestEnableAndDisableTOTP(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+	secret, _ := totp.GenerateSecret()
+	BeginTOTP(db, alice.ID, secret)
+	if _, err := EnableTOTP(db, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Starting over must not replace the secret of an enabled account.
+	other, _ := totp.GenerateSecret()
+	if err := BeginTOTP(db, alice.ID, other); err != nil {
+		t.Fatal(err)
+	}
+	var user model.User
+	db.First(&user, alice.ID)
+	if stored, _ := openTOTPSecret(alice.ID, user.TOTPSecret); !user.TOTPEnabled || stored != secret {
+		t.Fatalf("user = enabled %v, secret %q; want the original secret", user.TOTPEnabled, stored)
+	}
+
+	if err := DisableTOTP(db, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	user = model.User{}
+	db.First(&user, alice.ID)
+	if user.TOTPEnabled || user.TOTPSecret != "" || user.TOTPLastStep != 0 {
+		t.Errorf("user = %+v, want 2FA cleared", user)
+	}
+	var count int64
+	db.Model(&model.RecoveryCode{}).Where("user_id = ?", alice.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("%d recovery codes kept", count)
+	}
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spacelord16/Videoparty/internal/model"
+	"github.com/spacelord16/Videoparty/internal/password"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticateUserUpgradesHash(t *testing.T) {
+	db := testDB(t)
+	old := password.Default
+	password.Default = &password.Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	t.Cleanup(func() { password.Default = old })
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := model.User{Username: "alice", Email: "alice@example.com", Password: string(legacy)}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AuthenticateUser(db, "alice", "wrong horse"); err != ErrInvalidCredentials {
+		t.Fatalf("wrong password: %v", err)
+	}
+	var stored model.User
+	db.First(&stored, user.ID)
+	if stored.Password != string(legacy) {
+		t.Fatal("a failed login replaced the hash")
+	}
+
+	if _, err := AuthenticateUser(db, "Alice@Example.com", "correct horse"); err != nil {
+		t.Fatalf("login by email: %v", err)
+	}
+	db.First(&stored, user.ID)
+	if !strings.HasPrefix(stored.Password, "$argon2id$") {
+		t.Errorf("hash was not upgraded: %s", stored.Password)
+	}
+	if _, err := AuthenticateUser(db, "alice", "correct horse"); err != nil {
+		t.Errorf("login after the upgrade: %v", err)
+	}
+}
+
+func TestAuthenticateUserWithoutPassword(t *testing.T) {
+	db := testDB(t)
+	newTestUser(t, db, "alice")
+
+	for _, login := range []string{"alice", "nobody"} {
+		if _, err := AuthenticateUser(db, login, ""); err != ErrInvalidCredentials {
+			t.Errorf("AuthenticateUser(%q) = %v, want ErrInvalidCredentials", login, err)
+		}
+	}
+}
+
+func TestCheckUserAvailable(t *testing.T) {
+	db := testDB(t)
+	alice := newTestUser(t, db, "alice")
+
+	tests := []struct {
+		exceptID        uint
+		username, email string
+		want            error
+	}{
+		{0, "ALICE", "", ErrUsernameTaken},
+		{0, "", "Alice@Example.com", ErrEmailTaken},
+		{0, "bob", "bob@example.com", nil},
+		{alice.ID, "Alice", "alice@example.com", nil},
+	}
+	for _, tt := range tests {
+		if got := CheckUserAvailable(db, tt.exceptID, tt.username, tt.email); got != tt.want {
+			t.Errorf("CheckUserAvailable(%d, %q, %q) = %v, want %v", tt.exceptID, tt.username, tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestCreateUserRejectsDuplicates(t *testing.T) {
+	db := testDB(t)
+	old := password.Default
+	password.Default = &password.Bcrypt{Cost: bcrypt.MinCost}
+	t.Cleanup(func() { password.Default = old })
+
+	if err := CreateUser(db, &model.User{Username: "alice", Email: "alice@example.com", Password: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateUser(db, &model.User{Username: "Alice", Email: "other@example.com", Password: "x"}); err != ErrUsernameTaken {
+		t.Errorf("duplicate username: %v", err)
+	}
+	if err := CreateUser(db, &model.User{Username: "bob", Email: "ALICE@example.com", Password: "x"}); err != ErrEmailTaken {
+		t.Errorf("duplicate email: %v", err)
+	}
+
+	// The unique indexes back the checks up if a race slips past them.
+	err := db.Create(&model.User{Username: "ALICE", Email: "third@example.com"}).Error
+	if err == nil {
+		t.Error("the database accepted a username differing only in case")
+	}
+}
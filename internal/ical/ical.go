@@ -0,0 +1,229 @@
+// Package ical writes iCalendar (RFC 5545) files for scheduled watch
+// parties.
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is a calendar event, optionally repeating every week.
+type Event struct {
+	UID         string
+	Start       time.Time // its location is used as the event's timezone
+	Duration    time.Duration
+	Summary     string
+	Description string
+	URL         string
+	Weekly      bool
+	Stamp       time.Time // when the event was last changed
+}
+
+const (
+	utcFormat   = "20060102T150405Z"
+	localFormat = "20060102T150405"
+)
+
+// tzYears is how many years of daylight saving transitions are spelled out
+// in a timezone definition.
+const tzYears = 5
+
+// Calendar returns an iCalendar file publishing ev, with a definition of its
+// timezone unless it is UTC.
+func Calendar(ev Event) []byte {
+	w := &writer{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//Videoparty//Watch Party//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+
+	loc := ev.Start.Location()
+	utc := loc == time.UTC || loc.String() == "UTC"
+	if !utc {
+		writeTimezone(w, loc, ev.Start)
+	}
+
+	w.line("BEGIN:VEVENT")
+	w.line("UID:" + escape(ev.UID))
+	w.line("DTSTAMP:" + ev.Stamp.UTC().Format(utcFormat))
+	if utc {
+		w.line("DTSTART:" + ev.Start.UTC().Format(utcFormat))
+	} else {
+		w.line("DTSTART;TZID=" + loc.String() + ":" + ev.Start.Format(localFormat))
+	}
+	w.line("DURATION:" + duration(ev.Duration))
+	if ev.Weekly {
+		w.line("RRULE:FREQ=WEEKLY")
+	}
+	w.line("SUMMARY:" + escape(ev.Summary))
+	if ev.Description != "" {
+		w.line("DESCRIPTION:" + escape(ev.Description))
+	}
+	if ev.URL != "" {
+		w.line("URL:" + ev.URL)
+	}
+	w.line("END:VEVENT")
+	w.line("END:VCALENDAR")
+	return []byte(w.b.String())
+}
+
+// transition is a change of UTC offset in a timezone.
+type transition struct {
+	at         time.Time
+	name       string
+	from, to   int // offsets in seconds east of UTC
+	isDaylight bool
+}
+
+// writeTimezone writes a VTIMEZONE for loc covering from a year before start
+// until tzYears after it. Transitions are listed explicitly rather than as
+// rules, which works for any zone in the tz database.
+func writeTimezone(w *writer, loc *time.Location, start time.Time) {
+	begin := start.AddDate(-1, 0, 0)
+	transitions := findTransitions(loc, begin, start.AddDate(tzYears, 0, 0))
+
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + loc.String())
+	if len(transitions) == 0 {
+		name, offset := begin.In(loc).Zone()
+		w.line("BEGIN:STANDARD")
+		w.line("DTSTART:19700101T000000")
+		w.line("TZOFFSETFROM:" + offsetString(offset))
+		w.line("TZOFFSETTO:" + offsetString(offset))
+		w.line("TZNAME:" + escape(name))
+		w.line("END:STANDARD")
+		w.line("END:VTIMEZONE")
+		return
+	}
+
+	// Group transitions that share offsets and name into one component,
+	// with the later ones as RDATEs.
+	type key struct {
+		name       string
+		from, to   int
+		isDaylight bool
+	}
+	groups := make(map[key][]time.Time)
+	var order []key
+	for _, t := range transitions {
+		k := key{t.name, t.from, t.to, t.isDaylight}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		// Onsets are given in the local time before the change.
+		groups[k] = append(groups[k], t.at.Add(time.Duration(t.from)*time.Second).UTC())
+	}
+	sort.SliceStable(order, func(i, j int) bool { return groups[order[i]][0].Before(groups[order[j]][0]) })
+
+	for _, k := range order {
+		component := "STANDARD"
+		if k.isDaylight {
+			component = "DAYLIGHT"
+		}
+		onsets := groups[k]
+		w.line("BEGIN:" + component)
+		w.line("DTSTART:" + onsets[0].Format(localFormat))
+		for _, onset := range onsets[1:] {
+			w.line("RDATE:" + onset.Format(localFormat))
+		}
+		w.line("TZOFFSETFROM:" + offsetString(k.from))
+		w.line("TZOFFSETTO:" + offsetString(k.to))
+		w.line("TZNAME:" + escape(k.name))
+		w.line("END:" + component)
+	}
+	w.line("END:VTIMEZONE")
+}
+
+// findTransitions returns the offset changes of loc between from and to.
+func findTransitions(loc *time.Location, from, to time.Time) []transition {
+	var out []transition
+	_, prev := from.In(loc).Zone()
+	for t := from; t.Before(to); t = t.Add(24 * time.Hour) {
+		next := t.Add(24 * time.Hour)
+		_, offset := next.In(loc).Zone()
+		if offset == prev {
+			continue
+		}
+		// Narrow the change down to the second.
+		lo, hi := t, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, o := mid.In(loc).Zone(); o == prev {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		at := hi.Truncate(time.Second)
+		name, _ := at.In(loc).Zone()
+		out = append(out, transition{
+			at:         at,
+			name:       name,
+			from:       prev,
+			to:         offset,
+			isDaylight: at.In(loc).IsDST(),
+		})
+		prev = offset
+	}
+	return out
+}
+
+func offsetString(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign = '-'
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+// duration formats d as an RFC 5545 duration such as PT1H30M.
+func duration(d time.Duration) string {
+	if d <= 0 {
+		return "PT0S"
+	}
+	s := "PT"
+	if h := int(d / time.Hour); h > 0 {
+		s += fmt.Sprintf("%dH", h)
+	}
+	if m := int(d % time.Hour / time.Minute); m > 0 {
+		s += fmt.Sprintf("%dM", m)
+	}
+	if sec := int(d % time.Minute / time.Second); sec > 0 {
+		s += fmt.Sprintf("%dS", sec)
+	}
+	if s == "PT" {
+		s = "PT0S"
+	}
+	return s
+}
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// writer emits content lines, folding them at 75 octets.
+type writer struct {
+	b strings.Builder
+}
+
+func (w *writer) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		// Don't split a UTF-8 sequence.
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.b.WriteString(s[:cut])
+		w.b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	w.b.WriteString(s)
+	w.b.WriteString("\r\n")
+}
@@ -0,0 +1,241 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// lines unfolds an iCalendar file into its content lines.
+func lines(t *testing.T, data []byte) []string {
+	t.Helper()
+	s := string(data)
+	if !strings.HasSuffix(s, "\r\n") {
+		t.Fatalf("file does not end in CRLF: %q", s)
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(s, "\r\n ", ""), "\r\n"), "\r\n")
+}
+
+// contains reports whether want appears in got as a contiguous run.
+func contains(got, want []string) bool {
+	for i := 0; i+len(want) <= len(got); i++ {
+		match := true
+		for j := range want {
+			if got[i+j] != want[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWriterFolds(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Movie night"},
+		{"exactly 75", "X:" + strings.Repeat("a", 73)},
+		{"ascii", "DESCRIPTION:" + strings.Repeat("0123456789", 20)},
+		{"multibyte", "SUMMARY:" + strings.Repeat("é🎬", 40)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &writer{}
+			w.line(tt.line)
+			out := w.b.String()
+
+			physical := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			for i, l := range physical {
+				if len(l) > 75 {
+					t.Errorf("line %d is %d octets", i, len(l))
+				}
+				if i > 0 && !strings.HasPrefix(l, " ") {
+					t.Errorf("continuation line %d does not start with a space", i)
+				}
+				if !utf8.ValidString(l) {
+					t.Errorf("line %d splits a UTF-8 sequence: %q", i, l)
+				}
+			}
+			if len(tt.line) <= 75 && len(physical) != 1 {
+				t.Errorf("a %d octet line was folded", len(tt.line))
+			}
+			if got := strings.ReplaceAll(out, "\r\n ", ""); got != tt.line+"\r\n" {
+				t.Errorf("unfolded = %q", got)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Movie night", "Movie night"},
+		{`C:\films`, `C:\\films`},
+		{"Dune; Part Two", `Dune\; Part Two`},
+		{"popcorn, drinks", `popcorn\, drinks`},
+		{"one\ntwo\r\nthree\rfour", `one\ntwo\nthree\nfour`},
+		{`\;`, `\\\;`},
+	}
+	for _, tt := range tests {
+		if got := escape(tt.in); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{-time.Hour, "PT0S"},
+		{time.Millisecond, "PT0S"},
+		{90 * time.Minute, "PT1H30M"},
+		{2 * time.Hour, "PT2H"},
+		{time.Hour + 5*time.Second, "PT1H5S"},
+	}
+	for _, tt := range tests {
+		if got := duration(tt.in); got != tt.want {
+			t.Errorf("duration(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestOffsetString(t *testing.T) {
+	for seconds, want := range map[int]string{0: "+0000", 3600: "+0100", 19800: "+0530", -12600: "-0330", -18000: "-0500"} {
+		if got := offsetString(seconds); got != want {
+			t.Errorf("offsetString(%d) = %q, want %q", seconds, got, want)
+		}
+	}
+}
+
+func TestCalendarUTC(t *testing.T) {
+	ev := Event{
+		UID:         "room-1@example.com",
+		Start:       time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC),
+		Duration:    90 * time.Minute,
+		Summary:     "Dune, again",
+		Description: "Join the watch party: https://example.com/room/ABC",
+		URL:         "https://example.com/room/ABC",
+		Weekly:      true,
+		Stamp:       time.Date(2026, 2, 1, 12, 0, 0, 0, time.FixedZone("X", 3600)),
+	}
+	got := lines(t, Calendar(ev))
+
+	want := []string{
+		"BEGIN:VEVENT",
+		"UID:room-1@example.com",
+		"DTSTAMP:20260201T110000Z",
+		"DTSTART:20260301T200000Z",
+		"DURATION:PT1H30M",
+		"RRULE:FREQ=WEEKLY",
+		`SUMMARY:Dune\, again`,
+		"DESCRIPTION:Join the watch party: https://example.com/room/ABC",
+		"URL:https://example.com/room/ABC",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+	if got[0] != "BEGIN:VCALENDAR" || !contains(got, want) {
+		t.Errorf("calendar = %q", got)
+	}
+	for _, l := range got {
+		if strings.HasPrefix(l, "BEGIN:VTIMEZONE") {
+			t.Error("a UTC event has a timezone definition")
+		}
+	}
+
+	ev.Weekly = false
+	ev.Description = ""
+	for _, l := range lines(t, Calendar(ev)) {
+		if strings.HasPrefix(l, "RRULE:") || strings.HasPrefix(l, "DESCRIPTION:") {
+			t.Errorf("unexpected %q", l)
+		}
+	}
+}
+
+func TestCalendarTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	ev := Event{
+		UID:      "room-1@example.com",
+		Start:    time.Date(2026, 3, 1, 20, 0, 0, 0, loc),
+		Duration: 2 * time.Hour,
+		Summary:  "Movie night",
+		Weekly:   true,
+		Stamp:    time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC),
+	}
+	got := lines(t, Calendar(ev))
+
+	if !contains(got, []string{"DTSTART;TZID=America/New_York:20260301T200000"}) {
+		t.Errorf("DTSTART is not in local time: %q", got)
+	}
+	// Transitions from a year before the start to five years after, each
+	// given in the local time before the change.
+	daylight := []string{
+		"BEGIN:DAYLIGHT",
+		"DTSTART:20250309T020000",
+		"RDATE:20260308T020000",
+		"RDATE:20270314T020000",
+		"RDATE:20280312T020000",
+		"RDATE:20290311T020000",
+		"RDATE:20300310T020000",
+		"TZOFFSETFROM:-0500",
+		"TZOFFSETTO:-0400",
+		"TZNAME:EDT",
+		"END:DAYLIGHT",
+	}
+	standard := []string{
+		"BEGIN:STANDARD",
+		"DTSTART:20251102T020000",
+		"RDATE:20261101T020000",
+		"RDATE:20271107T020000",
+		"RDATE:20281105T020000",
+		"RDATE:20291104T020000",
+		"RDATE:20301103T020000",
+		"TZOFFSETFROM:-0400",
+		"TZOFFSETTO:-0500",
+		"TZNAME:EST",
+		"END:STANDARD",
+	}
+	timezone := append(append([]string{"BEGIN:VTIMEZONE", "TZID:America/New_York"}, daylight...), standard...)
+	timezone = append(timezone, "END:VTIMEZONE", "BEGIN:VEVENT")
+	if !contains(got, timezone) {
+		t.Errorf("VTIMEZONE = %q", got)
+	}
+}
+
+func TestCalendarFixedTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	got := lines(t, Calendar(Event{
+		UID:   "room-2@example.com",
+		Start: time.Date(2026, 6, 1, 21, 30, 0, 0, loc),
+		Stamp: time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC),
+	}))
+	want := []string{
+		"BEGIN:VTIMEZONE",
+		"TZID:Asia/Kolkata",
+		"BEGIN:STANDARD",
+		"DTSTART:19700101T000000",
+		"TZOFFSETFROM:+0530",
+		"TZOFFSETTO:+0530",
+		"TZNAME:IST",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+	}
+	if !contains(got, want) || !contains(got, []string{"DTSTART;TZID=Asia/Kolkata:20260601T213000"}) {
+		t.Errorf("calendar = %q", got)
+	}
+}
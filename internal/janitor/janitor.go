@@ -0,0 +1,126 @@
+// Package janitor periodically closes idle rooms and cleans up after them.
+// Every instance of the server runs it; a Postgres advisory lock ensures
+// only one of them sweeps at a time.
+package janitor
+
+import (
+	"log"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+	"github.com/spacelord16/Videoparty/internal/db"
+	"github.com/spacelord16/Videoparty/internal/model"
+	"github.com/spacelord16/Videoparty/internal/realtime"
+	"gorm.io/gorm"
+)
+
+// lockKey identifies the janitor's advisory lock. It is arbitrary but must
+// not collide with other advisory locks taken on the same database.
+const lockKey int64 = 0x76706a616e69746f // "vpjanito"
+
+// Policy holds the janitor's thresholds. A zero duration disables that step.
+type Policy struct {
+	Interval     time.Duration // time between sweeps
+	RoomIdleTTL  time.Duration // close rooms idle this long
+	ViewerTTL    time.Duration // prune viewers absent this long
+	ArchiveAfter time.Duration // archive rooms closed this long
+}
+
+// PolicyFromEnv reads the policy from JANITOR_INTERVAL, ROOM_IDLE_TTL,
+// PARTICIPANT_IDLE_TTL and ROOM_ARCHIVE_AFTER.
+func PolicyFromEnv() Policy {
+	return Policy{
+		Interval:     config.Duration("JANITOR_INTERVAL", 5*time.Minute),
+		RoomIdleTTL:  config.Duration("ROOM_IDLE_TTL", 24*time.Hour),
+		ViewerTTL:    config.Duration("PARTICIPANT_IDLE_TTL", 7*24*time.Hour),
+		ArchiveAfter: config.Duration("ROOM_ARCHIVE_AFTER", 30*24*time.Hour),
+	}
+}
+
+// Start sweeps database every policy.Interval in the background. A zero
+// interval disables the janitor.
+func Start(database *gorm.DB, policy Policy) {
+	if policy.Interval <= 0 {
+		log.Println("Room janitor disabled")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+		for {
+			if err := Sweep(database, policy); err != nil {
+				log.Printf("Room janitor failed: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// Sweep runs one cleanup pass, unless another instance is already running
+// one. Clients still connected to rooms it closes, and lobby users whose
+// requests expire, are disconnected.
+func Sweep(database *gorm.DB, policy Policy) error {
+	var closed []uint
+	var expired []model.JoinRequest
+	err := database.Transaction(func(tx *gorm.DB) error {
+		locked, err := db.TryAdvisoryLock(tx, lockKey)
+		if err != nil || !locked {
+			return err
+		}
+
+		now := time.Now()
+		if policy.RoomIdleTTL > 0 {
+			ids, err := db.CloseIdleRooms(tx, now.Add(-policy.RoomIdleTTL))
+			if err != nil {
+				return err
+			}
+			if len(ids) > 0 {
+				log.Printf("Room janitor closed %d idle rooms", len(ids))
+			}
+			closed = ids
+		}
+		if policy.ViewerTTL > 0 {
+			n, err := db.PruneParticipants(tx, now.Add(-policy.ViewerTTL))
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("Room janitor pruned %d stale participants", n)
+			}
+		}
+		n, err := db.PruneLoginThrottles(tx, now)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.Printf("Room janitor pruned %d expired login throttles", n)
+		}
+		reqs, err := db.ExpireJoinRequests(tx)
+		if err != nil {
+			return err
+		}
+		expired = reqs
+
+		if policy.ArchiveAfter > 0 {
+			n, err := db.ArchiveClosedRooms(tx, now.Add(-policy.ArchiveAfter))
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("Room janitor archived %d closed rooms", n)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, roomID := range closed {
+		realtime.Disconnect(roomID, 0, realtime.EventClosed, nil)
+	}
+	for _, req := range expired {
+		realtime.Disconnect(req.RoomID, req.UserID, realtime.EventLobbyExpired, req)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package janitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/db"
+	"github.com/spacelord16/Videoparty/internal/dbtest"
+	"github.com/spacelord16/Videoparty/internal/model"
+	"github.com/spacelord16/Videoparty/internal/realtime"
+)
+
+func TestPolicyFromEnv(t *testing.T) {
+	t.Setenv("JANITOR_INTERVAL", "1m")
+	t.Setenv("ROOM_IDLE_TTL", "0")
+	t.Setenv("PARTICIPANT_IDLE_TTL", "")
+	t.Setenv("ROOM_ARCHIVE_AFTER", "bogus")
+
+	want := Policy{
+		Interval:     time.Minute,
+		RoomIdleTTL:  0,
+		ViewerTTL:    7 * 24 * time.Hour,
+		ArchiveAfter: 30 * 24 * time.Hour,
+	}
+	if got := PolicyFromEnv(); got != want {
+		t.Errorf("PolicyFromEnv = %+v, want %+v", got, want)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	conn := dbtest.Open(t, db.Migrate)
+	host := model.User{Username: "alice", Email: "alice@example.com"}
+	if err := conn.Create(&host).Error; err != nil {
+		t.Fatal(err)
+	}
+	room := model.Room{Name: "Movie night", HostID: host.ID, Visibility: model.VisibilityPublic, JoinPolicy: model.JoinOpen}
+	if err := db.CreateRoom(conn, &room); err != nil {
+		t.Fatal(err)
+	}
+	conn.Model(&room).UpdateColumn("last_activity_at", time.Now().Add(-2*time.Hour))
+
+	sub := realtime.Subscribe(room.ID, host.ID, false)
+	defer realtime.Unsubscribe(sub)
+
+	policy := Policy{Interval: time.Minute, RoomIdleTTL: time.Hour}
+	if err := Sweep(conn, policy); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.First(&room, room.ID)
+	if room.ClosedAt == nil {
+		t.Fatal("the idle room was not closed")
+	}
+	select {
+	case ev := <-sub.Events:
+		if ev.Type != realtime.EventClosed || !ev.Close {
+			t.Errorf("event = %+v, want a closing %s", ev, realtime.EventClosed)
+		}
+	case <-time.After(time.Second):
+		t.Error("connected clients were not told the room closed")
+	}
+}
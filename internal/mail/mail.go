@@ -0,0 +1,134 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Sender is the mailer used by the API, configured by InitMailer.
+var Sender Mailer = &MemoryMailer{}
+
+// InitMailer configures Sender from the environment. MAIL_DRIVER selects
+// "smtp", "file" (the default, writing to MAIL_DIR) or "memory".
+func InitMailer() error {
+	from := config.String("MAIL_FROM", "no-reply@videoparty.local")
+	switch driver := config.String("MAIL_DRIVER", "file"); driver {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return fmt.Errorf("SMTP_HOST is required for the smtp mail driver")
+		}
+		Sender = &SMTPMailer{
+			Host:     host,
+			Port:     config.String("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	case "file":
+		Sender = &FileMailer{Dir: config.String("MAIL_DIR", "mail"), From: from}
+	case "memory":
+		Sender = &MemoryMailer{}
+	default:
+		return fmt.Errorf("unknown mail driver %q", driver)
+	}
+	return nil
+}
+
+// SMTPMailer sends messages through an SMTP server using PLAIN auth.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := m.Host + ":" + m.Port
+	return smtp.SendMail(addr, auth, m.From, []string{msg.To}, format(m.From, msg))
+}
+
+// FileMailer writes each message to its own file in Dir, for local
+// development without a mail server.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), sanitize(msg.To))
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, format(m.From, msg), 0o644); err != nil {
+		return err
+	}
+	log.Printf("Mail to %s written to %s", msg.To, path)
+	return nil
+}
+
+// MemoryMailer keeps messages in memory, for tests.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Body)
+	return []byte(b.String())
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
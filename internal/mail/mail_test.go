@@ -0,0 +1,106 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	for _, name := range []string{"verify_email", "magic_link", "password_reset"} {
+		msg, err := Render(name, "alice@example.com", map[string]interface{}{
+			"Username":  "alice",
+			"Link":      "http://localhost:3000/x?token=abc",
+			"ExpiresIn": "1h0m0s",
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if msg.To != "alice@example.com" || msg.Subject == "" {
+			t.Errorf("%s: message = %+v", name, msg)
+		}
+		if !strings.Contains(msg.Body, "http://localhost:3000/x?token=abc") || !strings.Contains(msg.Body, "1h0m0s") {
+			t.Errorf("%s: body lacks the link or expiry:\n%s", name, msg.Body)
+		}
+	}
+
+	if _, err := Render("nope", "alice@example.com", nil); err == nil {
+		t.Error("Render accepted an unknown template")
+	}
+}
+
+func TestRenderDoesNotEscapeLinks(t *testing.T) {
+	// Messages are plain text, so links must come through unescaped.
+	msg, err := Render("magic_link", "a@example.com", map[string]interface{}{"Link": "http://x/?a=1&token=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(msg.Body, "http://x/?a=1&token=b") {
+		t.Errorf("link was escaped:\n%s", msg.Body)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{Dir: filepath.Join(dir, "mail"), From: "party@example.com"}
+	if err := m.Send(Message{To: "Alice <alice@example.com>", Subject: "Hi", Body: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(m.Dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("mail dir has %d files, %v", len(files), err)
+	}
+	if name := files[0].Name(); strings.ContainsAny(name, "<> @") || !strings.HasSuffix(name, ".eml") {
+		t.Errorf("file name %q is not sanitized", name)
+	}
+	data, err := os.ReadFile(filepath.Join(m.Dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"From: party@example.com\r\n", "To: Alice <alice@example.com>\r\n", "Subject: Hi\r\n", "\r\n\r\nHello"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("message lacks %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := &MemoryMailer{}
+	m.Send(Message{To: "a@example.com"})
+	m.Send(Message{To: "b@example.com"})
+
+	got := m.Messages()
+	if len(got) != 2 || got[0].To != "a@example.com" || got[1].To != "b@example.com" {
+		t.Fatalf("Messages = %+v", got)
+	}
+	got[0].To = "changed"
+	if m.Messages()[0].To != "a@example.com" {
+		t.Error("Messages returned the mailer's own slice")
+	}
+}
+
+func TestInitMailer(t *testing.T) {
+	old := Sender
+	t.Cleanup(func() { Sender = old })
+
+	t.Setenv("MAIL_DRIVER", "memory")
+	if err := InitMailer(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Sender.(*MemoryMailer); !ok {
+		t.Errorf("Sender = %T, want *MemoryMailer", Sender)
+	}
+
+	t.Setenv("MAIL_DRIVER", "smtp")
+	t.Setenv("SMTP_HOST", "")
+	if err := InitMailer(); err == nil {
+		t.Error("smtp driver accepted without SMTP_HOST")
+	}
+
+	t.Setenv("MAIL_DRIVER", "pigeon")
+	if err := InitMailer(); err == nil {
+		t.Error("unknown driver accepted")
+	}
+}
@@ -0,0 +1,74 @@
+package mail
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+type messageTemplate struct {
+	subject string
+	body    *template.Template
+}
+
+var templates = map[string]messageTemplate{
+	"verify_email": {
+		subject: "Verify your Videoparty email",
+		body: template.Must(template.New("verify_email").Parse(`Hi {{.Username}},
+
+Please confirm your email address by opening the link below:
+
+{{.Link}}
+
+This link expires in {{.ExpiresIn}}. If you did not create a Videoparty
+account, you can ignore this message.
+`)),
+	},
+	"magic_link": {
+		subject: "Your Videoparty login link",
+		body: template.Must(template.New("magic_link").Parse(`Hi,
+
+Open the link below to log in to Videoparty:
+
+{{.Link}}
+
+This link expires in {{.ExpiresIn}} and can only be used once. If you did
+not ask to log in, you can ignore this message.
+`)),
+	},
+	"password_reset": {
+		subject: "Reset your Videoparty password",
+		body: template.Must(template.New("password_reset").Parse(`Hi {{.Username}},
+
+Someone asked to reset the password for your Videoparty account. To choose
+a new password, open the link below:
+
+{{.Link}}
+
+This link expires in {{.ExpiresIn}} and can only be used once. If you did
+not ask for a reset, you can ignore this message.
+`)),
+	},
+}
+
+// Render builds the message named name for to, filling the template with data.
+func Render(name, to string, data interface{}) (Message, error) {
+	t, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown mail template %q", name)
+	}
+	var b strings.Builder
+	if err := t.body.Execute(&b, data); err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: t.subject, Body: b.String()}, nil
+}
+
+// SendTemplate renders the named template and sends it with Sender.
+func SendTemplate(name, to string, data interface{}) error {
+	msg, err := Render(name, to, data)
+	if err != nil {
+		return err
+	}
+	return Sender.Send(msg)
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "net/http"
+)
+
+// AdminOnly rejects requests from users that are not administrators. It must
+// run after AuthMiddleware.
+func AdminOnly() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        userID, exists := c.Get("userID")
+        if !exists {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+            c.Abort()
+            return
+        }
+
+        var user model.User
+        if err := db.DB.First(&user, userID).Error; err != nil || !user.IsAdmin {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+            c.Abort()
+            return
+        }
+
+        c.Next()
+    }
+}
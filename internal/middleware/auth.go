@@ -3,10 +3,17 @@ package middleware
 import (
     "github.com/gin-gonic/gin"
     "github.com/golang-jwt/jwt/v5"
+    "github.com/spacelord16/Videoparty/internal/config"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "log"
     "net/http"
     "strings"
 )
 
+// AuthMiddleware accepts either a session JWT or an API token. For API
+// tokens the granted scopes are stored under "tokenScopes"; routes opt in
+// to token access with RequireScope or shut it out with RequireSession.
 func AuthMiddleware() gin.HandlerFunc {
     return func(c *gin.Context) {
         authHeader := c.GetHeader("Authorization")
@@ -26,12 +33,29 @@ func AuthMiddleware() gin.HandlerFunc {
         }
 
         tokenString := parts[1]
+        if strings.HasPrefix(tokenString, model.APITokenPrefix) {
+            apiToken, err := db.AuthenticateAPIToken(db.DB, tokenString)
+            if err != nil {
+                if err != db.ErrAPITokenInvalid {
+                    log.Printf("Error checking API token: %v", err)
+                }
+                c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+                c.Abort()
+                return
+            }
+
+            c.Set("userID", apiToken.UserID)
+            c.Set("tokenScopes", apiToken.ScopeList())
+            c.Next()
+            return
+        }
+
         token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
             // Validate the signing method
             if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
                 return nil, jwt.ErrSignatureInvalid
             }
-            return []byte("your-secret-key"), nil // Use environment variable in production
+            return config.JWTSecret(), nil
         })
 
         if err != nil {
@@ -41,8 +65,22 @@ func AuthMiddleware() gin.HandlerFunc {
         }
 
         if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+            // Every session token names the login it belongs to, which
+            // must not have been revoked.
+            jti, _ := claims["jti"].(string)
+            session, err := db.TouchSession(db.DB, jti)
+            if err != nil {
+                if err != db.ErrSessionInvalid {
+                    log.Printf("Error checking session: %v", err)
+                }
+                c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired or been revoked"})
+                c.Abort()
+                return
+            }
+
             // Set user ID in context
-            c.Set("userID", uint(claims["user_id"].(float64)))
+            c.Set("userID", session.UserID)
+            c.Set("sessionID", session.ID)
             c.Next()
         } else {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
@@ -50,4 +88,37 @@ func AuthMiddleware() gin.HandlerFunc {
             return
         }
     }
-} 
\ No newline at end of file
+}
+
+// RequireScope lets API tokens through only if they were granted scope.
+// Session JWTs always pass.
+func RequireScope(scope string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        scopes, isToken := c.Get("tokenScopes")
+        if !isToken {
+            c.Next()
+            return
+        }
+        for _, s := range scopes.([]string) {
+            if s == scope {
+                c.Next()
+                return
+            }
+        }
+        c.JSON(http.StatusForbidden, gin.H{"error": "API token is missing the " + scope + " scope"})
+        c.Abort()
+    }
+}
+
+// RequireSession rejects API tokens, for account management routes that
+// only an interactively logged-in user may use.
+func RequireSession() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if _, isToken := c.Get("tokenScopes"); isToken {
+            c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with an API token"})
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/spacelord16/Videoparty/internal/db"
+    "github.com/spacelord16/Videoparty/internal/dbtest"
+    "github.com/spacelord16/Videoparty/internal/model"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+// scopeRouter serves /x behind handlers, as a caller holding scopes, or as
+// a session when scopes is nil.
+func scopeRouter(scopes []string, handlers ...gin.HandlerFunc) *gin.Engine {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.Use(func(c *gin.Context) {
+        c.Set("userID", uint(1))
+        if scopes != nil {
+            c.Set("tokenScopes", scopes)
+        }
+    })
+    handlers = append(handlers, func(c *gin.Context) { c.Status(http.StatusNoContent) })
+    r.GET("/x", handlers...)
+    return r
+}
+
+func status(r *gin.Engine, header string) int {
+    req := httptest.NewRequest(http.MethodGet, "/x", nil)
+    if header != "" {
+        req.Header.Set("Authorization", header)
+    }
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    return w.Code
+}
+
+func TestRequireScope(t *testing.T) {
+    tests := []struct {
+        name   string
+        scopes []string
+        want   int
+    }{
+        {"session", nil, http.StatusNoContent},
+        {"token with scope", []string{model.ScopeRoomsRead, model.ScopePlaylistWrite}, http.StatusNoContent},
+        {"token without scope", []string{model.ScopeRoomsRead}, http.StatusForbidden},
+        {"token without scopes", []string{}, http.StatusForbidden},
+    }
+    for _, tt := range tests {
+        r := scopeRouter(tt.scopes, RequireScope(model.ScopePlaylistWrite))
+        if got := status(r, ""); got != tt.want {
+            t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestRequireSession(t *testing.T) {
+    if got := status(scopeRouter(nil, RequireSession()), ""); got != http.StatusNoContent {
+        t.Errorf("session: status %d", got)
+    }
+    if got := status(scopeRouter([]string{model.ScopeRoomsRead}, RequireSession()), ""); got != http.StatusForbidden {
+        t.Errorf("API token: status %d, want 403", got)
+    }
+}
+
+func TestAuthMiddlewareRejectsMalformedHeaders(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.GET("/x", AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+    for _, header := range []string{"", "Bearer", "Basic abc", "Bearer a b", "Bearer not-a-jwt"} {
+        if got := status(r, header); got != http.StatusUnauthorized {
+            t.Errorf("%q: status %d, want 401", header, got)
+        }
+    }
+}
+
+func TestAuthMiddlewareAPIToken(t *testing.T) {
+    conn := dbtest.Open(t, db.Migrate)
+    old := db.DB
+    db.DB = conn
+    t.Cleanup(func() { db.DB = old })
+
+    user := model.User{Username: "alice", Email: "alice@example.com"}
+    if err := conn.Create(&user).Error; err != nil {
+        t.Fatal(err)
+    }
+    valid, err := db.CreateAPIToken(conn, &model.APIToken{UserID: user.ID, Name: "ci", Scopes: model.ScopeRoomsRead})
+    if err != nil {
+        t.Fatal(err)
+    }
+    past := time.Now().Add(-time.Minute)
+    expired, err := db.CreateAPIToken(conn, &model.APIToken{UserID: user.ID, Name: "old", Scopes: model.ScopeRoomsRead, ExpiresAt: &past})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.GET("/x", AuthMiddleware(), RequireScope(model.ScopeRoomsRead), func(c *gin.Context) {
+        if c.GetUint("userID") != user.ID {
+            t.Errorf("userID = %d, want %d", c.GetUint("userID"), user.ID)
+        }
+        c.Status(http.StatusNoContent)
+    })
+
+    if got := status(r, "Bearer "+valid); got != http.StatusNoContent {
+        t.Errorf("valid token: status %d", got)
+    }
+    if got := status(r, "Bearer "+expired); got != http.StatusUnauthorized {
+        t.Errorf("expired token: status %d, want 401", got)
+    }
+    if got := status(r, "Bearer "+model.APITokenPrefix+"unknown"); got != http.StatusUnauthorized {
+        t.Errorf("unknown token: status %d, want 401", got)
+    }
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// API token scopes.
+const (
+	ScopeRoomsRead     = "rooms:read"
+	ScopeRoomsWrite    = "rooms:write"
+	ScopeRoomsControl  = "rooms:control"
+	ScopePlaylistWrite = "playlist:write"
+)
+
+// APITokenPrefix marks bearer tokens that are API tokens rather than JWTs.
+const APITokenPrefix = "vpt_"
+
+// ValidScope reports whether s is a known API token scope.
+func ValidScope(s string) bool {
+	switch s {
+	case ScopeRoomsRead, ScopeRoomsWrite, ScopeRoomsControl, ScopePlaylistWrite:
+		return true
+	}
+	return false
+}
+
+// APIToken is a named, scoped token a user creates for scripts and bots.
+// Only a hash of the token is stored; Prefix keeps enough of it for the
+// user to recognise it.
+type APIToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex"`
+	Scopes     string     `json:"scopes"` // space separated
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ScopeList returns the token's scopes.
+func (t APIToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
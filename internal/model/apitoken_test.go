@@ -0,0 +1,23 @@
+package model
+
+import "testing"
+
+func TestValidScope(t *testing.T) {
+	for _, s := range []string{ScopeRoomsRead, ScopeRoomsWrite, ScopeRoomsControl, ScopePlaylistWrite} {
+		if !ValidScope(s) {
+			t.Errorf("ValidScope(%q) = false", s)
+		}
+	}
+	for _, s := range []string{"", "admin", "rooms:*", "ROOMS:READ"} {
+		if ValidScope(s) {
+			t.Errorf("ValidScope(%q) = true", s)
+		}
+	}
+}
+
+func TestScopeList(t *testing.T) {
+	got := APIToken{Scopes: "rooms:read  playlist:write"}.ScopeList()
+	if len(got) != 2 || got[0] != ScopeRoomsRead || got[1] != ScopePlaylistWrite {
+		t.Errorf("ScopeList = %q", got)
+	}
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// RoomBan keeps a user out of a room until ExpiresAt, or for good if it is
+// nil.
+type RoomBan struct {
+    ID        uint       `json:"id" gorm:"primaryKey"`
+    RoomID    uint       `json:"room_id" gorm:"uniqueIndex:idx_room_bans_room_user"`
+    UserID    uint       `json:"user_id" gorm:"uniqueIndex:idx_room_bans_room_user"`
+    User      User       `json:"-" gorm:"foreignKey:UserID"`
+    Reason    string     `json:"reason"`
+    ExpiresAt *time.Time `json:"expires_at"`
+    CreatedBy uint       `json:"created_by"`
+    CreatedAt time.Time  `json:"created_at"`
+}
+
+// Active reports whether the ban is in force at now.
+func (b RoomBan) Active(now time.Time) bool {
+    return b.ExpiresAt == nil || now.Before(*b.ExpiresAt)
+}
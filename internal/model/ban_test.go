@@ -0,0 +1,28 @@
+package model
+
+import (
+    "testing"
+    "time"
+)
+
+func TestRoomBanActive(t *testing.T) {
+    now := time.Now()
+    later := now.Add(time.Minute)
+    earlier := now.Add(-time.Minute)
+
+    tests := []struct {
+        name string
+        ban  RoomBan
+        want bool
+    }{
+        {"permanent", RoomBan{}, true},
+        {"until later", RoomBan{ExpiresAt: &later}, true},
+        {"expired", RoomBan{ExpiresAt: &earlier}, false},
+        {"expiring now", RoomBan{ExpiresAt: &now}, false},
+    }
+    for _, tt := range tests {
+        if got := tt.ban.Active(now); got != tt.want {
+            t.Errorf("%s: Active = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
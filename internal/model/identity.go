@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider.
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	Issuer    string    `json:"issuer" gorm:"uniqueIndex:idx_identity_issuer_subject"`
+	Subject   string    `json:"subject" gorm:"uniqueIndex:idx_identity_issuer_subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
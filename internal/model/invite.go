@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// RoomInvite lets holders of its token join a room regardless of its
+// password or visibility. Only a hash of the token is stored.
+type RoomInvite struct {
+    ID        uint       `json:"id" gorm:"primaryKey"`
+    RoomID    uint       `json:"room_id" gorm:"index"`
+    CreatedBy uint       `json:"created_by"`
+    TokenHash string     `json:"-" gorm:"uniqueIndex"`
+    Role      string     `json:"role"`
+    MaxUses   int        `json:"max_uses"`
+    Uses      int        `json:"uses"`
+    ExpiresAt time.Time  `json:"expires_at"`
+    RevokedAt *time.Time `json:"revoked_at"`
+    CreatedAt time.Time  `json:"created_at"`
+}
+
+// Usable reports whether the invite can still be redeemed at now.
+func (i RoomInvite) Usable(now time.Time) bool {
+    if i.RevokedAt != nil || now.After(i.ExpiresAt) {
+        return false
+    }
+    return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+    "testing"
+    "time"
+)
+
+func TestRoomInviteUsable(t *testing.T) {
+    now := time.Now()
+    revoked := now.Add(-time.Minute)
+
+    tests := []struct {
+        name   string
+        invite RoomInvite
+        want   bool
+    }{
+        {"fresh", RoomInvite{ExpiresAt: now.Add(time.Hour)}, true},
+        {"uses left", RoomInvite{ExpiresAt: now.Add(time.Hour), MaxUses: 2, Uses: 1}, true},
+        {"used up", RoomInvite{ExpiresAt: now.Add(time.Hour), MaxUses: 2, Uses: 2}, false},
+        {"unlimited", RoomInvite{ExpiresAt: now.Add(time.Hour), Uses: 1000}, true},
+        {"expired", RoomInvite{ExpiresAt: now.Add(-time.Second)}, false},
+        {"revoked", RoomInvite{ExpiresAt: now.Add(time.Hour), RevokedAt: &revoked}, false},
+    }
+    for _, tt := range tests {
+        if got := tt.invite.Usable(now); got != tt.want {
+            t.Errorf("%s: Usable = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Join request states. Requests start pending and are decided by the host,
+// or expire if the host doesn't get to them in time.
+const (
+    JoinPending  = "pending"
+    JoinAdmitted = "admitted"
+    JoinDenied   = "denied"
+    JoinExpired  = "expired"
+)
+
+// JoinRequest is a user waiting in the lobby of an approval room.
+type JoinRequest struct {
+    ID        uint       `json:"id" gorm:"primaryKey"`
+    RoomID    uint       `json:"room_id" gorm:"index"`
+    UserID    uint       `json:"user_id" gorm:"index"`
+    User      User       `json:"-" gorm:"foreignKey:UserID"`
+    Status    string     `json:"status" gorm:"index"`
+    ExpiresAt time.Time  `json:"expires_at"`
+    DecidedAt *time.Time `json:"decided_at"`
+    DecidedBy uint       `json:"decided_by"`
+    CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+    "net/url"
+    "strings"
+)
+
+// Video platforms a room's VideoURL can point at. PlatformOther covers any
+// other site; rooms without a video have no platform.
+const (
+    PlatformYouTube = "youtube"
+    PlatformVimeo   = "vimeo"
+    PlatformTwitch  = "twitch"
+    PlatformOther   = "other"
+)
+
+var platformHosts = map[string]string{
+    "youtube.com": PlatformYouTube,
+    "youtu.be":    PlatformYouTube,
+    "vimeo.com":   PlatformVimeo,
+    "twitch.tv":   PlatformTwitch,
+}
+
+// DetectPlatform derives the platform from a video URL's host, including
+// subdomains such as www. or m.
+func DetectPlatform(videoURL string) string {
+    if videoURL == "" {
+        return ""
+    }
+    u, err := url.Parse(videoURL)
+    if err != nil {
+        return PlatformOther
+    }
+    host := strings.ToLower(u.Hostname())
+    for domain, platform := range platformHosts {
+        if host == domain || strings.HasSuffix(host, "."+domain) {
+            return platform
+        }
+    }
+    return PlatformOther
+}
+
+// ValidPlatform reports whether p is a platform DetectPlatform can return.
+func ValidPlatform(p string) bool {
+    switch p {
+    case PlatformYouTube, PlatformVimeo, PlatformTwitch, PlatformOther:
+        return true
+    }
+    return false
+}
@@ -0,0 +1,40 @@
+package model
+
+import "testing"
+
+func TestDetectPlatform(t *testing.T) {
+    tests := []struct {
+        url, want string
+    }{
+        {"", ""},
+        {"https://www.youtube.com/watch?v=dQw4w9WgXcQ", PlatformYouTube},
+        {"https://m.youtube.com/watch?v=dQw4w9WgXcQ", PlatformYouTube},
+        {"https://youtu.be/dQw4w9WgXcQ", PlatformYouTube},
+        {"https://YouTube.com/watch?v=x", PlatformYouTube},
+        {"https://vimeo.com/76979871", PlatformVimeo},
+        {"https://player.vimeo.com/video/76979871", PlatformVimeo},
+        {"https://www.twitch.tv/videos/1", PlatformTwitch},
+        {"https://notyoutube.com/watch", PlatformOther},
+        {"https://youtube.com.evil.example/watch", PlatformOther},
+        {"https://example.com/film.mp4", PlatformOther},
+        {"://bad", PlatformOther},
+    }
+    for _, tt := range tests {
+        if got := DetectPlatform(tt.url); got != tt.want {
+            t.Errorf("DetectPlatform(%q) = %q, want %q", tt.url, got, tt.want)
+        }
+    }
+}
+
+func TestValidPlatform(t *testing.T) {
+    for _, p := range []string{PlatformYouTube, PlatformVimeo, PlatformTwitch, PlatformOther} {
+        if !ValidPlatform(p) {
+            t.Errorf("ValidPlatform(%q) = false", p)
+        }
+    }
+    for _, p := range []string{"", "YouTube", "netflix"} {
+        if ValidPlatform(p) {
+            t.Errorf("ValidPlatform(%q) = true", p)
+        }
+    }
+}
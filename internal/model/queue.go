@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// QueueItem is a video lined up to play in a room. Items play in Position
+// order.
+type QueueItem struct {
+    ID        uint      `json:"id" gorm:"primaryKey"`
+    RoomID    uint      `json:"room_id" gorm:"index"`
+    Position  int       `json:"position"`
+    VideoURL  string    `json:"video_url"`
+    Title     string    `json:"title"`
+    Platform  string    `json:"platform"`
+    AddedBy   uint      `json:"added_by"`
+    CreatedAt time.Time `json:"created_at"`
+}
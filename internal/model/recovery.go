@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when the
+// user has lost their device. Only a hash of the code is stored.
+type RecoveryCode struct {
+	ID       uint       `json:"id" gorm:"primaryKey"`
+	UserID   uint       `json:"user_id" gorm:"index"`
+	CodeHash string     `json:"-"`
+	UsedAt   *time.Time `json:"used_at"`
+}
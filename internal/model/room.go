@@ -1,24 +1,79 @@
 package model
 
-import "time"
+import (
+    "time"
+
+    "gorm.io/gorm"
+)
+
+// Room visibility levels. Public rooms are discoverable, unlisted rooms can
+// be joined by anyone with the code, and private rooms are only visible to
+// their participants.
+const (
+    VisibilityPublic   = "public"
+    VisibilityUnlisted = "unlisted"
+    VisibilityPrivate  = "private"
+)
+
+// Join policies. Open rooms can be joined directly, approval rooms need the
+// host to admit each joiner, and invite rooms can only be joined through an
+// invite.
+const (
+    JoinOpen     = "open"
+    JoinApproval = "approval"
+    JoinInvite   = "invite"
+)
+
+// Participant roles. The host is identified by Room.HostID; moderators may
+// also control playback.
+const (
+    RoleViewer    = "viewer"
+    RoleModerator = "moderator"
+)
 
 type Room struct {
     ID          uint      `json:"id" gorm:"primaryKey"`
     Name        string    `json:"name"`
-    Code        string    `json:"code" gorm:"unique"`
+    Code        string    `json:"code" gorm:"index"`
     HostID      uint      `json:"host_id"`
-    Host        User      `json:"host" gorm:"foreignKey:HostID"`
+    Host        User      `json:"-" gorm:"foreignKey:HostID"`
     VideoURL    string    `json:"video_url"`
+    Visibility  string    `json:"visibility" gorm:"default:public"`
+    PasswordHash string   `json:"-"`
+    JoinPolicy  string    `json:"join_policy" gorm:"default:open"`
+    MaxParticipants int   `json:"max_participants"` // 0 uses ROOM_MAX_PARTICIPANTS
     IsPlaying   bool      `json:"is_playing"`
     CurrentTime float64   `json:"current_time"`
+    Platform    string    `json:"platform" gorm:"index"`
+    LastActivityAt time.Time `json:"last_activity_at" gorm:"index"`
+    ScheduledAt *time.Time `json:"scheduled_at"` // first showing, if scheduled
+    Timezone    string    `json:"timezone"`      // IANA zone the schedule is kept in
+    Recurrence  string    `json:"recurrence"`
+    NextStartAt *time.Time `json:"next_start_at" gorm:"index"` // next automatic start, if any
+    ClosedAt    *time.Time `json:"closed_at" gorm:"index"`
+    ArchivedAt  *time.Time `json:"archived_at" gorm:"index"`
     CreatedAt   time.Time `json:"created_at"`
     UpdatedAt   time.Time `json:"updated_at"`
+    DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"` // soft delete, keeping the room's history
 }
 
 type RoomParticipant struct {
     ID        uint      `json:"id" gorm:"primaryKey"`
-    RoomID    uint      `json:"room_id"`
-    UserID    uint      `json:"user_id"`
-    User      User      `json:"user" gorm:"foreignKey:UserID"`
+    RoomID    uint      `json:"room_id" gorm:"uniqueIndex:idx_room_participants_room_user"`
+    UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_room_participants_room_user"`
+    User      User      `json:"-" gorm:"foreignKey:UserID"`
     JoinedAt  time.Time `json:"joined_at"`
-} 
\ No newline at end of file
+    LastSeenAt time.Time `json:"last_seen_at"`
+    Role      string    `json:"role" gorm:"default:viewer"`
+}
+
+// Closed reports whether the party has ended. Closed rooms stay readable
+// but can no longer be joined or played.
+func (r Room) Closed() bool {
+    return r.ClosedAt != nil
+}
+
+// HasPassword reports whether joining the room requires a password.
+func (r Room) HasPassword() bool {
+    return r.PasswordHash != ""
+}
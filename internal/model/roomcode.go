@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// RoomCodeReservation holds a vanity room code for a premium user, who can
+// then create rooms with it. Codes are stored upper-case.
+type RoomCodeReservation struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	Code      string    `json:"code" gorm:"uniqueIndex"`
+	UserID    uint      `json:"-" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
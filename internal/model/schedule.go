@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// Schedule recurrences. Weekly parties repeat at the same local time in the
+// room's timezone, across daylight saving changes.
+const (
+    RecurNone   = ""
+    RecurWeekly = "weekly"
+)
+
+// Location returns the room's schedule timezone, UTC if unset or unknown.
+func (r Room) Location() *time.Location {
+    if r.Timezone != "" {
+        if loc, err := time.LoadLocation(r.Timezone); err == nil {
+            return loc
+        }
+    }
+    return time.UTC
+}
+
+// Schedule makes the room start at start, repeating per recurrence in
+// start's timezone, and sets its next start as of now.
+func (r *Room) Schedule(start time.Time, recurrence string, now time.Time) {
+    r.ScheduledAt = &start
+    r.Timezone = start.Location().String()
+    r.Recurrence = recurrence
+    r.NextStartAt = r.OccurrenceAfter(now)
+}
+
+// OccurrenceAfter returns the first scheduled start of the room after t, or
+// nil if there is none.
+func (r Room) OccurrenceAfter(t time.Time) *time.Time {
+    if r.ScheduledAt == nil {
+        return nil
+    }
+    start := *r.ScheduledAt
+    if start.After(t) {
+        return &start
+    }
+    if r.Recurrence != RecurWeekly {
+        return nil
+    }
+
+    // Step in calendar weeks of the room's timezone, so the local time of
+    // day stays put when the UTC offset changes.
+    local := start.In(r.Location())
+    week := 7 * 24 * time.Hour
+    for n := int(t.Sub(start) / week); ; n++ {
+        next := time.Date(local.Year(), local.Month(), local.Day()+7*n,
+            local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), local.Location())
+        if next.After(t) {
+            return &next
+        }
+    }
+}
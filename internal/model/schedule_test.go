@@ -0,0 +1,76 @@
+package model
+
+import (
+    "testing"
+    "time"
+)
+
+func TestRoomLocation(t *testing.T) {
+    for tz, want := range map[string]string{"": "UTC", "Not/AZone": "UTC", "Europe/Berlin": "Europe/Berlin"} {
+        if got := (Room{Timezone: tz}).Location().String(); got != want {
+            t.Errorf("Location(%q) = %s, want %s", tz, got, want)
+        }
+    }
+}
+
+func TestRoomOccurrenceAfter(t *testing.T) {
+    loc, err := time.LoadLocation("America/New_York")
+    if err != nil {
+        t.Skipf("no timezone data: %v", err)
+    }
+    // 8pm on the Sunday before the clocks go forward.
+    start := time.Date(2026, 3, 1, 20, 0, 0, 0, loc)
+
+    once := Room{ScheduledAt: &start, Timezone: loc.String()}
+    if got := once.OccurrenceAfter(start.Add(-time.Minute)); got == nil || !got.Equal(start) {
+        t.Errorf("before a one-off start = %v, want %v", got, start)
+    }
+    if got := once.OccurrenceAfter(start); got != nil {
+        t.Errorf("after a one-off start = %v, want none", got)
+    }
+    if got := (Room{}).OccurrenceAfter(start); got != nil {
+        t.Errorf("unscheduled room = %v, want none", got)
+    }
+
+    weekly := Room{ScheduledAt: &start, Timezone: loc.String(), Recurrence: RecurWeekly}
+    tests := []struct {
+        name  string
+        after time.Time
+        want  time.Time
+    }{
+        {"before the first", start.Add(-time.Hour), start},
+        {"at the first", start, time.Date(2026, 3, 8, 20, 0, 0, 0, loc)},
+        {"across the change to summer time", start.Add(24 * time.Hour), time.Date(2026, 3, 8, 20, 0, 0, 0, loc)},
+        {"months later", time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 7, 5, 20, 0, 0, 0, loc)},
+        {"across the change back", time.Date(2026, 11, 2, 0, 0, 0, 0, loc), time.Date(2026, 11, 8, 20, 0, 0, 0, loc)},
+    }
+    for _, tt := range tests {
+        got := weekly.OccurrenceAfter(tt.after)
+        if got == nil || !got.Equal(tt.want) {
+            t.Errorf("%s: OccurrenceAfter = %v, want %v", tt.name, got, tt.want)
+            continue
+        }
+        if local := got.In(loc); local.Hour() != 20 || local.Weekday() != time.Sunday {
+            t.Errorf("%s: starts %v, want Sundays at 8pm local time", tt.name, local)
+        }
+    }
+}
+
+func TestRoomSchedule(t *testing.T) {
+    loc := time.FixedZone("Test", 2*3600)
+    start := time.Date(2026, 3, 1, 20, 0, 0, 0, loc)
+
+    var room Room
+    room.Schedule(start, RecurWeekly, start.Add(time.Hour))
+    if room.ScheduledAt == nil || !room.ScheduledAt.Equal(start) || room.Timezone != "Test" || room.Recurrence != RecurWeekly {
+        t.Errorf("room = %+v", room)
+    }
+    if want := start.AddDate(0, 0, 7); room.NextStartAt == nil || !room.NextStartAt.Equal(want) {
+        t.Errorf("NextStartAt = %v, want %v", room.NextStartAt, want)
+    }
+
+    room.Schedule(start, RecurNone, start.Add(time.Hour))
+    if room.NextStartAt != nil {
+        t.Errorf("a past one-off start is still coming up at %v", room.NextStartAt)
+    }
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionActive(t *testing.T) {
+	now := time.Now()
+	revoked := now.Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		session Session
+		want    bool
+	}{
+		{"fresh", Session{ExpiresAt: now.Add(time.Hour)}, true},
+		{"expired", Session{ExpiresAt: now.Add(-time.Second)}, false},
+		{"expiring now", Session{ExpiresAt: now}, false},
+		{"revoked", Session{ExpiresAt: now.Add(time.Hour), RevokedAt: &revoked}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.session.Active(now); got != tt.want {
+			t.Errorf("%s: Active = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
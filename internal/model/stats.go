@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Playback event types.
+const (
+    PlaybackPlay  = "play"
+    PlaybackPause = "pause"
+    PlaybackSeek  = "seek"
+    PlaybackVideo = "video" // the room switched to another video
+)
+
+// RoomVisit is a stretch of time a user spent in a room, from joining or
+// coming back until they were last seen.
+type RoomVisit struct {
+    ID         uint      `gorm:"primaryKey"`
+    RoomID     uint      `gorm:"index:idx_room_visits_room_user"`
+    UserID     uint      `gorm:"index:idx_room_visits_room_user"`
+    StartedAt  time.Time
+    LastSeenAt time.Time
+}
+
+// PlaybackEvent is a change of a room's playback, made by UserID or by the
+// scheduler if it is 0.
+type PlaybackEvent struct {
+    ID       uint      `gorm:"primaryKey"`
+    RoomID   uint      `gorm:"index"`
+    UserID   uint
+    Type     string
+    Position float64   // seconds into the video after the change
+    VideoURL string
+    At       time.Time
+}
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// RoomTemplate is a room setup a user saved to create rooms from: the
+// room's settings, its moderators and its queue.
+type RoomTemplate struct {
+    ID              uint      `json:"id" gorm:"primaryKey"`
+    UserID          uint      `json:"-" gorm:"uniqueIndex:idx_room_templates_user_name"`
+    Name            string    `json:"name" gorm:"uniqueIndex:idx_room_templates_user_name"`
+    RoomName        string    `json:"room_name"`
+    VideoURL        string    `json:"video_url"`
+    Visibility      string    `json:"visibility"`
+    PasswordHash    string    `json:"-"`
+    JoinPolicy      string    `json:"join_policy"`
+    MaxParticipants int       `json:"max_participants"`
+    Moderators      []RoomTemplateModerator `json:"-" gorm:"foreignKey:TemplateID"`
+    Queue           []RoomTemplateItem      `json:"-" gorm:"foreignKey:TemplateID"`
+    CreatedAt       time.Time `json:"created_at"`
+    UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// RoomTemplateModerator is a user made moderator of rooms created from a
+// template.
+type RoomTemplateModerator struct {
+    ID         uint `gorm:"primaryKey"`
+    TemplateID uint `gorm:"index"`
+    UserID     uint `gorm:"index"`
+}
+
+// RoomTemplateItem is a queued video of a template, in Position order.
+type RoomTemplateItem struct {
+    ID         uint `gorm:"primaryKey"`
+    TemplateID uint `gorm:"index"`
+    Position   int
+    VideoURL   string
+    Title      string
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// LoginThrottle tracks recent failed logins for a subject, which is an
+// account ("user:<id>"), a login that matches no account ("login:<name>")
+// or a client address ("ip:<addr>"). It also counts the requests a client
+// address makes for mail to be sent ("mail:<addr>").
+type LoginThrottle struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	Subject       string    `json:"subject" gorm:"uniqueIndex"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	LockedUntil   time.Time `json:"locked_until"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Purposes of single-use user tokens.
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenPasswordReset = "password_reset"
+	TokenMagicLink     = "magic_link"
+)
+
+// UserToken is a single-use, expiring token emailed to a user. Only a hash
+// of the token is stored. Magic link tokens for addresses without an
+// account have no UserID, only Email.
+type UserToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	Email     string     `json:"email" gorm:"index"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -1,8 +1,37 @@
 package model
 
+import "regexp"
+
+// Usernames are 3 to 32 letters, digits, dots, dashes or underscores.
+const (
+	UsernameMinLength = 3
+	UsernameMaxLength = 32
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// ValidUsername reports whether name may be used as a username.
+func ValidUsername(name string) bool {
+	return usernamePattern.MatchString(name)
+}
+
+// UsernameRune reports whether r may appear in a username.
+func UsernameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-'
+}
+
 type User struct {
 	ID uint `json:"id" gorm:"primaryKey"`
 	Username string `json:"username"`
 	Email string `json:"email"`
-	Password string `json:"password"`  // User's password
+	DisplayName string `json:"display_name"`
+	Bio string `json:"bio"`
+	AvatarKey string `json:"avatar_key"`  // Content hash of the uploaded avatar
+	EmailVerified bool `json:"email_verified"`
+	IsAdmin bool `json:"is_admin"`
+	Premium bool `json:"premium"`  // Premium hosts may reserve vanity room codes
+	Password string `json:"-"`  // Encoded password hash, never serialised
+	TOTPEnabled bool `json:"totp_enabled"`
+	TOTPSecret string `json:"-"`  // Base32 secret encrypted with AES-GCM, pending until TOTPEnabled
+	TOTPLastStep int64 `json:"-"`  // Last accepted time step, to stop code replay
 }
\ No newline at end of file
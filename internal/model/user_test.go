@@ -0,0 +1,19 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidUsername(t *testing.T) {
+	for _, name := range []string{"bob", "alice_1", "a.b-c", strings.Repeat("x", UsernameMaxLength)} {
+		if !ValidUsername(name) {
+			t.Errorf("ValidUsername(%q) = false", name)
+		}
+	}
+	for _, name := range []string{"", "ab", "has space", "ünïcode", "a@b", strings.Repeat("x", UsernameMaxLength+1)} {
+		if ValidUsername(name) {
+			t.Errorf("ValidUsername(%q) = true", name)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Package oidc implements the relying-party side of the OpenID Connect
+// authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+)
+
+// ErrNotConfigured is returned when no identity provider has been set up.
+var ErrNotConfigured = errors.New("oidc login is not configured")
+
+// Default is the provider used by the API, or nil when OIDC is disabled.
+var Default *Provider
+
+// InitProvider configures Default from OIDC_ISSUER, OIDC_CLIENT_ID,
+// OIDC_CLIENT_SECRET and OIDC_REDIRECT_URL. OIDC stays disabled if the
+// issuer is unset.
+func InitProvider() error {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if clientID == "" || redirectURL == "" {
+		return fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER is set")
+	}
+	Default = &Provider{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  redirectURL,
+		Scopes:       strings.Fields(config.String("OIDC_SCOPES", "openid email profile")),
+	}
+	return nil
+}
+
+// Provider is an OpenID Connect identity provider. Its endpoints and signing
+// keys are discovered from the issuer on first use.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     map[string]interface{}
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func (p *Provider) client() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+func (p *Provider) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// discover loads and caches the provider metadata.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var m metadata
+	u := strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, u, &m); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %v", err)
+	}
+	if strings.TrimSuffix(m.Issuer, "/") != strings.TrimSuffix(p.Issuer, "/") {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", m.Issuer, p.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery: incomplete provider metadata")
+	}
+	p.metadata = &m
+	return p.metadata, nil
+}
+
+// AuthRequest holds the per-login secrets that must survive the round trip
+// to the provider.
+type AuthRequest struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// NewAuthRequest generates a fresh state, nonce and PKCE verifier.
+func NewAuthRequest() (AuthRequest, error) {
+	var r AuthRequest
+	for _, s := range []*string{&r.State, &r.Nonce, &r.Verifier} {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return r, err
+		}
+		*s = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return r, nil
+}
+
+// challenge returns the S256 PKCE code challenge for verifier.
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the URL to send the user to in order to log in.
+func (p *Provider) AuthCodeURL(ctx context.Context, r AuthRequest) (string, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {r.State},
+		"nonce":                 {r.Nonce},
+		"code_challenge":        {challenge(r.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return m.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades an authorization code for tokens and returns the verified
+// ID token claims.
+func (p *Provider) Exchange(ctx context.Context, code string, r AuthRequest) (*Claims, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"code_verifier": {r.Verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tok struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("oidc token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || tok.Error != "" {
+		return nil, fmt.Errorf("oidc token exchange failed: %s %s", tok.Error, tok.ErrorDescription)
+	}
+	if tok.IDToken == "" {
+		return nil, fmt.Errorf("oidc token response has no id_token")
+	}
+
+	return p.Verify(ctx, tok.IDToken, r.Nonce)
+}
@@ -0,0 +1,301 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID    = "videoparty"
+	testRedirectURL = "http://localhost:8080/api/oidc/callback"
+	testKeyID       = "key-1"
+)
+
+// testIdP is an identity provider serving discovery, a key set and a token
+// endpoint that checks the PKCE verifier like a real provider would.
+type testIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+// grant is what the provider remembers about an authorization code.
+type grant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key, codes: make(map[string]grant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		pub := key.PublicKey
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.mu.Lock()
+		g, ok := idp.codes[r.PostForm.Get("code")]
+		delete(idp.codes, r.PostForm.Get("code"))
+		idp.mu.Unlock()
+
+		switch {
+		case !ok || r.PostForm.Get("grant_type") != "authorization_code":
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		case challenge(r.PostForm.Get("code_verifier")) != g.challenge:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+			return
+		case r.PostForm.Get("redirect_uri") != testRedirectURL || r.PostForm.Get("client_id") != testClientID:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_request"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, g.claims, key)})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *testIdP) provider() *Provider {
+	return &Provider{
+		Issuer:      idp.URL,
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+		Scopes:      []string{"openid", "email"},
+		HTTPClient:  idp.Client(),
+	}
+}
+
+// claims returns valid ID token claims for nonce.
+func (idp *testIdP) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.URL,
+		"aud":            testClientID,
+		"sub":            "alice-1",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          nonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+	}
+}
+
+// authorize plays the user logging in at the provider: it checks the
+// authorization request and hands out a code for claims.
+func (idp *testIdP) authorize(t *testing.T, authURL string, claims func(nonce string) jwt.MapClaims) (code, state string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if got := u.Scheme + "://" + u.Host + u.Path; got != idp.URL+"/authorize" {
+		t.Fatalf("authorization endpoint = %q", got)
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("authorization request without S256 PKCE: %v", q)
+	}
+	if q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirectURL || q.Get("response_type") != "code" {
+		t.Fatalf("unexpected authorization request: %v", q)
+	}
+
+	code = base64.RawURLEncoding.EncodeToString([]byte(q.Get("state")))
+	idp.mu.Lock()
+	idp.codes[code] = grant{challenge: q.Get("code_challenge"), claims: claims(q.Get("nonce"))}
+	idp.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (idp *testIdP) sign(t *testing.T, claims jwt.MapClaims, key *rsa.PrivateKey) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestLoginRoundTrip(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	ctx := context.Background()
+
+	authReq, err := NewAuthRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := p.AuthCodeURL(ctx, authReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := idp.authorize(t, authURL, idp.claims)
+	if state != authReq.State {
+		t.Fatalf("state = %q, want %q", state, authReq.State)
+	}
+
+	claims, err := p.Exchange(ctx, code, authReq)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.Subject != "alice-1" || claims.Email != "alice@example.com" || !claims.EmailVerified {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.Issuer != idp.URL {
+		t.Errorf("issuer = %q, want %q", claims.Issuer, idp.URL)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	ctx := context.Background()
+
+	authReq, _ := NewAuthRequest()
+	authURL, err := p.AuthCodeURL(ctx, authReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := idp.authorize(t, authURL, idp.claims)
+
+	other, _ := NewAuthRequest()
+	authReq.Verifier = other.Verifier
+	if _, err := p.Exchange(ctx, code, authReq); err == nil {
+		t.Fatal("Exchange succeeded with the wrong PKCE verifier")
+	}
+}
+
+func TestVerifyRejectsInvalidIDToken(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+		key    *rsa.PrivateKey
+	}{
+		{name: "bad signature", key: otherKey},
+		{name: "wrong audience", modify: func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{name: "wrong issuer", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-5 * time.Minute).Unix() }},
+		{name: "no expiry", modify: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "nonce mismatch", modify: func(c jwt.MapClaims) { c["nonce"] = "replayed" }},
+		{name: "no subject", modify: func(c jwt.MapClaims) { delete(c, "sub") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newTestIdP(t)
+			p := idp.provider()
+			ctx := context.Background()
+
+			authReq, _ := NewAuthRequest()
+			claims := idp.claims(authReq.Nonce)
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			key := idp.key
+			if tt.key != nil {
+				key = tt.key
+			}
+
+			if _, err := p.Verify(ctx, idp.sign(t, claims, key), authReq.Nonce); err == nil {
+				t.Fatal("Verify accepted the token")
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsSymmetricAlgorithm(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+
+	// A token signed with the client ID as an HMAC secret must not pass
+	// just because the verifier is handed a key.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.claims("n"))
+	token.Header["kid"] = testKeyID
+	raw, err := token.SignedString([]byte(testClientID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Verify(context.Background(), raw, "n"); err == nil {
+		t.Fatal("Verify accepted an HS256 token")
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	p.Issuer = strings.Replace(idp.URL, "127.0.0.1", "localhost", 1)
+
+	if _, err := p.AuthCodeURL(context.Background(), AuthRequest{}); err == nil {
+		t.Fatal("discovery accepted metadata for another issuer")
+	}
+}
+
+func TestNewAuthRequestIsUnique(t *testing.T) {
+	a, err := NewAuthRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewAuthRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.State == b.State || a.Nonce == b.Nonce || a.Verifier == b.Verifier {
+		t.Fatal("NewAuthRequest repeated a value")
+	}
+	if a.State == a.Nonce || a.Nonce == a.Verifier {
+		t.Fatal("NewAuthRequest reused a value within one request")
+	}
+}
+
+func TestChallengeMatchesRFC7636(t *testing.T) {
+	// Appendix B of RFC 7636.
+	got := challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("challenge = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id hashes passwords with argon2id, encoded in the PHC string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type Argon2id struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (a *Argon2id) Verify(encoded, password string) (bool, error) {
+	p, err := decodeArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), p.salt, p.iterations, p.memory, p.parallelism, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+}
+
+func (a *Argon2id) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (a *Argon2id) NeedsRehash(encoded string) bool {
+	p, err := decodeArgon2(encoded)
+	if err != nil {
+		return true
+	}
+	return p.memory < a.Memory || p.iterations < a.Iterations || p.parallelism < a.Parallelism ||
+		uint32(len(p.salt)) < a.SaltLength || uint32(len(p.key)) < a.KeyLength
+}
+
+func decodeArgon2(encoded string) (argon2Params, error) {
+	var p argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, ErrUnknownFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, fmt.Errorf("invalid argon2id version: %v", err)
+	}
+	if version != argon2.Version {
+		return p, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return p, fmt.Errorf("invalid argon2id parameters: %v", err)
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, fmt.Errorf("invalid argon2id salt: %v", err)
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return p, fmt.Errorf("invalid argon2id key: %v", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,123 @@
+// Package password hashes and verifies user passwords. Encoded hashes
+// record their algorithm and parameters, so hashes made under older
+// settings keep verifying and can be upgraded on the next login.
+package password
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownFormat is returned for encoded hashes no hasher recognises.
+var ErrUnknownFormat = errors.New("unknown password hash format")
+
+// Hasher produces and checks encoded password hashes for one algorithm.
+type Hasher interface {
+	// Hash returns the encoded hash of password.
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded.
+	Verify(encoded, password string) (bool, error)
+	// Handles reports whether encoded was produced by this algorithm.
+	Handles(encoded string) bool
+	// NeedsRehash reports whether encoded was made with parameters weaker
+	// than the hasher's current ones.
+	NeedsRehash(encoded string) bool
+}
+
+// Default hashes new passwords. Hashes from the other known algorithms are
+// still verified, and reported as needing a rehash. InitHasher replaces it
+// with the configured hasher.
+var Default Hasher = &Argon2id{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// InitHasher configures Default from the environment.
+func InitHasher() {
+	Default = FromEnv()
+}
+
+var known = []Hasher{
+	&Argon2id{},
+	&Bcrypt{},
+}
+
+// FromEnv builds the hasher selected by PASSWORD_ALGORITHM ("argon2id", the
+// default, or "bcrypt") with parameters from the environment.
+func FromEnv() Hasher {
+	if config.String("PASSWORD_ALGORITHM", "argon2id") == "bcrypt" {
+		return &Bcrypt{Cost: config.Int("BCRYPT_COST", bcrypt.DefaultCost)}
+	}
+	return &Argon2id{
+		Memory:      uint32(config.Int("ARGON2_MEMORY_KB", 64*1024)),
+		Iterations:  uint32(config.Int("ARGON2_ITERATIONS", 3)),
+		Parallelism: uint8(config.Int("ARGON2_PARALLELISM", 2)),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Hash hashes password with Default.
+func Hash(password string) (string, error) {
+	return Default.Hash(password)
+}
+
+// Verify checks password against encoded with whichever algorithm made it.
+// needsRehash is set when the password matched but encoded should be
+// replaced by a fresh hash from Default.
+func Verify(encoded, password string) (ok, needsRehash bool, err error) {
+	for _, h := range known {
+		if !h.Handles(encoded) {
+			continue
+		}
+		ok, err = h.Verify(encoded, password)
+		if !ok || err != nil {
+			return false, false, err
+		}
+		if !Default.Handles(encoded) {
+			return true, true, nil
+		}
+		return true, Default.NeedsRehash(encoded), nil
+	}
+	return false, false, ErrUnknownFormat
+}
+
+// Bcrypt hashes passwords with bcrypt at Cost.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b *Bcrypt) cost() int {
+	if b.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return b.Cost
+}
+
+func (b *Bcrypt) Hash(password string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(password), b.cost())
+	return string(h), err
+}
+
+func (b *Bcrypt) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *Bcrypt) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (b *Bcrypt) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < b.cost()
+}
@@ -0,0 +1,160 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// lightArgon2id keeps the tests fast.
+var lightArgon2id = &Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func withDefault(t *testing.T, h Hasher) {
+	t.Helper()
+	old := Default
+	Default = h
+	t.Cleanup(func() { Default = old })
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	encoded, err := lightArgon2id.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("encoded = %s", encoded)
+	}
+	if ok, err := lightArgon2id.Verify(encoded, "correct horse"); !ok || err != nil {
+		t.Errorf("Verify(right password) = %v, %v", ok, err)
+	}
+	if ok, err := lightArgon2id.Verify(encoded, "wrong horse"); ok || err != nil {
+		t.Errorf("Verify(wrong password) = %v, %v", ok, err)
+	}
+
+	other, _ := lightArgon2id.Hash("correct horse")
+	if other == encoded {
+		t.Error("two hashes of the same password share a salt")
+	}
+}
+
+func TestArgon2idRejectsMalformedHashes(t *testing.T) {
+	for _, encoded := range []string{
+		"$argon2id$v=19$m=1024,t=1,p=1$salt",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5",
+	} {
+		if ok, err := lightArgon2id.Verify(encoded, "x"); ok || err == nil {
+			t.Errorf("Verify(%q) = %v, %v; want an error", encoded, ok, err)
+		}
+	}
+}
+
+func TestVerifyUpgradesHashes(t *testing.T) {
+	withDefault(t, lightArgon2id)
+
+	current, _ := Hash("correct horse")
+	weaker, _ := (&Argon2id{Memory: 512, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}).Hash("correct horse")
+	legacy, _ := (&Bcrypt{Cost: bcrypt.MinCost}).Hash("correct horse")
+
+	tests := []struct {
+		name       string
+		encoded    string
+		password   string
+		ok, rehash bool
+	}{
+		{"current", current, "correct horse", true, false},
+		{"weaker parameters", weaker, "correct horse", true, true},
+		{"bcrypt", legacy, "correct horse", true, true},
+		{"wrong password", legacy, "wrong horse", false, false},
+	}
+	for _, tt := range tests {
+		ok, rehash, err := Verify(tt.encoded, tt.password)
+		if err != nil || ok != tt.ok || rehash != tt.rehash {
+			t.Errorf("%s: Verify = %v, %v, %v; want %v, %v", tt.name, ok, rehash, err, tt.ok, tt.rehash)
+		}
+	}
+
+	if _, _, err := Verify("plaintext", "plaintext"); err != ErrUnknownFormat {
+		t.Errorf("Verify(unknown format) error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestBcryptNeedsRehash(t *testing.T) {
+	encoded, _ := (&Bcrypt{Cost: bcrypt.MinCost}).Hash("x")
+	if !(&Bcrypt{Cost: bcrypt.MinCost + 1}).NeedsRehash(encoded) {
+		t.Error("a cheaper bcrypt hash does not need a rehash")
+	}
+	if (&Bcrypt{Cost: bcrypt.MinCost}).NeedsRehash(encoded) {
+		t.Error("a bcrypt hash at the current cost needs a rehash")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("PASSWORD_ALGORITHM", "bcrypt")
+	t.Setenv("BCRYPT_COST", "11")
+	if h, ok := FromEnv().(*Bcrypt); !ok || h.Cost != 11 {
+		t.Errorf("FromEnv = %#v, want bcrypt at cost 11", FromEnv())
+	}
+
+	t.Setenv("PASSWORD_ALGORITHM", "")
+	t.Setenv("ARGON2_MEMORY_KB", "2048")
+	if h, ok := FromEnv().(*Argon2id); !ok || h.Memory != 2048 {
+		t.Errorf("FromEnv = %#v, want argon2id with 2048 KiB", FromEnv())
+	}
+}
+
+// withBreachedList reloads the breached list from a file holding lines.
+func withBreachedList(t *testing.T, lines ...string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PASSWORD_BREACHED_LIST", path)
+	breachedOnce, breached = sync.Once{}, nil
+	t.Cleanup(func() { breachedOnce, breached = sync.Once{}, nil })
+}
+
+func TestValidate(t *testing.T) {
+	withBreachedList(t,
+		"hunter2hunter2",
+		sha1Hex("trustno1trustno1")+":42",
+		strings.ToLower(sha1Hex("letmeinplease")),
+	)
+
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"a long and unusual passphrase", true},
+		{"short", false},
+		{strings.Repeat("x", maxLength+1), false},
+		{"password123", false},
+		{"hunter2hunter2", false},
+		{"trustno1trustno1", false},
+		{"letmeinplease", false},
+		{"ñandú-ñandú", true},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.password)
+		if (err == nil) != tt.ok {
+			t.Errorf("Validate(%.20q) = %v, want ok %v", tt.password, err, tt.ok)
+		}
+	}
+}
+
+func TestValidateMinLength(t *testing.T) {
+	t.Setenv("PASSWORD_MIN_LENGTH", "12")
+	if err := Validate("elevenchars"); err == nil {
+		t.Error("accepted a password under PASSWORD_MIN_LENGTH")
+	}
+	// Length is counted in characters, not bytes.
+	if err := Validate("ééééééééééé"); err == nil {
+		t.Error("counted bytes instead of characters")
+	}
+}
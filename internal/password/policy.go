@@ -0,0 +1,110 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+)
+
+// PolicyError explains why a password was rejected.
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Reason
+}
+
+// maxLength keeps hashing cost bounded for absurdly long inputs.
+const maxLength = 256
+
+// commonPasswords are always rejected, even without a breached list.
+var commonPasswords = []string{
+	"password", "password1", "password123", "12345678", "123456789",
+	"1234567890", "qwerty123", "qwertyuiop", "iloveyou", "11111111",
+	"00000000", "letmein1", "welcome1", "abc12345", "videoparty",
+}
+
+var (
+	breachedOnce sync.Once
+	breached     map[string]struct{}
+)
+
+// loadBreached reads PASSWORD_BREACHED_LIST once. The file holds one entry
+// per line, either a plaintext password or an upper- or lower-case SHA-1
+// hex digest optionally followed by ":count", as in the Pwned Passwords
+// downloads.
+func loadBreached() {
+	breached = make(map[string]struct{})
+	for _, p := range commonPasswords {
+		breached[sha1Hex(p)] = struct{}{}
+	}
+
+	path := os.Getenv("PASSWORD_BREACHED_LIST")
+	if path == "" {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("Error opening breached password list: %v", err)
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if digest, _, _ := strings.Cut(line, ":"); isSHA1Hex(digest) {
+			breached[strings.ToUpper(digest)] = struct{}{}
+			continue
+		}
+		breached[sha1Hex(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Error reading breached password list: %v", err)
+	}
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Validate checks password against the password policy: a minimum length
+// of PASSWORD_MIN_LENGTH characters (default 8) and absence from the
+// breached password list.
+func Validate(password string) error {
+	minLength := config.Int("PASSWORD_MIN_LENGTH", 8)
+	n := utf8.RuneCountInString(password)
+	if n < minLength {
+		return &PolicyError{Reason: fmt.Sprintf("Password must be at least %d characters", minLength)}
+	}
+	if n > maxLength {
+		return &PolicyError{Reason: fmt.Sprintf("Password must be at most %d characters", maxLength)}
+	}
+
+	breachedOnce.Do(loadBreached)
+	if _, found := breached[sha1Hex(password)]; found {
+		return &PolicyError{Reason: "This password has appeared in a data breach, please choose another"}
+	}
+	return nil
+}
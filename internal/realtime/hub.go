@@ -0,0 +1,144 @@
+// Package realtime pushes room events to connected clients. Events are
+// fanned out to every server instance through Postgres LISTEN/NOTIFY, so a
+// client is reached whichever instance it is connected to.
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+// Event types sent to clients.
+const (
+	EventState   = "state"   // playback state changed
+	EventRoom    = "room"    // name, video or settings changed
+	EventHost    = "host"    // host changed
+	EventClosed  = "closed"  // the party ended
+	EventDeleted = "deleted" // the room was deleted
+	EventQueue   = "queue"   // the queue changed
+
+	EventLobby        = "lobby"         // to the host: someone is waiting
+	EventAdmitted     = "admitted"      // to a lobby user: let in
+	EventDenied       = "denied"        // to a lobby user: turned away
+	EventLobbyExpired = "lobby_expired" // to a lobby user: request expired
+)
+
+// Event is a message for the subscribers of a room. UserID restricts it to
+// one user's connections; Close disconnects them after delivery.
+type Event struct {
+	Type   string          `json:"type"`
+	RoomID uint            `json:"room_id"`
+	UserID uint            `json:"user_id,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Close  bool            `json:"close,omitempty"`
+}
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped.
+const subscriberBuffer = 32
+
+// Subscriber is one client connection to a room.
+type Subscriber struct {
+	RoomID uint
+	UserID uint
+	// DirectOnly subscribers, such as users waiting in a lobby, only get
+	// events addressed to them.
+	DirectOnly bool
+	// Events is closed if the subscriber falls too far behind.
+	Events chan Event
+}
+
+var (
+	mu    sync.Mutex
+	rooms = make(map[uint]map[*Subscriber]struct{})
+)
+
+// Subscribe registers a connection of userID to roomID. Callers must
+// Unsubscribe when the connection ends.
+func Subscribe(roomID, userID uint, directOnly bool) *Subscriber {
+	s := &Subscriber{
+		RoomID:     roomID,
+		UserID:     userID,
+		DirectOnly: directOnly,
+		Events:     make(chan Event, subscriberBuffer),
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if rooms[roomID] == nil {
+		rooms[roomID] = make(map[*Subscriber]struct{})
+	}
+	rooms[roomID][s] = struct{}{}
+	return s
+}
+
+// Unsubscribe removes s. It is safe to call more than once.
+func Unsubscribe(s *Subscriber) {
+	mu.Lock()
+	defer mu.Unlock()
+	remove(s)
+}
+
+// remove drops s and closes its channel; mu must be held.
+func remove(s *Subscriber) {
+	subs := rooms[s.RoomID]
+	if _, ok := subs[s]; !ok {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(rooms, s.RoomID)
+	}
+	close(s.Events)
+}
+
+// deliver hands ev to the matching subscribers of this instance.
+func deliver(ev Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	for s := range rooms[ev.RoomID] {
+		if ev.UserID != 0 && s.UserID != ev.UserID {
+			continue
+		}
+		if ev.UserID == 0 && s.DirectOnly {
+			continue
+		}
+		select {
+		case s.Events <- ev:
+		default:
+			log.Printf("Dropping slow subscriber of room %d", s.RoomID)
+			remove(s)
+		}
+	}
+}
+
+// Broadcast sends an event to everyone connected to roomID.
+func Broadcast(roomID uint, eventType string, data interface{}) {
+	publish(roomID, 0, eventType, data, false)
+}
+
+// Notify sends an event to userID's connections to roomID.
+func Notify(roomID, userID uint, eventType string, data interface{}) {
+	publish(roomID, userID, eventType, data, false)
+}
+
+// Disconnect sends a final event to userID's connections to roomID, or to
+// all of the room's connections if userID is 0, and then closes them.
+func Disconnect(roomID, userID uint, eventType string, data interface{}) {
+	publish(roomID, userID, eventType, data, true)
+}
+
+func publish(roomID, userID uint, eventType string, data interface{}, disconnect bool) {
+	ev := Event{Type: eventType, RoomID: roomID, UserID: userID, Close: disconnect}
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("Error encoding %s event for room %d: %v", eventType, roomID, err)
+			return
+		}
+		ev.Data = b
+	}
+	if !notify(ev) {
+		deliver(ev)
+	}
+}
@@ -0,0 +1,127 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// received drains the events waiting for s.
+func received(s *Subscriber) []Event {
+	var events []Event
+	for {
+		select {
+		case ev, ok := <-s.Events:
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+func TestBroadcastAndNotify(t *testing.T) {
+	host := Subscribe(1, 10, false)
+	viewer := Subscribe(1, 20, false)
+	lobby := Subscribe(1, 30, true)
+	elsewhere := Subscribe(2, 10, false)
+	for _, s := range []*Subscriber{host, viewer, lobby, elsewhere} {
+		defer Unsubscribe(s)
+	}
+
+	Broadcast(1, EventRoom, map[string]string{"name": "Movie night"})
+	Notify(1, 30, EventAdmitted, nil)
+
+	if got := received(host); len(got) != 1 || got[0].Type != EventRoom {
+		t.Errorf("host got %+v, want the broadcast", got)
+	}
+	if got := received(viewer); len(got) != 1 || got[0].Type != EventRoom || got[0].Close {
+		t.Errorf("viewer got %+v, want the broadcast", got)
+	}
+	got := received(lobby)
+	if len(got) != 1 || got[0].Type != EventAdmitted || got[0].UserID != 30 {
+		t.Errorf("lobby user got %+v, want only their own event", got)
+	}
+	if got := received(elsewhere); len(got) != 0 {
+		t.Errorf("another room's subscriber got %+v", got)
+	}
+
+	var data map[string]string
+	Broadcast(1, EventRoom, map[string]string{"name": "Renamed"})
+	ev := received(host)[0]
+	if err := json.Unmarshal(ev.Data, &data); err != nil || data["name"] != "Renamed" || ev.RoomID != 1 {
+		t.Errorf("event = %+v, data %v", ev, data)
+	}
+	received(viewer)
+}
+
+func TestDisconnect(t *testing.T) {
+	host := Subscribe(3, 10, false)
+	viewer := Subscribe(3, 20, false)
+	lobby := Subscribe(3, 30, true)
+	for _, s := range []*Subscriber{host, viewer, lobby} {
+		defer Unsubscribe(s)
+	}
+
+	Disconnect(3, 20, EventDenied, nil)
+	if got := received(viewer); len(got) != 1 || !got[0].Close || got[0].Type != EventDenied {
+		t.Errorf("turned away user got %+v, want a closing event", got)
+	}
+	if got := received(host); len(got) != 0 {
+		t.Errorf("host got %+v from someone else's event", got)
+	}
+
+	Disconnect(3, 0, EventClosed, nil)
+	for name, s := range map[string]*Subscriber{"host": host, "viewer": viewer} {
+		if got := received(s); len(got) != 1 || !got[0].Close {
+			t.Errorf("%s got %+v, want a closing event", name, got)
+		}
+	}
+	if got := received(lobby); len(got) != 0 {
+		t.Errorf("lobby user got %+v from a room-wide event", got)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	slow := Subscribe(4, 10, false)
+	defer Unsubscribe(slow)
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		Broadcast(4, EventRoom, nil)
+	}
+	n := 0
+	for range slow.Events {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("slow subscriber got %d events before being dropped, want %d", n, subscriberBuffer)
+	}
+
+	mu.Lock()
+	_, ok := rooms[4]
+	mu.Unlock()
+	if ok {
+		t.Error("the dropped subscriber's room is still registered")
+	}
+}
+
+func TestUnsubscribeTwice(t *testing.T) {
+	s := Subscribe(5, 10, false)
+	Unsubscribe(s)
+	Unsubscribe(s)
+	if _, ok := <-s.Events; ok {
+		t.Error("events channel still open")
+	}
+	Broadcast(5, EventRoom, nil)
+}
+
+func TestUnencodableDataIsDropped(t *testing.T) {
+	s := Subscribe(6, 10, false)
+	defer Unsubscribe(s)
+
+	Broadcast(6, EventRoom, make(chan int))
+	if got := received(s); len(got) != 0 {
+		t.Errorf("got %+v for data that cannot be encoded", got)
+	}
+}
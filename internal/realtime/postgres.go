@@ -0,0 +1,99 @@
+package realtime
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// channel is the Postgres notification channel events travel on.
+const channel = "videoparty_events"
+
+// maxPayload is the largest event Postgres accepts as a notification
+// payload, less some headroom.
+const maxPayload = 7900
+
+var (
+	bridge    *gorm.DB
+	listening atomic.Bool
+)
+
+// Init fans events out through database, listening for them on a dedicated
+// connection that is re-established if it drops. Without Init, events only
+// reach clients of this instance.
+func Init(database *gorm.DB) error {
+	sqlDB, err := database.DB()
+	if err != nil {
+		return err
+	}
+	bridge = database
+	go func() {
+		for {
+			err := listen(context.Background(), sqlDB)
+			listening.Store(false)
+			log.Printf("Realtime listener stopped, reconnecting: %v", err)
+			time.Sleep(5 * time.Second)
+		}
+	}()
+	return nil
+}
+
+// listen delivers notifications locally until the connection fails.
+func listen(ctx context.Context, sqlDB *sql.DB) error {
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", driverConn)
+		}
+		pc := c.Conn()
+		if _, err := pc.Exec(ctx, "LISTEN "+channel); err != nil {
+			return err
+		}
+		listening.Store(true)
+		for {
+			n, err := pc.WaitForNotification(ctx)
+			if err != nil {
+				// Don't return a connection still listening to the pool.
+				return fmt.Errorf("%w: %v", driver.ErrBadConn, err)
+			}
+			var ev Event
+			if err := json.Unmarshal([]byte(n.Payload), &ev); err != nil {
+				log.Printf("Ignoring malformed realtime event: %v", err)
+				continue
+			}
+			deliver(ev)
+		}
+	})
+}
+
+// notify publishes ev through Postgres, reporting false if it has to be
+// delivered locally instead.
+func notify(ev Event) bool {
+	if bridge == nil || !listening.Load() {
+		return false
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil || len(payload) > maxPayload {
+		log.Printf("Realtime event %s for room %d too large to fan out", ev.Type, ev.RoomID)
+		return false
+	}
+	if err := bridge.Exec("SELECT pg_notify(?, ?)", channel, string(payload)).Error; err != nil {
+		log.Printf("Error publishing realtime event: %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+// Package scheduler starts scheduled watch parties when their time comes.
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	"github.com/spacelord16/Videoparty/internal/config"
+	"github.com/spacelord16/Videoparty/internal/db"
+	"github.com/spacelord16/Videoparty/internal/model"
+	"gorm.io/gorm"
+)
+
+// Start checks for due rooms every SCHEDULER_INTERVAL in the background,
+// calling started for each room it starts playing.
+func Start(database *gorm.DB, started func(model.Room)) {
+	interval := config.Duration("SCHEDULER_INTERVAL", time.Second)
+	if interval <= 0 {
+		log.Println("Room scheduler disabled")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			rooms, err := db.StartDueRooms(database, now)
+			if err != nil {
+				log.Printf("Room scheduler failed: %v", err)
+			}
+			for _, room := range rooms {
+				log.Printf("Room %s started on schedule", room.Code)
+				started(room)
+			}
+		}
+	}()
+}
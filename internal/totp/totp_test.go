@@ -0,0 +1,132 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of RFC 6238 Appendix B, "12345678901234567890",
+// in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// Appendix B lists eight digit codes; six digit codes are their last six.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeAcceptsLowercaseSecret(t *testing.T) {
+	got, err := Code(strings.ToLower(rfcSecret), 1)
+	if err != nil || got != "287082" {
+		t.Errorf("Code = %q, %v", got, err)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code accepted an invalid secret")
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	for offset := int64(-3); offset <= 3; offset++ {
+		code, _ := Code(rfcSecret, step+offset)
+		got, ok := Validate(rfcSecret, code, now, 0)
+		want := offset >= -skew && offset <= skew
+		if ok != want {
+			t.Errorf("code %+d steps away: ok = %v, want %v", offset, ok, want)
+		}
+		if ok && got != step+offset {
+			t.Errorf("code %+d steps away matched step %d, want %d", offset, got, step+offset)
+		}
+	}
+}
+
+func TestValidateRejectsReplay(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := Code(rfcSecret, Step(now))
+
+	step, ok := Validate(rfcSecret, code, now, 0)
+	if !ok {
+		t.Fatal("valid code rejected")
+	}
+	if _, ok := Validate(rfcSecret, code, now, step); ok {
+		t.Error("the same step was accepted twice")
+	}
+	// A code from before the last accepted step is stale too, even inside
+	// the skew window.
+	previous, _ := Code(rfcSecret, step-1)
+	if _, ok := Validate(rfcSecret, previous, now, step); ok {
+		t.Error("an older step was accepted after a newer one")
+	}
+	next, _ := Code(rfcSecret, step+1)
+	if _, ok := Validate(rfcSecret, next, now, step); !ok {
+		t.Error("the next step was rejected")
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"287 082", " 287082 "} {
+		if _, ok := Validate(rfcSecret, code, now, 0); !ok {
+			t.Errorf("%q rejected", code)
+		}
+	}
+	for _, code := range []string{"", "28708", "2870822", "abcdef", "94287082"} {
+		if _, ok := Validate(rfcSecret, code, now, 0); ok {
+			t.Errorf("%q accepted", code)
+		}
+	}
+	if _, ok := Validate("not base32!", "287082", now, 0); ok {
+		t.Error("code accepted for an invalid secret")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateSecret()
+	if a == b {
+		t.Fatal("GenerateSecret repeated a secret")
+	}
+	key, err := encoding.DecodeString(a)
+	if err != nil || len(key) != 20 {
+		t.Errorf("secret %q decodes to %d bytes, %v", a, len(key), err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("Video Party", "alice@example.com", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Video Party:alice@example.com" {
+		t.Errorf("URI = %s", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != rfcSecret || q.Get("issuer") != "Video Party" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("query = %v", q)
+	}
+}